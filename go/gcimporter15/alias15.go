@@ -0,0 +1,44 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.5,!go1.22
+
+package gcimporter
+
+import (
+	"go/token"
+	"go/types"
+)
+
+// Before Go 1.22, go/types has no alias types: alias type names
+// denote their types directly. See alias22.go and alias23.go.
+
+// genericAliases reports whether go/types supports generic aliases.
+const genericAliases = false
+
+// unalias returns t.
+func unalias(t types.Type) types.Type {
+	return t
+}
+
+func aliasRhs(t types.Type) (types.Type, bool) {
+	return nil, false
+}
+
+func isAlias(obj *types.TypeName) bool {
+	// TypeName.IsAlias exists since Go 1.9
+	a, ok := interface{}(obj).(interface {
+		IsAlias() bool
+	})
+	return ok && a.IsAlias()
+}
+
+func aliasDecl(obj *types.TypeName) (rhs types.Type, tparams []types.Type) {
+	return obj.Type(), nil
+}
+
+// newAlias returns a new alias type name for rhs; tparams must be empty.
+func newAlias(pos token.Pos, pkg *types.Package, name string, rhs types.Type, tparams []types.Type) *types.TypeName {
+	return types.NewTypeName(pos, pkg, name, rhs)
+}
@@ -0,0 +1,48 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.22,!go1.23
+
+package gcimporter
+
+import (
+	"go/token"
+	"go/types"
+)
+
+// In Go 1.22, go/types has alias types if enabled with
+// GODEBUG=gotypesalias=1, but no accessor for their right-hand
+// sides and no generic aliases. See alias23.go.
+
+// genericAliases reports whether go/types supports generic aliases.
+const genericAliases = false
+
+// unalias returns the type t denotes, following alias types.
+func unalias(t types.Type) types.Type {
+	return types.Unalias(t)
+}
+
+// aliasRhs returns the type denoted by t if t is an alias type;
+// since the right-hand side of an alias is not accessible, it is
+// the type at the end of a chain of aliases.
+func aliasRhs(t types.Type) (types.Type, bool) {
+	if a, ok := t.(*types.Alias); ok {
+		return types.Unalias(a), true
+	}
+	return nil, false
+}
+
+func isAlias(obj *types.TypeName) bool {
+	return obj.IsAlias()
+}
+
+// aliasDecl returns the type denoted by the alias type name obj.
+func aliasDecl(obj *types.TypeName) (rhs types.Type, tparams []types.Type) {
+	return types.Unalias(obj.Type()), nil
+}
+
+// newAlias returns a new alias type name for rhs; tparams must be empty.
+func newAlias(pos token.Pos, pkg *types.Package, name string, rhs types.Type, tparams []types.Type) *types.TypeName {
+	return types.NewTypeName(pos, pkg, name, rhs)
+}
@@ -0,0 +1,51 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.23
+
+package gcimporter
+
+import (
+	"go/token"
+	"go/types"
+)
+
+// genericAliases reports whether go/types supports generic aliases.
+const genericAliases = true
+
+// unalias returns the type t denotes, following alias types.
+func unalias(t types.Type) types.Type {
+	return types.Unalias(t)
+}
+
+// aliasRhs returns the type denoted by t if t is an alias type.
+func aliasRhs(t types.Type) (types.Type, bool) {
+	if a, ok := t.(*types.Alias); ok {
+		return a.Rhs(), true
+	}
+	return nil, false
+}
+
+func isAlias(obj *types.TypeName) bool {
+	return obj.IsAlias()
+}
+
+// aliasDecl returns the type denoted by the alias type name obj
+// and its type parameters.
+func aliasDecl(obj *types.TypeName) (rhs types.Type, tparams []types.Type) {
+	if a, ok := obj.Type().(*types.Alias); ok {
+		return a.Rhs(), typeParamSlice(a.TypeParams())
+	}
+	return obj.Type(), nil
+}
+
+// newAlias returns a new alias type name for rhs.
+func newAlias(pos token.Pos, pkg *types.Package, name string, rhs types.Type, tparams []types.Type) *types.TypeName {
+	obj := types.NewTypeName(pos, pkg, name, nil)
+	a := types.NewAlias(obj, rhs)
+	if len(tparams) > 0 {
+		a.SetTypeParams(asTypeParams(tparams))
+	}
+	return obj
+}
@@ -0,0 +1,148 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.23
+
+package gcimporter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnderlyingChain(t *testing.T) {
+	const src = `package p
+type A = B
+type B C
+type C struct{ X int }
+`
+	pkg := bimport(t, exportSource(t, "p", src), "p")
+	A := pkg.Scope().Lookup("A")
+	if !A.(*types.TypeName).IsAlias() {
+		t.Fatalf("%s is not an alias", A)
+	}
+
+	var got []string
+	for _, typ := range UnderlyingChain(A.Type()) {
+		got = append(got, fmt.Sprintf("%T %s", typ, typ))
+	}
+	want := []string{
+		"*types.Alias p.A",
+		"*types.Named p.B",
+		"*types.Struct struct{X int}",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("UnderlyingChain(A) = %q; want %q", got, want)
+	}
+
+	if got := UnderlyingChain(types.Typ[types.Int]); len(got) != 1 {
+		t.Errorf("UnderlyingChain(int) = %v; want [int]", got)
+	}
+}
+
+func TestAliasTestdata(t *testing.T) {
+	src, err := ioutil.ReadFile(filepath.Join("testdata", "alias.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := typecheck(t, token.NewFileSet(), "alias", string(src))
+	pkg := bimport(t, exportSource(t, "alias", string(src)), "alias")
+
+	for _, name := range []string{"T", "A", "P", "S", "I"} {
+		want := orig.Scope().Lookup(name)
+		got := pkg.Scope().Lookup(name)
+		if got == nil {
+			t.Errorf("%s not found", name)
+			continue
+		}
+		if alias := got.(*types.TypeName).IsAlias(); alias != want.(*types.TypeName).IsAlias() {
+			t.Errorf("%s: IsAlias() = %v; want %v", name, alias, want.(*types.TypeName).IsAlias())
+		}
+		if got.Type().String() != want.Type().String() {
+			t.Errorf("%s: got type %s; want %s", name, got.Type(), want.Type())
+		}
+	}
+
+	// aliases denote the types they alias; uses of aliases, as in the
+	// types of variables, are recorded as the aliased types
+	T := pkg.Scope().Lookup("T").Type()
+	for _, test := range []struct {
+		name string
+		want types.Type
+	}{
+		{"A", T},
+		{"VA", T},
+		{"P", types.NewPointer(T)},
+		{"VP", types.NewPointer(T)},
+		{"S", types.NewSlice(T)},
+		{"VS", types.NewSlice(T)},
+		{"I", types.Typ[types.Int]},
+	} {
+		if got := pkg.Scope().Lookup(test.name).Type(); !types.Identical(got, test.want) {
+			t.Errorf("%s: type %s not identical to %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestAliasCycle(t *testing.T) {
+	data := exportSource(t, "p", "package p; type Alias1 = Target; type Target struct{ Next *Target }")
+	// make the alias refer to itself, as in "type Alias1 = Alias1"
+	if bytes.Count(data, []byte("Target")) != 1 {
+		t.Fatalf("cannot locate type name in export data")
+	}
+	bad := bytes.Replace(data, []byte("Target"), []byte("Alias1"), 1)
+
+	_, _, err := BImportData(token.NewFileSet(), make(map[string]*types.Package), bad, "p")
+	if !errors.Is(err, ErrAliasCycle) {
+		t.Fatalf("got error %v; want ErrAliasCycle", err)
+	}
+	if want := "Alias1 -> Alias1"; !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q does not mention %s", err, want)
+	}
+}
+
+func TestAny(t *testing.T) {
+	const src = `package p
+func F(x any) any { return x }
+func G(x interface{}) {}
+type A = any
+type S struct{ X any }
+`
+	pkg := bimport(t, exportSource(t, "p", src), "p")
+	universeAny := types.Universe.Lookup("any").Type()
+
+	f := pkg.Scope().Lookup("F").Type().(*types.Signature)
+	for _, v := range []*types.Var{f.Params().At(0), f.Results().At(0)} {
+		if got := types.TypeString(v.Type(), nil); got != "any" {
+			t.Errorf("F: %s has type %s; want any", v.Name(), got)
+		}
+		if v.Type() != universeAny {
+			t.Errorf("F: type of %s is not the predeclared any", v.Name())
+		}
+	}
+	s := pkg.Scope().Lookup("S").Type().Underlying().(*types.Struct)
+	if got := s.Field(0).Type(); got != universeAny {
+		t.Errorf("type of S.X is %s (%T); want the predeclared any", got, got)
+	}
+	if rhs, _ := aliasDecl(pkg.Scope().Lookup("A").(*types.TypeName)); rhs != universeAny {
+		t.Errorf("A denotes %s (%T); want the predeclared any", rhs, rhs)
+	}
+
+	// interface{} remains a distinct, but identical, type
+	g := pkg.Scope().Lookup("G").Type().(*types.Signature)
+	x := g.Params().At(0).Type()
+	if got := types.TypeString(x, nil); got != "interface{}" {
+		t.Errorf("G: x has type %s; want interface{}", got)
+	}
+	if !types.Identical(x, universeAny) {
+		t.Errorf("G: type %s of x is not identical to any", x)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.5
+
+package gcimporter
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"go/types"
+	"io"
+	"os"
+)
+
+// ArchiveMembers returns the names of the members of the archive in
+// file filename, such as "__.PKGDEF" and "_go_.o" for archives written
+// by the gc toolchain, in order. It reads only the member headers.
+// ArchiveMembers is meant for diagnosing files in which FindExportData
+// fails to find export data.
+func ArchiveMembers(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	line, err := r.ReadSlice('\n')
+	if err != nil || string(line) != "!<arch>\n" {
+		return nil, errors.New("not an archive: " + filename)
+	}
+
+	var names []string
+	for {
+		if _, err := r.Peek(1); err == io.EOF {
+			return names, nil
+		}
+		name, size, err := readGopackHeader(r)
+		if err != nil {
+			return names, err
+		}
+		names = append(names, name)
+		if _, err := r.Discard(size); err != nil {
+			return names, err
+		}
+		if size%2 != 0 {
+			r.Discard(1) // members are 2-byte aligned; ignore missing padding at end
+		}
+	}
+}
+
+// ImportArchiveBytes imports the package with the given import path
+// from archive, the contents of an archive written by the gc toolchain,
+// adds it to the packages map, and returns it. The archive is decoded
+// in memory: its export data is taken from the __.PKGDEF member, found
+// by walking the member headers. Dependencies are not imported.
+func ImportArchiveBytes(packages map[string]*types.Package, archive []byte, path string) (*types.Package, error) {
+	pkgdef, err := archiveMember(archive, "__.PKGDEF")
+	if err != nil {
+		return nil, fmt.Errorf("archive of %s: %v", path, err)
+	}
+	return new(Importer).importFile(packages, path, path, bytes.NewReader(pkgdef))
+}
+
+var errNotArchive = errors.New("not an archive")
+
+// archiveMember returns the contents of the member name of archive,
+// or errNotArchive if archive is not an archive.
+func archiveMember(archive []byte, name string) ([]byte, error) {
+	const magic = "!<arch>\n"
+	if !bytes.HasPrefix(archive, []byte(magic)) {
+		return nil, errNotArchive
+	}
+	data := archive[len(magic):]
+	for len(data) > 0 {
+		if len(data) < gopackHeaderSize {
+			return nil, errors.New("invalid archive header")
+		}
+		member, size, err := parseGopackHeader(data[:gopackHeaderSize])
+		if err != nil {
+			return nil, err
+		}
+		data = data[gopackHeaderSize:]
+		if size < 0 || size > len(data) {
+			return nil, fmt.Errorf("archive member %s truncated", member)
+		}
+		if member == name {
+			return data[:size], nil
+		}
+		if size%2 != 0 && size < len(data) {
+			size++ // members are 2-byte aligned
+		}
+		data = data[size:]
+	}
+	return nil, fmt.Errorf("archive is missing %s", name)
+}
@@ -0,0 +1,121 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.13
+
+package gcimporter
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestArchiveMembers(t *testing.T) {
+	MustHaveGoBuild(t)
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "p.go"), []byte("package p\nconst C = 1\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("go", "tool", "compile", "-p", "p", "-o", "p.a", "p.go")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go tool compile failed: %v\n%s", err, out)
+	}
+
+	names, err := ArchiveMembers(filepath.Join(dir, "p.a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, name := range names {
+		found = found || name == "__.PKGDEF"
+	}
+	if !found {
+		t.Errorf("ArchiveMembers = %v; want __.PKGDEF among them", names)
+	}
+
+	if _, err := ArchiveMembers(filepath.Join(dir, "p.go")); err == nil {
+		t.Errorf("ArchiveMembers succeeded for source file")
+	}
+}
+
+// archiveData returns an archive with the given members, alternating
+// member names and contents, laid out as by the gc toolchain.
+func archiveData(members ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("!<arch>\n")
+	for i := 0; i < len(members); i += 2 {
+		name, data := members[i], members[i+1]
+		fmt.Fprintf(&buf, "%-16s%-12d%-6d%-6d%-8o%-10d`\n", name, 0, 0, 0, 0644, len(data))
+		buf.WriteString(data)
+		if len(data)%2 != 0 {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestImportArchiveBytes(t *testing.T) {
+	pkgdef := string(objectFile(runtime.GOARCH, exportSource(t, "example.com/p", "package p\ntype T struct{ X int }\nfunc F(T) int { return 0 }\n")))
+	// an odd-sized member precedes __.PKGDEF
+	archive := archiveData("__.SYMDEF", "odd", "__.PKGDEF", pkgdef, "_go_.o", "go object\n")
+
+	pkg, err := ImportArchiveBytes(make(map[string]*types.Package), archive, "example.com/p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj := pkg.Scope().Lookup("F"); obj == nil {
+		t.Errorf("%s.F not found", pkg.Path())
+	} else if got, want := obj.Type().String(), "func(example.com/p.T) int"; got != want {
+		t.Errorf("%s.F has type %s; want %s", pkg.Path(), got, want)
+	}
+
+	for _, archive := range [][]byte{
+		archive[:100],                        // truncated
+		archiveData("_go_.o", "go object\n"), // no export data
+		[]byte(pkgdef),                       // not an archive
+	} {
+		if _, err := ImportArchiveBytes(make(map[string]*types.Package), archive, "example.com/p"); err == nil {
+			t.Errorf("import of invalid archive %.20q succeeded", archive)
+		}
+	}
+}
+
+func TestImportArchiveFile(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// as written by "go build -o lib.a", __.PKGDEF comes first
+	pkgdef := string(objectFile(runtime.GOARCH, exportSource(t, "lib", "package lib\ntype T struct{ X int }\n")))
+	archive := archiveData("__.PKGDEF", pkgdef, "_go_.o", "go object\n")
+	if err := ioutil.WriteFile(filepath.Join(dir, "lib.a"), archive, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	pkg, err := Import(make(map[string]*types.Package), "./lib", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Scope().Lookup("T") == nil {
+		t.Errorf("%s.T not found", pkg.Path())
+	}
+
+	// other leading members are not accepted in object files
+	archive = archiveData("__.SYMDEF", "odd", "__.PKGDEF", pkgdef)
+	if err := ioutil.WriteFile(filepath.Join(dir, "lib.a"), archive, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Import(make(map[string]*types.Package), "./lib", dir); err == nil {
+		t.Errorf("import of archive not starting with __.PKGDEF succeeded")
+	}
+}
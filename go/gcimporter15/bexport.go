@@ -18,6 +18,7 @@ import (
 	"go/constant"
 	"go/token"
 	"go/types"
+	"io"
 	"log"
 	"math"
 	"math/big"
@@ -40,10 +41,13 @@ const debugFormat = false // default: false
 // If trace is set, debugging output is printed to std out.
 const trace = false // default: false
 
-// This version doesn't write the nointerface flag for exported methods.
-// The corresponding importer handles both "v0" and "v1".
-// See also issues #16243, #16244.
-const exportVersion = "v0"
+// Version 2 of the format adds type parameters, instantiated types,
+// union types, explicitly embedded interface types, and aliases. Like
+// version 1, it writes the (always false) nointerface flag for exported
+// methods. Version 3 adds the list of imported packages following the
+// package itself. The corresponding importer handles versions "v0"
+// through "v3". See also issues #16243, #16244.
+const exportVersion = 3
 
 // trackAllTypes enables cycle tracking for all types, not just named
 // types. The existing compiler invariants assume that unnamed types
@@ -59,17 +63,24 @@ const trackAllTypes = false
 type exporter struct {
 	fset *token.FileSet
 	out  bytes.Buffer
+	w    io.Writer // if set, receives the contents of out; see flush
+	err  error     // first error writing to w
 
 	// object -> index maps, indexed in order of serialization
 	strIndex map[string]int
 	pkgIndex map[*types.Package]int
 	typIndex map[types.Type]int
 
+	// type parameters in scope, innermost last
+	tparams []types.Type
+
 	// position encoding
 	posInfoFormat bool
 	prevFile      string
 	prevLine      int
 
+	version int
+
 	// debugging support
 	written int // bytes written
 	indent  int // for trace
@@ -78,14 +89,68 @@ type exporter struct {
 // BExportData returns binary export data for pkg.
 // If no file set is provided, position info will be missing.
 func BExportData(fset *token.FileSet, pkg *types.Package) []byte {
-	p := exporter{
+	return bexportData(fset, pkg, exportVersion, true)
+}
+
+// BExportDataNoPos is like BExportData but omits all position information.
+// The result depends only on the declarations of pkg, not on the names or
+// layout of its source files, which makes it suitable for reproducible
+// artifacts and cache keys.
+func BExportDataNoPos(pkg *types.Package) []byte {
+	return bexportData(nil, pkg, exportVersion, false)
+}
+
+// BExportTo writes the binary export data for pkg, as returned by
+// BExportData, to w. The data is written while
+// pkg is encoded, in chunks of a few kilobytes, so that it need not
+// be held in memory as a whole. BExportTo returns the first error
+// reported by w; the data written until then is incomplete.
+func BExportTo(w io.Writer, fset *token.FileSet, pkg *types.Package) error {
+	p := newExporter(fset, exportVersion, true)
+	p.w = w
+	p.export(pkg)
+	p.flush(0)
+	return p.err
+}
+
+// bexportData is like BExportData but writes the given format version,
+// with or without position information.
+func bexportData(fset *token.FileSet, pkg *types.Package, version int, posInfo bool) []byte {
+	p := newExporter(fset, version, posInfo)
+	p.export(pkg)
+	return p.out.Bytes()
+}
+
+func newExporter(fset *token.FileSet, version int, posInfo bool) *exporter {
+	return &exporter{
 		fset:          fset,
 		strIndex:      map[string]int{"": 0}, // empty string is mapped to 0
 		pkgIndex:      make(map[*types.Package]int),
 		typIndex:      make(map[types.Type]int),
-		posInfoFormat: true, // TODO(gri) might become a flag, eventually
+		posInfoFormat: posInfo,
+		version:       version,
+	}
+}
+
+// flushSize is the amount of buffered data above which an exporter
+// writing to an io.Writer passes the data on.
+const flushSize = 4 << 10
+
+// flush writes the data buffered in p.out to p.w if there is at least
+// min bytes of it. It does nothing if p.w is not set or writing to it
+// failed before.
+func (p *exporter) flush(min int) {
+	if p.w == nil || p.out.Len() < min {
+		return
+	}
+	if p.err == nil {
+		_, p.err = p.w.Write(p.out.Bytes())
 	}
+	p.out.Reset()
+}
 
+// export writes the export data for pkg.
+func (p *exporter) export(pkg *types.Package) {
 	// first byte indicates low-level encoding format
 	var format byte = 'c' // compact
 	if debugFormat {
@@ -114,21 +179,36 @@ func BExportData(fset *token.FileSet, pkg *types.Package) []byte {
 	if trace {
 		p.tracef("version = ")
 	}
-	p.string(exportVersion)
+	p.string(fmt.Sprintf("v%d", p.version))
 	if trace {
 		p.tracef("\n")
 	}
 
 	// populate type map with predeclared "known" types
-	for index, typ := range predeclared {
+	known := predeclaredTypes(p.version)
+	for index, typ := range known {
 		p.typIndex[typ] = index
 	}
-	if len(p.typIndex) != len(predeclared) {
+	if len(p.typIndex) != len(known) {
 		log.Fatalf("gcimporter: duplicate entries in type map?")
 	}
 
 	// write package data
 	p.pkg(pkg, true)
+	if p.version >= 3 {
+		var imports []*types.Package
+		for _, imp := range pkg.Imports() {
+			// The pseudo-package C of packages using cgo, as declared
+			// by go/types with Config.FakeImportC, has no export data.
+			if imp.Path() != "C" {
+				imports = append(imports, imp)
+			}
+		}
+		p.int(len(imports))
+		for _, imp := range imports {
+			p.pkg(imp, false)
+		}
+	}
 	if trace {
 		p.tracef("\n")
 	}
@@ -145,6 +225,7 @@ func BExportData(fset *token.FileSet, pkg *types.Package) []byte {
 		}
 		p.obj(scope.Lookup(name))
 		objcount++
+		p.flush(flushSize)
 	}
 
 	// indicate end of list
@@ -161,8 +242,6 @@ func BExportData(fset *token.FileSet, pkg *types.Package) []byte {
 	}
 
 	// --- end of export data ---
-
-	return p.out.Bytes()
 }
 
 func (p *exporter) pkg(pkg *types.Package, emptypath bool) {
@@ -202,6 +281,16 @@ func (p *exporter) obj(obj types.Object) {
 		p.value(obj.Val())
 
 	case *types.TypeName:
+		if p.version >= 2 && isAlias(obj) {
+			rhs, tparams := aliasDecl(obj)
+			p.tag(aliasTag)
+			p.pos(obj)
+			p.qualifiedName(obj)
+			n := p.typeParamList(tparams)
+			p.typ(rhs)
+			p.endTypeParams(n)
+			break
+		}
 		p.tag(typeTag)
 		p.typ(obj.Type())
 
@@ -216,8 +305,10 @@ func (p *exporter) obj(obj types.Object) {
 		p.pos(obj)
 		p.qualifiedName(obj)
 		sig := obj.Type().(*types.Signature)
+		n := p.typeParamList(typeParams(sig))
 		p.paramList(sig.Params(), sig.Variadic())
 		p.paramList(sig.Results(), false)
+		p.endTypeParams(n)
 
 	default:
 		log.Fatalf("gcimporter: unexpected object %v (%T)", obj, obj)
@@ -302,6 +393,12 @@ func (p *exporter) typ(t types.Type) {
 		return
 	}
 
+	// aliases are not recorded in the format; use the aliased type
+	if u := unalias(t); u != t {
+		p.typ(u)
+		return
+	}
+
 	// otherwise, remember the type, write the type tag (< 0) and type data
 	if trackAllTypes {
 		if trace {
@@ -313,6 +410,18 @@ func (p *exporter) typ(t types.Type) {
 
 	switch t := t.(type) {
 	case *types.Named:
+		// instantiated types are written as their generic type
+		// and type arguments; they are not tracked
+		if origin, targs := instanceOf(t); len(targs) > 0 {
+			p.tag(instanceTag)
+			p.typ(origin)
+			p.int(len(targs))
+			for _, targ := range targs {
+				p.typ(targ)
+			}
+			break
+		}
+
 		if !trackAllTypes {
 			// if we don't track all types, track named types now
 			p.typIndex[t] = len(p.typIndex)
@@ -321,10 +430,12 @@ func (p *exporter) typ(t types.Type) {
 		p.tag(namedTag)
 		p.pos(t.Obj())
 		p.qualifiedName(t.Obj())
+		n := p.typeParamList(typeParams(t))
 		p.typ(t.Underlying())
 		if !types.IsInterface(t) {
 			p.assocMethods(t)
 		}
+		p.endTypeParams(n)
 
 	case *types.Array:
 		p.tag(arrayTag)
@@ -367,10 +478,65 @@ func (p *exporter) typ(t types.Type) {
 		p.typ(t.Elem())
 
 	default:
+		if isTypeParam(t) {
+			p.tag(typeParamTag)
+			p.int(p.typeParamIndex(t))
+			break
+		}
+		if terms, tilde, ok := unionTerms(t); ok {
+			p.tag(unionTag)
+			p.int(len(terms))
+			for i, term := range terms {
+				p.bool(tilde[i])
+				p.typ(term)
+			}
+			break
+		}
 		log.Fatalf("gcimporter: unexpected type %T: %s", t, t)
 	}
 }
 
+// typeParamList writes the type parameters tparams (version 2 and later)
+// and brings them into scope. It returns the number of type parameters;
+// the caller must pass it to endTypeParams when leaving their scope.
+func (p *exporter) typeParamList(tparams []types.Type) int {
+	if p.version < 2 {
+		if len(tparams) > 0 {
+			log.Fatalf("gcimporter: type parameters require export data version 2")
+		}
+		return 0
+	}
+
+	p.int(len(tparams))
+	for _, tp := range tparams {
+		obj := typeParamObj(tp)
+		p.pos(obj)
+		p.string(obj.Name())
+	}
+	// constraints may refer to the type parameters themselves
+	p.tparams = append(p.tparams, tparams...)
+	for _, tp := range tparams {
+		p.typ(typeParamConstraint(tp))
+	}
+	return len(tparams)
+}
+
+func (p *exporter) endTypeParams(n int) {
+	p.tparams = p.tparams[:len(p.tparams)-n]
+}
+
+// typeParamIndex returns the index of the type parameter t
+// in the list of type parameters in scope.
+func (p *exporter) typeParamIndex(t types.Type) int {
+	for i := len(p.tparams) - 1; i >= 0; i-- {
+		if p.tparams[i] == t {
+			return i
+		}
+	}
+	log.Fatalf("gcimporter: type parameter %s not in scope", t)
+	panic("unreachable")
+}
+
 func (p *exporter) assocMethods(named *types.Named) {
 	// Sort methods (for determinism).
 	var methods []*types.Func
@@ -398,9 +564,15 @@ func (p *exporter) assocMethods(named *types.Named) {
 		}
 
 		sig := m.Type().(*types.Signature)
+		n := p.typeParamList(recvTypeParams(sig))
 		p.paramList(types.NewTuple(sig.Recv()), false)
+		n += p.typeParamList(typeParams(sig)) // generic methods
 		p.paramList(sig.Params(), sig.Variadic())
 		p.paramList(sig.Results(), false)
+		if p.version >= 1 {
+			p.int(0) // nointerface flag
+		}
+		p.endTypeParams(n)
 	}
 
 	if trace && methods != nil {
@@ -441,11 +613,24 @@ func (p *exporter) field(f *types.Var) {
 }
 
 func (p *exporter) iface(t *types.Interface) {
-	// TODO(gri): enable importer to load embedded interfaces,
-	// then emit Embeddeds and ExplicitMethods separately here.
-	p.int(0)
+	if p.version < 2 {
+		// versions before 2 have no embedded types;
+		// write the full method set instead
+		p.int(0)
+		p.methodList(t.NumMethods(), t.Method)
+		return
+	}
 
-	n := t.NumMethods()
+	p.bool(isImplicit(t))
+	embeddeds := embeddedTypes(t)
+	p.int(len(embeddeds))
+	for _, typ := range embeddeds {
+		p.typ(typ)
+	}
+	p.methodList(t.NumExplicitMethods(), t.ExplicitMethod)
+}
+
+func (p *exporter) methodList(n int, method func(int) *types.Func) {
 	if trace && n > 0 {
 		p.tracef("methods {>\n")
 		defer p.tracef("<\n} ")
@@ -455,7 +640,7 @@ func (p *exporter) iface(t *types.Interface) {
 		if trace && i > 0 {
 			p.tracef("\n")
 		}
-		p.method(t.Method(i))
+		p.method(method(i))
 	}
 }
 
@@ -481,9 +666,11 @@ func (p *exporter) method(m *types.Func) {
 func (p *exporter) fieldName(f *types.Var) {
 	name := f.Name()
 
-	// anonymous field with unexported base type name: use "?" as field name
+	// anonymous field: use "" as field name, or "?" if the base type
+	// name is unexported so that the package is recorded
 	// (bname != "" per spec, but we are conservative in case of errors)
 	if f.Anonymous() {
+		name = ""
 		base := f.Type()
 		if ptr, ok := base.(*types.Pointer); ok {
 			base = ptr.Elem()
@@ -556,9 +743,10 @@ func (p *exporter) value(x constant.Value) {
 		p.float(x)
 
 	case constant.Complex:
+		// the parts of a complex constant may be integers (e.g. 1i)
 		p.tag(complexTag)
-		p.float(constant.Real(x))
-		p.float(constant.Imag(x))
+		p.float(constant.ToFloat(constant.Real(x)))
+		p.float(constant.ToFloat(constant.Imag(x)))
 
 	case constant.String:
 		p.tag(stringTag)
@@ -593,7 +781,18 @@ func (p *exporter) float(x constant.Value) {
 	} else if num, denom := constant.Num(x), constant.Denom(x); num.Kind() == constant.Int {
 		// TODO(gri): add big.Rat accessor to constant.Value.
 		r := valueToRat(num)
-		f.SetRat(r.Quo(r, valueToRat(denom)))
+		r.Quo(r, valueToRat(denom))
+		// Fractions without finite binary representation are rounded
+		// to the precision of the compiler's constants; all others,
+		// such as large integers, are represented exactly.
+		prec := uint(constPrec)
+		if n := r.Num().BitLen(); n > int(prec) {
+			prec = uint(n)
+		}
+		if n := r.Denom().BitLen(); n > int(prec) {
+			prec = uint(n)
+		}
+		f.SetPrec(prec).SetRat(r)
 	} else {
 		// Value too large to represent as a fraction => inaccessible.
 		// TODO(gri): add big.Float accessor to constant.Value.
@@ -618,6 +817,10 @@ func (p *exporter) float(x constant.Value) {
 	p.string(string(mant.Bytes()))
 }
 
+// constPrec is the mantissa precision in bits of the floating-point
+// constants of cmd/compile (Mpprec).
+const constPrec = 512
+
 func valueToRat(x constant.Value) *big.Rat {
 	// Convert little-endian to big-endian.
 	// I can't believe this is necessary.
@@ -808,4 +1011,10 @@ var tagString = [...]string{
 	-complexTag:  "complex",
 	-stringTag:   "string",
 	-unknownTag:  "unknown",
+
+	// Type parameters and aliases:
+	-typeParamTag: "type parameter",
+	-instanceTag:  "instance",
+	-unionTag:     "union",
+	-aliasTag:     "alias",
 }
@@ -7,6 +7,7 @@
 package gcimporter_test
 
 import (
+	"bytes"
 	"fmt"
 	"go/ast"
 	"go/build"
@@ -326,3 +327,45 @@ func TestVeryLongFile(t *testing.T) {
 			posn2, want, posn1)
 	}
 }
+
+// errWriter fails once more than n bytes have been written to it.
+type errWriter struct{ n int }
+
+func (w *errWriter) Write(data []byte) (int, error) {
+	if w.n -= len(data); w.n < 0 {
+		return 0, fmt.Errorf("write failed")
+	}
+	return len(data), nil
+}
+
+func TestBExportTo(t *testing.T) {
+	// large enough to be written in several chunks
+	var src bytes.Buffer
+	src.WriteString("package p\ntype T struct{ X, Y int }\n")
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&src, "func F%d(t *T, s string) (T, error) { return *t, nil }\n", i)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src.String(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conf types.Config
+	pkg, err := conf.Check("p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := gcimporter.BExportData(fset, pkg)
+	var buf bytes.Buffer
+	if err := gcimporter.BExportTo(&buf, fset, pkg); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("BExportTo wrote %d bytes differing from the %d bytes of BExportData", buf.Len(), len(want))
+	}
+
+	if err := gcimporter.BExportTo(&errWriter{len(want) / 2}, fset, pkg); err == nil {
+		t.Errorf("BExportTo succeeded despite write error")
+	}
+}
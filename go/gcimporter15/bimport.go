@@ -10,11 +10,14 @@ package gcimporter
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"go/constant"
 	"go/token"
 	"go/types"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"unicode"
@@ -22,11 +25,12 @@ import (
 )
 
 type importer struct {
+	conf    *Importer
 	imports map[string]*types.Package
 	data    []byte
 	path    string
 	buf     []byte // for reading strings
-	version string
+	version int    // export data version
 
 	// object lists
 	strList       []string         // in order of appearance
@@ -34,6 +38,17 @@ type importer struct {
 	typList       []types.Type     // in order of appearance
 	trackAllTypes bool
 
+	// type parameters in scope, innermost last
+	tparams []types.Type
+
+	// redacted type names and those waiting for Importer.OnType,
+	// not in the package scope yet
+	hidden  map[string]*types.TypeName
+	pending []*types.TypeName // waiting for OnType, in order of appearance
+
+	// aliases being declared, innermost last
+	aliases []*types.TypeName
+
 	// position encoding
 	posInfoFormat bool
 	prevFile      string
@@ -51,7 +66,13 @@ type importer struct {
 // If data is obviously malformed, an error is returned but in
 // general it is not recommended to call BImportData on untrusted data.
 func BImportData(fset *token.FileSet, imports map[string]*types.Package, data []byte, path string) (int, *types.Package, error) {
+	return new(Importer).bimportData(fset, imports, data, path)
+}
+
+// bimportData is like BImportData but subject to the configuration of imp.
+func (imp *Importer) bimportData(fset *token.FileSet, imports map[string]*types.Package, data []byte, path string) (n int, pkg *types.Package, err error) {
 	p := importer{
+		conf:    imp,
 		imports: imports,
 		data:    data,
 		path:    path,
@@ -60,32 +81,31 @@ func BImportData(fset *token.FileSet, imports map[string]*types.Package, data []
 		files:   make(map[string]*token.File),
 	}
 
-	// read low-level encoding format
-	switch format := p.rawByte(); format {
-	case 'c':
-		// compact format - nothing to do
-	case 'd':
-		p.debugFormat = true
-	default:
-		return p.read, nil, fmt.Errorf("invalid encoding format in export data: got %q; want 'c' or 'd'", format)
-	}
-
-	p.trackAllTypes = p.rawByte() == 'a'
-
-	p.posInfoFormat = p.int() != 0
-
-	// --- generic export data ---
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+			// nothing to do
+		case formatError:
+			n, pkg, err = p.read, nil, &CorruptError{int64(p.read), string(r)}
+		case *aliasCycleError:
+			n, pkg, err = p.read, nil, r
+		case runtime.Error:
+			// undetected format error, such as an index out of range
+			n, pkg, err = p.read, nil, &CorruptError{int64(p.read), fmt.Sprintf("invalid export data for %s: %v", p.path, r)}
+		default:
+			panic(r) // internal error
+		}
+	}()
 
-	p.version = p.string()
-	if p.version != "v0" && p.version != "v1" {
-		return p.read, nil, fmt.Errorf("unknown export data version: %s", p.version)
+	if err := p.header(); err != nil {
+		return p.read, nil, err
 	}
 
-	// populate typList with predeclared "known" types
-	p.typList = append(p.typList, predeclared...)
-
-	// read package data
-	pkg := p.pkg()
+	// read package data; a package created here can be discarded
+	// if the import is interrupted (see ImportContext)
+	_, existed := imports[path]
+	pkg = p.pkg()
+	p.importList()
 
 	// read objects of phase 1 only (see cmd/compiler/internal/gc/bexport.go)
 	objcount := 0
@@ -94,13 +114,17 @@ func BImportData(fset *token.FileSet, imports map[string]*types.Package, data []
 		if tag == endTag {
 			break
 		}
+		if err := p.conf.interrupted(); err != nil && !existed {
+			delete(imports, path)
+			return p.read, nil, err
+		}
 		p.obj(tag)
 		objcount++
 	}
 
 	// self-verification
 	if count := p.int(); count != objcount {
-		panic(fmt.Sprintf("got %d objects; want %d", objcount, count))
+		p.formatErrorf("got %d objects; want %d", objcount, count)
 	}
 
 	// ignore compiler-specific import data
@@ -117,8 +141,26 @@ func BImportData(fset *token.FileSet, imports map[string]*types.Package, data []
 		}
 	}
 
-	// record all referenced packages as imports
-	list := append(([]*types.Package)(nil), p.pkgList[1:]...)
+	// declare the type names seen by OnType
+	for _, tname := range p.pending {
+		if typ := p.conf.onType()(tname.Type()); typ != tname.Type() {
+			tname = types.NewTypeName(tname.Pos(), tname.Pkg(), tname.Name(), typ)
+		}
+		pkg.Scope().Insert(tname)
+	}
+
+	// record all listed and referenced packages as imports, except
+	// for the cgo pseudo-package C recorded by earlier versions of
+	// BExportData (see importList); a package listed repeatedly
+	// appears in pkgList repeatedly
+	var list []*types.Package
+	seen := make(map[*types.Package]bool)
+	for _, pkg := range p.pkgList[1:] {
+		if pkg.Path() != "C" && !seen[pkg] {
+			seen[pkg] = true
+			list = append(list, pkg)
+		}
+	}
 	sort.Sort(byPath(list))
 	pkg.SetImports(list)
 
@@ -128,6 +170,183 @@ func BImportData(fset *token.FileSet, imports map[string]*types.Package, data []
 	return p.read, pkg, nil
 }
 
+// header reads the encoding format and version of the export data.
+func (p *importer) header() error {
+	// read low-level encoding format
+	switch format := p.rawByte(); format {
+	case 'c':
+		// compact format - nothing to do
+	case 'd':
+		p.debugFormat = true
+	default:
+		return &CorruptError{0, fmt.Sprintf("invalid encoding format in export data: got %q; want 'c' or 'd'", format)}
+	}
+
+	p.trackAllTypes = p.rawByte() == 'a'
+
+	p.posInfoFormat = p.int() != 0
+
+	// --- generic export data ---
+
+	version := p.string()
+	v, ok := versionNumber(version)
+	if !ok || v < minVersion || v > maxVersion {
+		return &VersionError{version, minVersion, maxVersion}
+	}
+	p.version = v
+
+	// populate typList with predeclared "known" types
+	p.typList = append(p.typList, predeclaredTypes(p.version)...)
+	return nil
+}
+
+// importList reads the list of packages imported by the package
+// (version 3 and later) and returns it. The cgo pseudo-package C,
+// which has no export data, is omitted. Paths listed more than once,
+// as written by some build setups, denote the same package and are
+// returned once.
+func (p *importer) importList() []*types.Package {
+	if p.version < 3 {
+		return nil
+	}
+	n := p.int()
+	if n < 0 || n > len(p.data) {
+		// each package takes at least a byte
+		p.formatErrorf("invalid package count %d", n)
+	}
+	list := make([]*types.Package, 0, n)
+	seen := make(map[*types.Package]bool, n)
+	for i := 0; i < n; i++ {
+		if pkg := p.pkg(); pkg.Path() != "C" && !seen[pkg] {
+			seen[pkg] = true
+			list = append(list, pkg)
+		}
+	}
+	return list
+}
+
+// bimportHeader returns the package name and, for version 3 and later,
+// the import paths recorded at the beginning of the binary export data,
+// without importing the package. For earlier versions, ok is false.
+func bimportHeader(data []byte) (name string, imports []string, ok bool, err error) {
+	p := importer{
+		conf:    new(Importer),
+		imports: make(map[string]*types.Package),
+		data:    data,
+		path:    "header",
+		strList: []string{""}, // empty string is mapped to 0
+	}
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+			// nothing to do
+		case formatError:
+			err = &CorruptError{int64(p.read), string(r)}
+		default:
+			err = fmt.Errorf("invalid export data header: %v", r)
+		}
+	}()
+
+	if err := p.header(); err != nil {
+		return "", nil, false, err
+	}
+	name = p.pkg().Name()
+	for _, pkg := range p.importList() {
+		imports = append(imports, pkg.Path())
+	}
+	return name, imports, p.version >= 3, nil
+}
+
+// bimportVersion returns the version recorded in the header of the
+// binary export data, even if BImportData does not support it.
+func bimportVersion(data []byte) (version int, err error) {
+	p := importer{
+		conf:    new(Importer),
+		imports: make(map[string]*types.Package),
+		data:    data,
+		path:    "header",
+		strList: []string{""}, // empty string is mapped to 0
+	}
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+			// nothing to do
+		case formatError:
+			err = &CorruptError{int64(p.read), string(r)}
+		default:
+			err = fmt.Errorf("invalid export data header: %v", r)
+		}
+	}()
+
+	if err := p.header(); err != nil {
+		if e, ok := err.(*VersionError); ok {
+			if v, ok := versionNumber(e.Version); ok {
+				return v, nil
+			}
+		}
+		return 0, err
+	}
+	return p.version, nil
+}
+
+// Range of binary export data versions ("v0", "v1", ...) supported by BImportData.
+const (
+	minVersion = 0
+	maxVersion = 3
+)
+
+// SupportedVersions returns the inclusive range of binary export data
+// format versions that BImportData can decode.
+func SupportedVersions() (min, max int) {
+	return minVersion, maxVersion
+}
+
+// A VersionError is returned by BImportData for export data written
+// in a format version outside the supported range, and by IImportData
+// for indexed export data of unsupported versions.
+type VersionError struct {
+	Version  string // version recorded in the export data, e.g. "v3"
+	Min, Max int    // supported versions; see SupportedVersions
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("unknown export data version: %s (want v%d to v%d)", e.Version, e.Min, e.Max)
+}
+
+// A formatError reports invalid export data detected by the importer.
+type formatError string
+
+func (e formatError) Error() string { return string(e) }
+
+func (p *importer) formatErrorf(format string, args ...interface{}) {
+	panic(formatError(fmt.Sprintf("invalid export data for %s: ", p.path) + fmt.Sprintf(format, args...)))
+}
+
+// ErrAliasCycle is reported, possibly wrapped, for export data with
+// alias declarations referring to themselves, directly or indirectly.
+// Such data is invalid since Go does not permit alias cycles.
+var ErrAliasCycle = errors.New("alias cycle in export data")
+
+// An aliasCycleError reports the aliases forming a cycle.
+type aliasCycleError struct {
+	names []string // in declaration order, starting and ending with the same alias
+}
+
+func (e *aliasCycleError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrAliasCycle, strings.Join(e.names, " -> "))
+}
+
+func (e *aliasCycleError) Is(target error) bool { return target == ErrAliasCycle }
+
+// versionNumber returns the number n of a version string "vn".
+func versionNumber(version string) (n int, ok bool) {
+	if !strings.HasPrefix(version, "v") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(version[1:])
+	return n, err == nil
+}
+
 func (p *importer) pkg() *types.Package {
 	// if the package was seen before, i is its index (>= 0)
 	i := p.tagOrIndex()
@@ -137,7 +356,7 @@ func (p *importer) pkg() *types.Package {
 
 	// otherwise, i is the package tag (< 0)
 	if i != packageTag {
-		panic(fmt.Sprintf("unexpected package tag %d", i))
+		p.formatErrorf("unexpected package tag %d", i)
 	}
 
 	// read package data
@@ -146,25 +365,37 @@ func (p *importer) pkg() *types.Package {
 
 	// we should never see an empty package name
 	if name == "" {
-		panic("empty package name in import")
+		p.formatErrorf("empty package name in import")
 	}
 
 	// an empty path denotes the package we are currently importing;
 	// it must be the first package we see
 	if (path == "") != (len(p.pkgList) == 0) {
-		panic(fmt.Sprintf("package path %q for pkg index %d", path, len(p.pkgList)))
+		p.formatErrorf("package path %q for pkg index %d", path, len(p.pkgList))
 	}
 
 	// if the package was imported before, use that one; otherwise create a new one
 	if path == "" {
 		path = p.path
 	}
+	if n, ok := p.conf.names()[path]; ok && path != "unsafe" {
+		name = n
+	}
 	pkg := p.imports[path]
 	if pkg == nil {
-		pkg = types.NewPackage(path, name)
-		p.imports[path] = pkg
-	} else if pkg.Name() != name {
-		panic(fmt.Sprintf("conflicting names %s and %s for package %q", pkg.Name(), name, path))
+		if path == "unsafe" {
+			pkg = types.Unsafe // appears in import lists only
+		} else {
+			if len(p.pkgList) > 0 {
+				pkg = p.conf.placeholder(path, name)
+			}
+			if pkg == nil {
+				pkg = types.NewPackage(path, name)
+			}
+			p.imports[path] = pkg
+		}
+	} else if pkg.Name() != name && !p.conf.isPlaceholder(pkg) {
+		p.formatErrorf("conflicting names %s and %s for package %q", pkg.Name(), name, path)
 	}
 	p.pkgList = append(p.pkgList, pkg)
 
@@ -173,6 +404,9 @@ func (p *importer) pkg() *types.Package {
 
 func (p *importer) declare(obj types.Object) {
 	pkg := obj.Pkg()
+	if p.redacted(pkg, obj.Name()) {
+		return
+	}
 	if alt := pkg.Scope().Insert(obj); alt != nil {
 		// This could only trigger if we import a (non-type) object a second time.
 		// This should never happen because 1) we only import a package once; and
@@ -181,8 +415,24 @@ func (p *importer) declare(obj types.Object) {
 		// imported.
 		// (See also the comment in cmd/compile/internal/gc/bimport.go importer.obj,
 		// switch case importing functions).
-		panic(fmt.Sprintf("inconsistent import:\n\t%v\npreviously imported as:\n\t%v\n", alt, obj))
+		p.formatErrorf("inconsistent import:\n\t%v\npreviously imported as:\n\t%v\n", alt, obj)
+	}
+}
+
+// intercepted reports whether the type name name of pkg is to be
+// passed to Importer.OnType before it is declared. This is the case
+// for the type names first declared by the imported package.
+func (p *importer) intercepted(pkg *types.Package, name string) bool {
+	if p.conf.onType() == nil || pkg != p.pkgList[0] {
+		return false
 	}
+	return p.hidden[name] != nil || pkg.Scope().Lookup(name) == nil
+}
+
+// redacted reports whether the object name of pkg is to be omitted
+// from the package scope.
+func (p *importer) redacted(pkg *types.Package, name string) bool {
+	return pkg == p.pkgList[0] && p.conf.redacts(name)
 }
 
 func (p *importer) obj(tag int) {
@@ -192,11 +442,31 @@ func (p *importer) obj(tag int) {
 		pkg, name := p.qualifiedName()
 		typ := p.typ(nil)
 		val := p.value()
+		if val == nil {
+			p.formatErrorf("constant %s has a value out of range", name)
+		}
+		if val.Kind() == constant.Unknown {
+			p.warnf("constant %s has unknown value", name)
+		}
 		p.declare(types.NewConst(pos, pkg, name, typ, val))
 
 	case typeTag:
 		_ = p.typ(nil)
 
+	case aliasTag:
+		pos := p.pos()
+		pkg, name := p.qualifiedName()
+		// the right-hand side must not refer to the alias itself
+		p.aliases = append(p.aliases, types.NewTypeName(pos, pkg, name, nil))
+		tparams := p.typeParamList(pkg, nil)
+		if len(tparams) > 0 && !genericAliases {
+			p.formatErrorf("generic alias %s requires go1.23", name)
+		}
+		rhs := p.typ(nil)
+		p.endTypeParams(len(tparams))
+		p.aliases = p.aliases[:len(p.aliases)-1]
+		p.declare(newAlias(pos, pkg, name, rhs, tparams))
+
 	case varTag:
 		pos := p.pos()
 		pkg, name := p.qualifiedName()
@@ -206,13 +476,15 @@ func (p *importer) obj(tag int) {
 	case funcTag:
 		pos := p.pos()
 		pkg, name := p.qualifiedName()
+		tparams := p.typeParamList(pkg, nil)
 		params, isddd := p.paramList()
 		result, _ := p.paramList()
-		sig := types.NewSignature(nil, params, result, isddd)
+		p.endTypeParams(len(tparams))
+		sig := newSignature(nil, nil, tparams, params, result, isddd)
 		p.declare(types.NewFunc(pos, pkg, name, sig))
 
 	default:
-		panic(fmt.Sprintf("unexpected object tag %d", tag))
+		p.formatErrorf("unexpected object tag %d", tag)
 	}
 }
 
@@ -235,26 +507,14 @@ func (p *importer) pos() token.Pos {
 	p.prevLine = line
 
 	// Synthesize a token.Pos
-
-	// Since we don't know the set of needed file positions, we
-	// reserve maxlines positions per file.
-	const maxlines = 64 * 1024
 	f := p.files[file]
 	if f == nil {
-		f = p.fset.AddFile(file, -1, maxlines)
+		f = fakeFile(p.fset, p.conf.posFilename(file))
 		p.files[file] = f
-		// Allocate the fake linebreak indices on first use.
-		// TODO(adonovan): opt: save ~512KB using a more complex scheme?
-		fakeLinesOnce.Do(func() {
-			fakeLines = make([]int, maxlines)
-			for i := range fakeLines {
-				fakeLines[i] = i
-			}
-		})
-		f.SetLines(fakeLines)
 	}
 
 	if line > maxlines {
+		p.warnf("%s:%d: line number too large; using line 1", file, line)
 		line = 1
 	}
 
@@ -263,21 +523,106 @@ func (p *importer) pos() token.Pos {
 	return f.Pos(line - 1)
 }
 
+// Since we don't know the set of needed file positions, we
+// reserve maxlines positions per file.
+const maxlines = 64 * 1024
+
+// fakeFile adds a file named filename to fset consisting of
+// maxlines empty lines, for synthesizing positions.
+func fakeFile(fset *token.FileSet, filename string) *token.File {
+	f := fset.AddFile(filename, -1, maxlines)
+	// Allocate the fake linebreak indices on first use.
+	// TODO(adonovan): opt: save ~512KB using a more complex scheme?
+	fakeLinesOnce.Do(func() {
+		fakeLines = make([]int, maxlines)
+		for i := range fakeLines {
+			fakeLines[i] = i
+		}
+	})
+	f.SetLines(fakeLines)
+	return f
+}
+
 var (
 	fakeLines     []int
 	fakeLinesOnce sync.Once
 )
 
+// warnf records a non-fatal problem with the export data
+// if the importer collects warnings.
+func (p *importer) warnf(format string, args ...interface{}) {
+	p.conf.warn(p.path, fmt.Sprintf(format, args...))
+}
+
 func (p *importer) qualifiedName() (pkg *types.Package, name string) {
 	name = p.string()
 	pkg = p.pkg()
 	return
 }
 
+// checkAliasCycle reports an alias cycle if the type name pkg.name
+// refers to an alias being declared.
+func (p *importer) checkAliasCycle(pkg *types.Package, name string) {
+	for i, alias := range p.aliases {
+		if alias.Pkg() == pkg && alias.Name() == name {
+			var names []string
+			for _, alias := range p.aliases[i:] {
+				names = append(names, alias.Name())
+			}
+			panic(&aliasCycleError{append(names, name)})
+		}
+	}
+}
+
 func (p *importer) record(t types.Type) {
 	p.typList = append(p.typList, t)
 }
 
+// typeParamList reads a list of type parameters declared in pkg
+// (version 2 and later) and brings them into scope; the caller must
+// call endTypeParams when leaving their scope. If named is not nil,
+// the type parameters are those of the generic type named.
+func (p *importer) typeParamList(pkg *types.Package, named *types.Named) []types.Type {
+	if p.version < 2 {
+		return nil
+	}
+	n := p.int()
+	if n == 0 {
+		return nil
+	}
+	if n < 0 || n > len(p.data) {
+		// each type parameter takes at least a byte
+		p.formatErrorf("invalid type parameter count %d", n)
+	}
+
+	tparams := make([]types.Type, n)
+	for i := range tparams {
+		pos := p.pos()
+		name := p.string()
+		tparams[i] = newTypeParam(types.NewTypeName(pos, pkg, name, nil))
+	}
+	if named != nil {
+		setTypeParams(named, tparams)
+	}
+
+	// constraints may refer to the type parameters themselves
+	p.tparams = append(p.tparams, tparams...)
+	for _, tp := range tparams {
+		constraint := p.typ(pkg)
+		if constraint == nil || !types.IsInterface(constraint) {
+			// go/types requires an interface constraint; default to any
+			p.warnf("type parameter %s has invalid constraint %v", tp, constraint)
+			constraint = types.NewInterface(nil, nil).Complete()
+		}
+		setConstraint(tp, constraint)
+	}
+	return tparams
+}
+
+func (p *importer) endTypeParams(n int) {
+	p.tparams = p.tparams[:len(p.tparams)-n]
+}
+
 // A dddSlice is a types.Type representing ...T parameters.
 // It only appears for parameter types and does not escape
 // the importer.
@@ -296,6 +641,9 @@ func (p *importer) typ(parent *types.Package) types.Type {
 	// if the type was seen before, i is its index (>= 0)
 	i := p.tagOrIndex()
 	if i >= 0 {
+		if i >= len(p.typList) {
+			p.formatErrorf("invalid type index %d", i)
+		}
 		return p.typList[i]
 	}
 
@@ -305,17 +653,31 @@ func (p *importer) typ(parent *types.Package) types.Type {
 		// read type object
 		pos := p.pos()
 		parent, name := p.qualifiedName()
-		scope := parent.Scope()
-		obj := scope.Lookup(name)
-
-		// if the object doesn't exist yet, create and insert it
-		if obj == nil {
+		p.checkAliasCycle(parent, name)
+		var obj types.Object
+		if redacted := p.redacted(parent, name); redacted || p.intercepted(parent, name) {
+			// keep redacted type names out of the scope, and
+			// declare the others once OnType has seen them
+			tname := p.hidden[name]
+			if tname == nil {
+				if p.hidden == nil {
+					p.hidden = make(map[string]*types.TypeName)
+				}
+				tname = types.NewTypeName(pos, parent, name, nil)
+				p.hidden[name] = tname
+				if !redacted {
+					p.pending = append(p.pending, tname)
+				}
+			}
+			obj = tname
+		} else if obj = parent.Scope().Lookup(name); obj == nil {
+			// if the object doesn't exist yet, create and insert it
 			obj = types.NewTypeName(pos, parent, name, nil)
-			scope.Insert(obj)
+			parent.Scope().Insert(obj)
 		}
 
 		if _, ok := obj.(*types.TypeName); !ok {
-			panic(fmt.Sprintf("pkg = %s, name = %s => %s", parent, name, obj))
+			p.formatErrorf("pkg = %s, name = %s => %s", parent, name, obj)
 		}
 
 		// associate new named type with obj if it doesn't exist yet
@@ -325,8 +687,14 @@ func (p *importer) typ(parent *types.Package) types.Type {
 		t := obj.Type().(*types.Named)
 		p.record(t)
 
+		tparams := p.typeParamList(parent, t0)
+		defer p.endTypeParams(len(tparams))
+
 		// read underlying type
 		t0.SetUnderlying(p.typ(parent))
+		if t0.Underlying() == types.Typ[types.Invalid] {
+			p.warnf("type %s has invalid underlying type", name)
+		}
 
 		// interfaces don't have associated methods
 		if types.IsInterface(t0) {
@@ -342,15 +710,18 @@ func (p *importer) typ(parent *types.Package) types.Type {
 				p.pkg()
 			}
 
-			recv, _ := p.paramList() // TODO(gri) do we need a full param list for the receiver?
+			rparams := p.typeParamList(parent, nil)
+			recv, _ := p.paramList()                // TODO(gri) do we need a full param list for the receiver?
+			mparams := p.typeParamList(parent, nil) // generic methods
 			params, isddd := p.paramList()
 			result, _ := p.paramList()
 
-			if p.version == "v1" {
+			if p.version >= 1 {
 				p.int() // nointerface flag - discarded
 			}
+			p.endTypeParams(len(rparams) + len(mparams))
 
-			sig := types.NewSignature(recv.At(0), params, result, isddd)
+			sig := newSignature(recv.At(0), rparams, mparams, params, result, isddd)
 			t0.AddMethod(types.NewFunc(pos, parent, name, sig))
 		}
 
@@ -423,12 +794,19 @@ func (p *importer) typ(parent *types.Package) types.Type {
 			p.record(nil)
 		}
 
-		// no embedded interfaces with gc compiler
-		if p.int() != 0 {
-			panic("unexpected embedded interface")
+		var implicit bool
+		var embeddeds []types.Type
+		if p.version >= 2 {
+			implicit = p.bool()
+			for i := p.int(); i > 0; i-- {
+				embeddeds = append(embeddeds, p.typ(parent))
+			}
+		} else if p.int() != 0 {
+			// no embedded interfaces with gc compiler
+			p.formatErrorf("unexpected embedded interface")
 		}
 
-		t := types.NewInterface(p.methodList(parent), nil)
+		t := newInterface(p.methodList(parent), embeddeds, implicit)
 		if p.trackAllTypes {
 			p.typList[n] = t
 		}
@@ -461,15 +839,41 @@ func (p *importer) typ(parent *types.Package) types.Type {
 		case 3 /* Cboth */ :
 			dir = types.SendRecv
 		default:
-			panic(fmt.Sprintf("unexpected channel dir %d", d))
+			p.formatErrorf("unexpected channel dir %d", d)
 		}
 		val := p.typ(parent)
 		*t = *types.NewChan(dir, val)
 		return t
 
+	case typeParamTag:
+		i := p.int()
+		if i < 0 || i >= len(p.tparams) {
+			p.formatErrorf("invalid type parameter index %d", i)
+		}
+		return p.tparams[i]
+
+	case instanceTag:
+		origin := p.typ(parent)
+		targs := make([]types.Type, p.int())
+		for i := range targs {
+			targs[i] = p.typ(parent)
+		}
+		return instantiate(origin, targs)
+
+	case unionTag:
+		n := p.int()
+		terms := make([]types.Type, n)
+		tilde := make([]bool, n)
+		for i := range terms {
+			tilde[i] = p.bool()
+			terms[i] = p.typ(parent)
+		}
+		return newUnion(terms, tilde)
+
 	default:
-		panic(fmt.Sprintf("unexpected type tag %d", i))
+		p.formatErrorf("unexpected type tag %d", i)
 	}
+	panic("unreachable")
 }
 
 func (p *importer) fieldList(parent *types.Package) (fields []*types.Var, tags []string) {
@@ -499,7 +903,7 @@ func (p *importer) field(parent *types.Package) *types.Var {
 		case *types.Named:
 			name = typ.Obj().Name()
 		default:
-			panic("anonymous field expected")
+			p.formatErrorf("anonymous field expected")
 		}
 		anonymous = true
 	}
@@ -578,7 +982,7 @@ func (p *importer) param(named bool) (*types.Var, bool) {
 	if named {
 		name = p.string()
 		if name == "" {
-			panic("expected named parameter")
+			p.formatErrorf("expected named parameter")
 		}
 		if name != "_" {
 			pkg = p.pkg()
@@ -586,6 +990,15 @@ func (p *importer) param(named bool) (*types.Var, bool) {
 		if i := strings.Index(name, "·"); i > 0 {
 			name = name[:i] // cut off gc-specific parameter numbering
 		}
+		// gc names unnamed results "~rN" and blank parameters "~bN";
+		// restore the names as written in the source
+		switch {
+		case strings.HasPrefix(name, "~r"):
+			pkg = nil
+			name = ""
+		case strings.HasPrefix(name, "~b"):
+			name = "_"
+		}
 	}
 
 	// read and discard compiler-specific info
@@ -612,16 +1025,28 @@ func (p *importer) value() constant.Value {
 	case complexTag:
 		re := p.float()
 		im := p.float()
+		if re == nil || im == nil {
+			return nil
+		}
 		return constant.BinaryOp(re, token.ADD, constant.MakeImag(im))
 	case stringTag:
 		return constant.MakeString(p.string())
 	case unknownTag:
 		return constant.MakeUnknown()
 	default:
-		panic(fmt.Sprintf("unexpected value tag %d", tag))
+		p.formatErrorf("unexpected value tag %d", tag)
 	}
+	panic("unreachable")
 }
 
+// maxFloatExp bounds the binary exponent of floating-point values.
+// It is far beyond the range of constants accepted by the compilers
+// and avoids huge allocations for corrupt or doctored export data,
+// which is also how infinities would have to be represented.
+const maxFloatExp = 1 << 16
+
+// float returns the floating-point value read, or nil if its exponent
+// is out of range.
 func (p *importer) float() constant.Value {
 	sign := p.int()
 	if sign == 0 {
@@ -630,6 +1055,9 @@ func (p *importer) float() constant.Value {
 
 	exp := p.int()
 	mant := []byte(p.string()) // big endian
+	if exp < -maxFloatExp || exp > maxFloatExp {
+		return nil
+	}
 
 	// remove leading 0's if any
 	for len(mant) > 0 && mant[0] == 0 {
@@ -678,10 +1106,14 @@ func (p *importer) tagOrIndex() int {
 	return int(p.rawInt64())
 }
 
+func (p *importer) bool() bool {
+	return p.int() != 0
+}
+
 func (p *importer) int() int {
 	x := p.int64()
 	if int64(int(x)) != x {
-		panic("exported integer too large")
+		p.formatErrorf("exported integer too large")
 	}
 	return int(x)
 }
@@ -702,9 +1134,16 @@ func (p *importer) string() string {
 	// (the empty string is at index 0)
 	i := p.rawInt64()
 	if i >= 0 {
+		if i >= int64(len(p.strList)) {
+			p.formatErrorf("invalid string index %d", i)
+		}
 		return p.strList[i]
 	}
-	// otherwise, i is the negative string length (< 0)
+	// otherwise, i is the negative string length (< 0);
+	// the bytes are copied as written, escapes aside (see rawByte)
+	if i < -int64(len(p.data)) {
+		p.formatErrorf("string length %d exceeds export data", -i)
+	}
 	if n := int(-i); n <= cap(p.buf) {
 		p.buf = p.buf[:n]
 	} else {
@@ -720,12 +1159,12 @@ func (p *importer) string() string {
 
 func (p *importer) marker(want byte) {
 	if got := p.rawByte(); got != want {
-		panic(fmt.Sprintf("incorrect marker: got %c; want %c (pos = %d)", got, want, p.read))
+		p.formatErrorf("incorrect marker: got %c; want %c (pos = %d)", got, want, p.read)
 	}
 
 	pos := p.read
 	if n := int(p.rawInt64()); n != pos {
-		panic(fmt.Sprintf("incorrect position: got %d; want %d", n, pos))
+		p.formatErrorf("incorrect position: got %d; want %d", n, pos)
 	}
 }
 
@@ -733,7 +1172,7 @@ func (p *importer) marker(want byte) {
 func (p *importer) rawInt64() int64 {
 	i, err := binary.ReadVarint(p)
 	if err != nil {
-		panic(fmt.Sprintf("read error: %v", err))
+		p.formatErrorf("read error: %v", err)
 	}
 	return i
 }
@@ -747,9 +1186,15 @@ func (p *importer) ReadByte() (byte, error) {
 // It unescapes '|' 'S' to '$' and '|' '|' to '|'.
 // rawByte should only be used by low-level decoders.
 func (p *importer) rawByte() byte {
+	if len(p.data) == 0 {
+		p.formatErrorf("unexpected end of export data")
+	}
 	b := p.data[0]
 	r := 1
 	if b == '|' {
+		if len(p.data) < 2 {
+			p.formatErrorf("unexpected end of export data")
+		}
 		b = p.data[1]
 		r = 2
 		switch b {
@@ -758,7 +1203,7 @@ func (p *importer) rawByte() byte {
 		case '|':
 			// nothing to do
 		default:
-			panic("unexpected escape sequence in export data")
+			p.formatErrorf("unexpected escape sequence in export data")
 		}
 	}
 	p.data = p.data[r:]
@@ -801,6 +1246,12 @@ const (
 	complexTag
 	stringTag
 	unknownTag // not used by gc (only appears in packages with errors)
+
+	// Type parameters and aliases (version 2 and later)
+	typeParamTag
+	instanceTag
+	unionTag
+	aliasTag // object
 )
 
 var predeclared = []types.Type{
@@ -849,6 +1300,15 @@ var predeclared = []types.Type{
 	anyType{},
 }
 
+// predeclaredTypes returns the predeclared types for the given
+// export data version.
+func predeclaredTypes(version int) []types.Type {
+	if version < 2 {
+		return predeclared
+	}
+	return append(predeclared[:len(predeclared):len(predeclared)], predeclared18...)
+}
+
 type anyType struct{}
 
 func (t anyType) Underlying() types.Type { return t }
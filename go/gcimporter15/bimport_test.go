@@ -0,0 +1,743 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.6
+
+package gcimporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	goparser "go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// typecheck parses and type-checks the package path from src.
+// Imports are satisfied from the deps packages.
+func typecheck(t testing.TB, fset *token.FileSet, path, src string, deps ...*types.Package) *types.Package {
+	f, err := goparser.ParseFile(fset, path+".go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: depsImporter(deps)}
+	pkg, err := conf.Check(path, fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkg
+}
+
+type depsImporter []*types.Package
+
+func (deps depsImporter) Import(path string) (*types.Package, error) {
+	for _, pkg := range deps {
+		if pkg.Path() == path {
+			return pkg, nil
+		}
+	}
+	return nil, fmt.Errorf("can't find import: %s", path)
+}
+
+// exportSource returns the binary export data for the package
+// path type-checked from src.
+func exportSource(t *testing.T, path, src string, deps ...*types.Package) []byte {
+	fset := token.NewFileSet()
+	return BExportData(fset, typecheck(t, fset, path, src, deps...))
+}
+
+// objectFile returns the contents of a gc object file for the given
+// architecture holding the binary export data data.
+func objectFile(goarch string, data []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "go object %s %s go1.7 X:none\n", runtime.GOOS, goarch)
+	buf.WriteString("\n$$B\n")
+	buf.Write(data)
+	buf.WriteString("\n$$\n")
+	return buf.Bytes()
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "gcimporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// writeObject writes an object file for the host architecture holding
+// data to dir/name.o, from where it can be imported as "./name".
+func writeObject(t testing.TB, dir, name string, data []byte) {
+	filename := filepath.Join(dir, name+".o")
+	if err := ioutil.WriteFile(filename, objectFile(runtime.GOARCH, data), 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// bimport imports the binary export data for path into a fresh packages map.
+func bimport(t *testing.T, data []byte, path string) *types.Package {
+	_, pkg, err := BImportData(token.NewFileSet(), make(map[string]*types.Package), data, path)
+	if err != nil {
+		t.Fatalf("BImportData(%s): %v", path, err)
+	}
+	return pkg
+}
+
+func TestBinaryResultNames(t *testing.T) {
+	const src = `package p
+type T struct{}
+func (t T) Read(p []byte) (n int, err error) { return }
+func Named() (n int, err error) { return }
+func Blank() (_ int, err error) { return }
+func Unnamed() (int, error) { return 0, nil }
+`
+	pkg := bimport(t, exportSource(t, "p", src), "p")
+	for _, test := range resultNameTests {
+		got := fmt.Sprint(resultNames(t, pkg, test.sel))
+		if got != test.want {
+			t.Errorf("%s results: got %s; want %s", test.sel, got, test.want)
+		}
+	}
+
+	// gc names unnamed results ~rN and blank ones ~bN in its export data
+	pkg = types.NewPackage("q", "q")
+	result := func(names ...string) *types.Tuple {
+		var vars []*types.Var
+		for _, name := range names {
+			vars = append(vars, types.NewVar(token.NoPos, pkg, name, types.Typ[types.Int]))
+		}
+		return types.NewTuple(vars...)
+	}
+	for name, res := range map[string]*types.Tuple{
+		"Named":   result("n", "m"),
+		"Blank":   result("~b0", "m"),
+		"Unnamed": result("~r0", "~r1"),
+	} {
+		pkg.Scope().Insert(types.NewFunc(token.NoPos, pkg, name, types.NewSignature(nil, nil, res, false)))
+	}
+	pkg = bimport(t, BExportData(nil, pkg), "q")
+	for _, test := range []struct {
+		sel  string
+		want string
+	}{
+		{"Named", "[n m]"},
+		{"Blank", "[_ m]"},
+		{"Unnamed", "[ ]"},
+	} {
+		got := fmt.Sprint(resultNames(t, pkg, test.sel))
+		if got != test.want {
+			t.Errorf("compiler-named %s results: got %s; want %s", test.sel, got, test.want)
+		}
+	}
+}
+
+func TestCrossPackageResults(t *testing.T) {
+	fset := token.NewFileSet()
+	tok := typecheck(t, fset, "go/token", "package token; type Pos int; type FileSet struct{}")
+	ast := typecheck(t, fset, "go/ast", `package ast
+import "go/token"
+type File struct{ Package token.Pos }
+`, tok)
+	// the package name differs from the last element of its path
+	ver := typecheck(t, fset, "example.com/v2", "package version; type Version struct{}")
+	const src = `package p
+import (
+	"go/ast"
+	"go/token"
+	"example.com/v2"
+)
+func Parse() (*ast.File, error) { return nil, nil }
+func Position() (pos token.Pos, fset *token.FileSet, ok bool) { return }
+func Versions() ([]version.Version, map[string]*ast.File) { return nil, nil }
+`
+	pkg := bimport(t, BExportData(fset, typecheck(t, fset, "p", src, tok, ast, ver)), "p")
+
+	type result struct {
+		typ, pkgPath, pkgName string // pkgPath and pkgName describe the named type in typ
+	}
+	for _, test := range []struct {
+		fun  string
+		want []result
+	}{
+		{"Parse", []result{{"*go/ast.File", "go/ast", "ast"}, {"error", "", ""}}},
+		{"Position", []result{{"go/token.Pos", "go/token", "token"}, {"*go/token.FileSet", "go/token", "token"}, {"bool", "", ""}}},
+		{"Versions", []result{{"[]example.com/v2.Version", "example.com/v2", "version"}, {"map[string]*go/ast.File", "go/ast", "ast"}}},
+	} {
+		res := pkg.Scope().Lookup(test.fun).Type().(*types.Signature).Results()
+		if res.Len() != len(test.want) {
+			t.Errorf("%s: got %d results; want %d", test.fun, res.Len(), len(test.want))
+			continue
+		}
+		for i, want := range test.want {
+			typ := res.At(i).Type()
+			if got := typ.String(); got != want.typ {
+				t.Errorf("%s result %d: got type %s; want %s", test.fun, i, got, want.typ)
+			}
+			named := namedOf(typ)
+			if named == nil || named.Obj().Pkg() == nil {
+				if want.pkgPath != "" {
+					t.Errorf("%s result %d: no named type of package %s in %s", test.fun, i, want.pkgPath, typ)
+				}
+				continue
+			}
+			if p := named.Obj().Pkg(); p.Path() != want.pkgPath || p.Name() != want.pkgName {
+				t.Errorf("%s result %d: %s declared in package %q (%s); want %q (%s)", test.fun, i, named, p.Name(), p.Path(), want.pkgName, want.pkgPath)
+			}
+		}
+	}
+
+	// the result types of different functions are identical
+	parse := pkg.Scope().Lookup("Parse").Type().(*types.Signature).Results().At(0).Type()
+	versions := pkg.Scope().Lookup("Versions").Type().(*types.Signature).Results().At(1).Type()
+	if elem := versions.(*types.Map).Elem(); !types.Identical(parse, elem) {
+		t.Errorf("result types %s and %s are not identical", parse, elem)
+	}
+}
+
+// namedOf returns the named type in typ, looking through pointers,
+// slices, and map elements, or nil if there is none.
+func namedOf(typ types.Type) *types.Named {
+	for {
+		switch t := typ.(type) {
+		case *types.Named:
+			return t
+		case *types.Pointer:
+			typ = t.Elem()
+		case *types.Slice:
+			typ = t.Elem()
+		case *types.Map:
+			typ = t.Elem()
+		default:
+			return nil
+		}
+	}
+}
+
+// objectStrings returns a description of each exported object of pkg,
+// including the methods of named types and, if fset is not nil, the
+// declaring file and line.
+func objectStrings(fset *token.FileSet, pkg *types.Package) []string {
+	var list []string
+	add := func(obj types.Object) {
+		s := types.ObjectString(obj, nil)
+		if fset != nil {
+			posn := fset.Position(obj.Pos())
+			s = fmt.Sprintf("%s:%d: %s", posn.Filename, posn.Line, s)
+		}
+		list = append(list, s)
+	}
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+		add(obj)
+		if named, ok := obj.Type().(*types.Named); ok {
+			for i := 0; i < named.NumMethods(); i++ {
+				add(named.Method(i))
+			}
+		}
+	}
+	return list
+}
+
+func TestReexport(t *testing.T) {
+	const srcQ = `package q
+type Node struct {
+	Name string
+	Kids []*Node
+}
+func (n *Node) Walk(f func(*Node) bool) {}
+type Sealed interface {
+	Get() int
+	seal()
+}
+`
+	const srcP = `package p
+import "q"
+const (
+	Big = 1 << 100
+	Pi  = 3.14159265358979323846264338327950288
+	S   = "$$|"
+)
+type T struct {
+	q.Node
+	x    int
+	Tags string ` + "`json:\"tags,omitempty\"`" + `
+	C    <-chan map[string][]*q.Node
+	A    [4]byte
+}
+func (t *T) Sum(xs ...int) (n int, err error) { return }
+func (T) value() {}
+type I interface {
+	q.Sealed
+	Sum(...int) (int, error)
+}
+var V I
+func F(a, b int, _ string) (q.Sealed, error) { return nil, nil }
+`
+	fset0 := token.NewFileSet()
+	q := typecheck(t, fset0, "q", srcQ)
+	p := typecheck(t, fset0, "p", srcP, q)
+
+	// import
+	fset1 := token.NewFileSet()
+	_, p1, err := BImportData(fset1, make(map[string]*types.Package), BExportData(fset0, p), "p")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// re-export the imported package and import it again
+	data := BExportData(fset1, p1)
+	fset2 := token.NewFileSet()
+	_, p2, err := BImportData(fset2, make(map[string]*types.Package), data, "p")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := fmt.Sprint(objectStrings(fset2, p2))
+	want := fmt.Sprint(objectStrings(fset1, p1))
+	if got != want {
+		t.Errorf("re-imported package differs:\ngot  %s\nwant %s", got, want)
+	}
+	if !bytes.Equal(BExportData(fset2, p2), data) {
+		t.Errorf("export data of re-imported package differs")
+	}
+
+	// embedded fields must survive the round trip
+	T := p2.Scope().Lookup("T").Type().Underlying().(*types.Struct)
+	if f := T.Field(0); !f.Anonymous() || f.Name() != "Node" {
+		t.Errorf("got field %s; want embedded field q.Node", f)
+	}
+}
+
+func TestReexportTextual(t *testing.T) {
+	pkg, err := ImportData(make(map[string]*types.Package), "p.o", "p", strings.NewReader(resultNamesSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg = bimport(t, BExportData(nil, pkg), "p")
+	for _, test := range resultNameTests {
+		got := fmt.Sprint(resultNames(t, pkg, test.sel))
+		if got != test.want {
+			t.Errorf("%s results: got %s; want %s", test.sel, got, test.want)
+		}
+	}
+}
+
+// nestedTypes are deeply nested composite types around the named type
+// Foo, written as printed by types.TypeString relative to their package.
+var nestedTypes = []string{
+	"[]map[string][]*Foo",
+	"map[[2]*Foo][]chan Foo",
+	"*[3][]map[string]*Foo",
+	"[][][][]Foo",
+	"chan<- []*Foo",
+	"<-chan map[Foo][]Foo",
+	"chan (<-chan *Foo)",
+	"chan<- chan<- Foo",
+	"[]func(x []*Foo) (m map[string]Foo)",
+	"map[string]*[4]chan<- []<-chan map[int]*Foo", // all composite kinds
+	"struct{X []map[string]*Foo; Y *[1]chan Foo}",
+}
+
+func TestNestedComposites(t *testing.T) {
+	// binary export data
+	var src bytes.Buffer
+	src.WriteString("package p\ntype Foo struct{}\n")
+	for i, typ := range nestedTypes {
+		fmt.Fprintf(&src, "var V%d %s\n", i, typ)
+	}
+	check := func(format string, pkg *types.Package) {
+		for i, want := range nestedTypes {
+			obj := pkg.Scope().Lookup(fmt.Sprintf("V%d", i))
+			if obj == nil {
+				t.Errorf("%s: V%d not found", format, i)
+				continue
+			}
+			if got := types.TypeString(obj.Type(), types.RelativeTo(pkg)); got != want {
+				t.Errorf("%s: V%d has type %s; want %s", format, i, got, want)
+			}
+		}
+	}
+	check("binary", bimport(t, exportSource(t, "p", src.String()), "p"))
+
+	// textual export data
+	var textual bytes.Buffer
+	textual.WriteString("package p\ntype @\"\".Foo struct {}\n")
+	for i, typ := range nestedTypes {
+		typ = strings.Replace(typ, "Foo", `@"".Foo`, -1)
+		typ = strings.Replace(typ, "X ", `@"".X `, -1)
+		typ = strings.Replace(typ, "Y ", `@"".Y `, -1)
+		fmt.Fprintf(&textual, "var @\"\".V%d %s\n", i, typ)
+	}
+	textual.WriteString("$$\n")
+	pkg, err := ImportData(make(map[string]*types.Package), "p.o", "p", &textual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("textual", pkg)
+}
+
+func TestStructFieldOrder(t *testing.T) {
+	// fields in neither alphabetical nor size order
+	want := []string{"Zeta int32", "alpha string", "Mid string", "Foo *Foo", "b []byte `json:\"b\"`", "Alpha bool", "_ int8"}
+
+	bin := bimport(t, exportSource(t, "p", "package p\ntype Foo struct{}\ntype S struct {\n"+
+		"\tZeta int32\n\talpha, Mid string\n\t*Foo\n\tb []byte `json:\"b\"`\n\tAlpha bool\n\t_ int8\n}\n"), "p")
+	text, err := ImportData(make(map[string]*types.Package), "p.o", "p", strings.NewReader(`package p
+type @"".Foo struct {}
+type @"".S struct { Zeta int32; @"".alpha string; Mid string; ? *@"".Foo; @"".b []byte "json:\"b\""; Alpha bool; @""._ int8 }
+$$
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sizes := &types.StdSizes{WordSize: 8, MaxAlign: 8}
+	var offsets [][]int64
+	for _, pkg := range []*types.Package{bin, text} {
+		st := pkg.Scope().Lookup("S").Type().Underlying().(*types.Struct)
+		if st.NumFields() != len(want) {
+			t.Fatalf("%s has %d fields; want %d", st, st.NumFields(), len(want))
+		}
+		var fields []*types.Var
+		for i := 0; i < st.NumFields(); i++ {
+			f := st.Field(i)
+			got := f.Name() + " " + types.TypeString(f.Type(), types.RelativeTo(pkg))
+			if tag := st.Tag(i); tag != "" {
+				got += " `" + tag + "`"
+			}
+			if got != want[i] {
+				t.Errorf("field %d of %s is %s; want %s", i, st, got, want[i])
+			}
+			fields = append(fields, f)
+		}
+		offsets = append(offsets, sizes.Offsetsof(fields))
+	}
+	if fmt.Sprint(offsets[0]) != fmt.Sprint(offsets[1]) {
+		t.Errorf("field offsets differ: binary %v, textual %v", offsets[0], offsets[1])
+	}
+}
+
+func TestSupportedVersions(t *testing.T) {
+	min, max := SupportedVersions()
+	if min > max {
+		t.Fatalf("SupportedVersions() = %d, %d", min, max)
+	}
+
+	fset := token.NewFileSet()
+	pkg := typecheck(t, fset, "p", "package p; const C = 0; type T int; func (T) M() {}")
+	for v := min; v <= max; v++ {
+		if _, _, err := BImportData(token.NewFileSet(), make(map[string]*types.Package), bexportData(fset, pkg, v, true), "p"); err != nil {
+			t.Errorf("version v%d: %v", v, err)
+		}
+	}
+
+	data := bytes.Replace(BExportData(fset, pkg), []byte(fmt.Sprintf("v%d", exportVersion)), []byte(fmt.Sprintf("v%d", max+1)), 1)
+	_, _, err := BImportData(token.NewFileSet(), make(map[string]*types.Package), data, "p")
+	verr, ok := err.(*VersionError)
+	if !ok {
+		t.Fatalf("version v%d: got error %v; want *VersionError", max+1, err)
+	}
+	if verr.Max != max || verr.Version != fmt.Sprintf("v%d", max+1) {
+		t.Errorf("got %+v; want Version v%d, Max %d", verr, max+1, max)
+	}
+}
+
+func TestBExportDataNoPos(t *testing.T) {
+	const src = `package p
+type T struct{ X int }
+func (T) M() {}
+var V T
+`
+	var pos, nopos [][]byte
+	for _, filename := range []string{"/a/p.go", "/b/c/renamed.go"} {
+		fset := token.NewFileSet()
+		f, err := goparser.ParseFile(fset, filename, src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg, err := new(types.Config).Check("p", fset, []*ast.File{f}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pos = append(pos, BExportData(fset, pkg))
+		nopos = append(nopos, BExportDataNoPos(pkg))
+	}
+
+	if bytes.Equal(pos[0], pos[1]) {
+		t.Errorf("export data with positions does not depend on file names")
+	}
+	if !bytes.Equal(nopos[0], nopos[1]) {
+		t.Errorf("export data without positions depends on file names")
+	}
+
+	pkg := bimport(t, nopos[0], "p")
+	if obj := pkg.Scope().Lookup("V"); obj.Pos().IsValid() {
+		t.Errorf("%s has position %d", obj, obj.Pos())
+	}
+}
+
+func TestFloatOutOfRange(t *testing.T) {
+	// A float constant is encoded as sign, binary exponent, and mantissa.
+	// Replace the exponent of 0x1p1000 by one so large that the value
+	// is effectively infinite.
+	data := exportSource(t, "p", "package p; const Huge float64 = 0x1p1000")
+	enc := func(sign, exp int64) []byte {
+		buf := make([]byte, 2*binary.MaxVarintLen64)
+		n := binary.PutVarint(buf, sign)
+		n += binary.PutVarint(buf[n:], exp)
+		return buf[:n]
+	}
+	if _, pkg, err := BImportData(token.NewFileSet(), make(map[string]*types.Package), data, "p"); err != nil || pkg.Scope().Lookup("Huge") == nil {
+		t.Fatalf("import of valid data failed: %v", err)
+	}
+	if bytes.Count(data, enc(1, 1001)) != 1 {
+		t.Fatalf("cannot locate float encoding in export data")
+	}
+	bad := bytes.Replace(data, enc(1, 1001), enc(1, 1<<40), 1)
+
+	_, _, err := BImportData(token.NewFileSet(), make(map[string]*types.Package), bad, "p")
+	if err == nil || !strings.Contains(err.Error(), "constant Huge") {
+		t.Errorf("got error %v; want error for constant Huge", err)
+	}
+}
+
+func TestInvalidPackageCount(t *testing.T) {
+	var buf [binary.MaxVarintLen64]byte
+	p := importer{conf: new(Importer), data: buf[:binary.PutVarint(buf[:], 1<<40)], path: "p", version: 3}
+	defer func() {
+		if _, ok := recover().(formatError); !ok {
+			t.Errorf("no format error")
+		}
+	}()
+	p.importList()
+}
+
+func TestErrorMethodPackage(t *testing.T) {
+	const srcQ = `package q
+type Err struct{ Msg string }
+func (e *Err) Error() string { return e.Msg }
+type Coded interface {
+	error
+	Code() int
+}
+`
+	const srcP = `package p
+import "q"
+type Wrap struct{ *q.Err }
+func F() (*q.Err, q.Coded) { return nil, nil }
+`
+	fset := token.NewFileSet()
+	q := typecheck(t, fset, "q", srcQ)
+	p := typecheck(t, fset, "p", srcP, q)
+
+	// q is only partially imported through p
+	packages := make(map[string]*types.Package)
+	if _, _, err := BImportData(token.NewFileSet(), packages, BExportData(fset, p), "p"); err != nil {
+		t.Fatal(err)
+	}
+	for _, qpkg := range []*types.Package{packages["q"], bimport(t, BExportData(fset, q), "q")} {
+		T := qpkg.Scope().Lookup("Err").Type()
+		obj, _, _ := types.LookupFieldOrMethod(T, true, nil, "Error")
+		if obj == nil || obj.Pkg() == nil || obj.Pkg().Path() != "q" {
+			t.Errorf("method Error of %s has package %v; want q", T, obj)
+		}
+		if !types.Implements(types.NewPointer(T), types.Universe.Lookup("error").Type().Underlying().(*types.Interface)) {
+			t.Errorf("*%s does not implement error", T)
+		}
+		I := qpkg.Scope().Lookup("Coded").Type().Underlying().(*types.Interface)
+		if m := I.ExplicitMethod(0); m.Pkg() == nil || m.Pkg().Path() != "q" {
+			t.Errorf("method Code of %s has package %v; want q", I, m.Pkg())
+		}
+	}
+
+	// textual export data
+	const src = `package q
+type @"".Err struct { @"".Msg string }
+func (@"".e *@"".Err) Error() (? string)
+$$
+`
+	qpkg, err := ImportData(make(map[string]*types.Package), "q.o", "q", strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, _, _ := types.LookupFieldOrMethod(qpkg.Scope().Lookup("Err").Type(), true, nil, "Error")
+	if obj == nil || obj.Pkg() != qpkg {
+		t.Errorf("method Error of textual q.Err has package %v; want q", obj)
+	}
+}
+
+func TestEmbeddedPointerMethodSets(t *testing.T) {
+	const srcQ = `package q
+type Base struct{}
+func (Base) Value()    {}
+func (*Base) Pointer() {}
+`
+	const srcP = `package p
+import "q"
+type ByValue struct{ q.Base }
+type ByPointer struct{ *q.Base }
+`
+	fset := token.NewFileSet()
+	q := typecheck(t, fset, "q", srcQ)
+	p := bimport(t, BExportData(fset, typecheck(t, fset, "p", srcP, q)), "p")
+
+	methods := func(T types.Type) string {
+		mset := types.NewMethodSet(T)
+		var names []string
+		for i := 0; i < mset.Len(); i++ {
+			names = append(names, mset.At(i).Obj().Name())
+		}
+		return fmt.Sprint(names)
+	}
+	for _, test := range []struct {
+		name     string
+		val, ptr string // method sets of T and *T
+	}{
+		{"ByValue", "[Value]", "[Pointer Value]"},
+		{"ByPointer", "[Pointer Value]", "[Pointer Value]"},
+	} {
+		T := p.Scope().Lookup(test.name).Type()
+		if got := methods(T); got != test.val {
+			t.Errorf("method set of %s = %s; want %s", T, got, test.val)
+		}
+		if got := methods(types.NewPointer(T)); got != test.ptr {
+			t.Errorf("method set of *%s = %s; want %s", T, got, test.ptr)
+		}
+	}
+}
+
+func TestSealedInterface(t *testing.T) {
+	const srcQ = `package q
+type Sealed interface {
+	Get() int
+	seal()
+}
+type Impl struct{}
+func (Impl) Get() int { return 0 }
+func (Impl) seal()    {}
+`
+	const srcP = `package p
+import "q"
+type Wrapper interface{ q.Sealed }
+func F(x interface{ seal() }) q.Impl { return q.Impl{} }
+func (Local) seal() {}
+type Local struct{}
+`
+	fset := token.NewFileSet()
+	q := typecheck(t, fset, "q", srcQ)
+	packages := make(map[string]*types.Package)
+	_, p, err := BImportData(token.NewFileSet(), packages, BExportData(fset, typecheck(t, fset, "p", srcP, q)), "p")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seal := func(iface *types.Interface) *types.Func {
+		for i := 0; i < iface.NumMethods(); i++ {
+			if m := iface.Method(i); !m.Exported() {
+				return m
+			}
+		}
+		t.Fatalf("%s has no unexported method", iface)
+		return nil
+	}
+	wrapper := p.Scope().Lookup("Wrapper").Type().Underlying().(*types.Interface)
+	if m := seal(wrapper); m.Pkg() == nil || m.Pkg().Path() != "q" {
+		t.Errorf("method %s of %s has package %v; want q", m.Name(), wrapper, m.Pkg())
+	}
+	sig := p.Scope().Lookup("F").Type().(*types.Signature)
+	param := sig.Params().At(0).Type().Underlying().(*types.Interface)
+	if m := seal(param); m.Pkg() != p {
+		t.Errorf("method %s of %s has package %v; want p", m.Name(), param, m.Pkg())
+	}
+
+	// unexported methods of different packages are distinct
+	impl := sig.Results().At(0).Type()
+	if !types.Implements(impl, wrapper) {
+		t.Errorf("%s does not implement %s", impl, wrapper)
+	}
+	if types.Implements(impl, param) {
+		t.Errorf("%s implements %s", impl, param)
+	}
+	if local := p.Scope().Lookup("Local").Type(); !types.Implements(local, param) {
+		t.Errorf("%s does not implement %s", local, param)
+	}
+}
+
+func TestBinaryStructTags(t *testing.T) {
+	src, err := ioutil.ReadFile(filepath.Join("testdata", "tags.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := typecheck(t, token.NewFileSet(), "tags", string(src))
+	pkg := bimport(t, exportSource(t, "tags", string(src)), "tags")
+
+	var compare func(name string, want, got *types.Struct)
+	compare = func(name string, want, got *types.Struct) {
+		if got.NumFields() != want.NumFields() {
+			t.Errorf("%s: got %d fields; want %d", name, got.NumFields(), want.NumFields())
+			return
+		}
+		for i := 0; i < want.NumFields(); i++ {
+			field := name + "." + want.Field(i).Name()
+			if got.Tag(i) != want.Tag(i) {
+				t.Errorf("%s: got tag %q; want %q", field, got.Tag(i), want.Tag(i))
+			}
+			if s, ok := want.Field(i).Type().(*types.Struct); ok {
+				compare(field, s, got.Field(i).Type().(*types.Struct))
+			}
+		}
+	}
+	for _, name := range []string{"T", "U"} {
+		compare(name, orig.Scope().Lookup(name).Type().Underlying().(*types.Struct), pkg.Scope().Lookup(name).Type().Underlying().(*types.Struct))
+	}
+}
+
+func TestBinaryConstants(t *testing.T) {
+	src, err := ioutil.ReadFile(filepath.Join("testdata", "consts.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := typecheck(t, token.NewFileSet(), "consts", string(src))
+	pkg := bimport(t, exportSource(t, "consts", string(src)), "consts")
+
+	abs := func(x constant.Value) constant.Value {
+		if constant.Sign(x) < 0 {
+			return constant.UnaryOp(token.SUB, x, 0)
+		}
+		return x
+	}
+	for _, name := range orig.Scope().Names() {
+		want := orig.Scope().Lookup(name).(*types.Const).Val()
+		got := pkg.Scope().Lookup(name).(*types.Const).Val()
+		switch name {
+		case "Third", "Pi", "Complex":
+			// fractions without finite binary representation are
+			// rounded to the precision of the compiler's constants
+			for _, part := range []func(constant.Value) constant.Value{constant.Real, constant.Imag} {
+				w, g := part(want), part(got)
+				diff := constant.BinaryOp(g, token.SUB, w)
+				diff = constant.BinaryOp(diff, token.MUL, constant.Shift(constant.MakeInt64(1), token.SHL, 500))
+				if constant.Compare(abs(diff), token.GTR, abs(w)) {
+					t.Errorf("%s = %s; want %s", name, got.ExactString(), want.ExactString())
+				}
+			}
+		default:
+			if !constant.Compare(got, token.EQL, want) {
+				t.Errorf("%s = %s; want %s", name, got.ExactString(), want.ExactString())
+			}
+		}
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.5
+
+package gcimporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// A BuildInfo reports the instrumentation a package was compiled with.
+type BuildInfo struct {
+	Race bool // compiled with -race
+	MSan bool // compiled with -msan
+	ASan bool // compiled with -asan
+}
+
+// BuildMode returns the BuildInfo of the object file for the package
+// with the given import path and srcDir (see FindPkg). The compiler
+// records its instrumentation flags in the DWARF producer information
+// of object files written by Go 1.20 and later; if they are not
+// recorded, as for older object files or those compiled without DWARF
+// information, BuildMode returns the zero BuildInfo.
+//
+func BuildMode(path, srcDir string) (BuildInfo, error) {
+	filename, id := FindPkg(path, srcDir)
+	if filename == "" {
+		return BuildInfo{}, &notFoundError{path: id}
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return BuildInfo{}, err
+	}
+	var info BuildInfo
+	for _, flag := range strings.Fields(producerFlags(objectData(data))) {
+		switch flag {
+		case "-race":
+			info.Race = true
+		case "-msan":
+			info.MSan = true
+		case "-asan":
+			info.ASan = true
+		}
+	}
+	return info, nil
+}
+
+// SourceHash returns the hash of the inputs recorded in the build ID of
+// the object file for the package with the given import path and srcDir
+// (see FindPkg). The go command records build IDs of the form
+// "actionID/contentID", where the action ID is a hash of the package's
+// source files, compiler flags, and the build IDs of its dependencies;
+// SourceHash returns the action ID. If no build ID is recorded, as for
+// object files compiled directly with "go tool compile", SourceHash
+// returns "" and a nil error.
+//
+// To detect a stale object file, callers compare the result with the
+// action ID (the part before the '/') of the BuildID reported for the
+// package by "go list -export"; they differ if the source changed.
+//
+func SourceHash(path, srcDir string) (string, error) {
+	filename, id := FindPkg(path, srcDir)
+	if filename == "" {
+		return "", &notFoundError{path: id}
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	buildID, err := objectBuildID(objectData(data))
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", filename, err)
+	}
+	if i := strings.Index(buildID, "/"); i >= 0 {
+		buildID = buildID[:i]
+	}
+	return buildID, nil
+}
+
+// BuildConstraints returns the build tags known to have been satisfied
+// when the object file for the package with the given import path and
+// srcDir (see FindPkg) was compiled, as recorded in its object header:
+// the target GOOS and GOARCH and, for each enabled experiment listed in
+// the header, "goexperiment." followed by the experiment's name. Tags
+// passed to the go command with -tags are not recorded in object files,
+// nor are those implied by others, such as "unix"; if nothing is
+// recorded, BuildConstraints returns an empty slice.
+//
+func BuildConstraints(path, srcDir string) ([]string, error) {
+	filename, id := FindPkg(path, srcDir)
+	if filename == "" {
+		return nil, &notFoundError{path: id}
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	objhdr, _, err := findExportData(bufio.NewReader(f), false)
+	if err != nil {
+		return nil, &fileError{filename, err}
+	}
+	return headerTags(objhdr), nil
+}
+
+// headerTags returns the build tags recorded in the object header line
+// objhdr, "go object $GOOS $GOARCH $GOVERSION [X:$GOEXPERIMENT]" with
+// further fields in newer versions.
+func headerTags(objhdr string) []string {
+	tags := []string{}
+	fields := strings.Fields(objhdr)
+	if len(fields) < 4 {
+		return tags
+	}
+	tags = append(tags, fields[2], fields[3])
+	for _, field := range fields[4:] {
+		if !strings.HasPrefix(field, "X:") {
+			continue
+		}
+		for _, exp := range strings.Split(field[len("X:"):], ",") {
+			if exp != "" && exp != "none" {
+				tags = append(tags, "goexperiment."+exp)
+			}
+		}
+	}
+	return tags
+}
+
+// objectBuildID returns the build ID recorded in the textual header of
+// the object file obj, or "" if there is none.
+func objectBuildID(obj []byte) (string, error) {
+	for len(obj) > 0 {
+		line := obj
+		if i := bytes.IndexByte(obj, '\n'); i >= 0 {
+			line, obj = obj[:i], obj[i+1:]
+		} else {
+			obj = nil
+		}
+		if len(line) > 0 && (line[0] == '$' || line[0] == '!') {
+			break // end of header
+		}
+		const prefix = "build id "
+		if bytes.HasPrefix(line, []byte(prefix)) {
+			id, err := strconv.Unquote(string(line[len(prefix):]))
+			if err != nil {
+				return "", fmt.Errorf("invalid build id line %q", line)
+			}
+			return id, nil
+		}
+	}
+	return "", nil
+}
+
+// objectData returns the contents of the _go_.o member of the archive
+// data or, if data is not an archive, data itself.
+func objectData(data []byte) []byte {
+	obj, err := archiveMember(data, "_go_.o")
+	if err == errNotArchive {
+		return data
+	}
+	return obj
+}
+
+// Layout of the Go 1.20 object file format; see cmd/internal/goobj.
+const (
+	goobjMagic   = "\x00go120ld"
+	goobjNumBlks = 19 // number of block offsets in the header
+	goobjSymSize = 8 + 2 + 1 + 1 + 1 + 4 + 4
+
+	// indices of the blocks used here
+	blkSymdef    = 3
+	blkNonpkgref = 7
+	blkDataIdx   = 13
+	blkData      = 16
+)
+
+// producerFlags returns the compiler flags recorded for the package in
+// the object file obj, or "" if obj is not in the Go 1.20 object file
+// format or does not record them.
+func producerFlags(obj []byte) string {
+	// The binary object data follows the textual header, ending in "\n!\n".
+	i := bytes.Index(obj, []byte("\n!\n"+goobjMagic))
+	if i < 0 {
+		return ""
+	}
+	obj = obj[i+3:]
+	hdrSize := len(goobjMagic) + 8 + 4 + 4*goobjNumBlks
+	if len(obj) < hdrSize {
+		return ""
+	}
+	u32 := func(off int) (uint32, bool) {
+		if off < 0 || off+4 > len(obj) {
+			return 0, false
+		}
+		return binary.LittleEndian.Uint32(obj[off:]), true
+	}
+	blk := func(i int) int {
+		off, _ := u32(len(goobjMagic) + 8 + 4 + 4*i)
+		return int(off)
+	}
+
+	// The symbol definitions (symdef through nonpkgdef blocks) are
+	// indexed in order by the data index, which holds offsets into
+	// the data block.
+	nsyms := (blk(blkNonpkgref) - blk(blkSymdef)) / goobjSymSize
+	for i := 0; i < nsyms; i++ {
+		sym := blk(blkSymdef) + i*goobjSymSize
+		n, ok1 := u32(sym)
+		off, ok2 := u32(sym + 4)
+		if !ok1 || !ok2 || int(off)+int(n) > len(obj) {
+			return ""
+		}
+		if !strings.HasPrefix(string(obj[off:off+n]), "go:cuinfo.producer.") {
+			continue
+		}
+		start, ok1 := u32(blk(blkDataIdx) + 4*i)
+		end, ok2 := u32(blk(blkDataIdx) + 4*(i+1))
+		data := blk(blkData)
+		if !ok1 || !ok2 || start > end || data+int(end) > len(obj) {
+			return ""
+		}
+		return string(obj[data+int(start) : data+int(end)])
+	}
+	return ""
+}
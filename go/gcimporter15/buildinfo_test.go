@@ -0,0 +1,145 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.13
+
+package gcimporter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestBuildMode(t *testing.T) {
+	MustHaveGoBuild(t)
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "p.go"), []byte("package p\nfunc F(x *int) int { return *x }\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name  string
+		flags []string
+		want  BuildInfo
+	}{
+		{"plain", nil, BuildInfo{}},
+		{"race", []string{"-race"}, BuildInfo{Race: true}},
+		{"msan", []string{"-msan"}, BuildInfo{MSan: true}},
+		{"asan", []string{"-asan"}, BuildInfo{ASan: true}},
+	} {
+		args := append([]string{"tool", "compile", "-p", "p", "-o", test.name + ".a"}, test.flags...)
+		cmd := exec.Command("go", append(args, "p.go")...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			if len(test.flags) > 0 {
+				t.Logf("skipping %s: %v\n%s", test.name, err, out)
+				continue // instrumentation not supported on this platform
+			}
+			t.Fatalf("go tool compile failed: %v\n%s", err, out)
+		}
+
+		got, err := BuildMode("./"+test.name, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("BuildMode(%s) = %+v; want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSourceHash(t *testing.T) {
+	MustHaveGoBuild(t)
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "p.go"), []byte("package p\nvar X int\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name  string
+		flags []string
+		want  string
+	}{
+		{"nobuildid", nil, ""},
+		{"buildid", []string{"-buildid", "actionhash/contenthash"}, "actionhash"},
+	} {
+		args := append([]string{"tool", "compile", "-p", "p", "-o", test.name + ".a"}, test.flags...)
+		cmd := exec.Command("go", append(args, "p.go")...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("go tool compile failed: %v\n%s", err, out)
+		}
+
+		got, err := SourceHash("./"+test.name, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("SourceHash(%s) = %q; want %q", test.name, got, test.want)
+		}
+	}
+
+	if _, err := SourceHash("./missing", dir); err == nil {
+		t.Errorf("SourceHash of missing package succeeded")
+	}
+}
+
+func TestBuildConstraints(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	for _, test := range []struct {
+		name, hdr string
+		want      string
+	}{
+		{"none", "go object linux amd64 go1.7 X:none", "[linux amd64]"},
+		{"exp", "go object darwin arm64 go1.22.1 X:regabiwrappers,rangefunc", "[darwin arm64 goexperiment.regabiwrappers goexperiment.rangefunc]"},
+		{"modern", "go object linux amd64 go1.27.1 GOAMD64=v1 X:jsonv2", "[linux amd64 goexperiment.jsonv2]"},
+		{"old", "go object linux", "[]"},
+	} {
+		data := []byte(test.hdr + "\n\n$$B\n\n$$\n")
+		if err := ioutil.WriteFile(filepath.Join(dir, test.name+".o"), data, 0666); err != nil {
+			t.Fatal(err)
+		}
+		tags, err := BuildConstraints("./"+test.name, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tags == nil || fmt.Sprint(tags) != test.want {
+			t.Errorf("BuildConstraints(%s) = %#v; want %s", test.name, tags, test.want)
+		}
+	}
+
+	// tags passed to the go command are not recorded
+	MustHaveGoBuild(t)
+	if err := ioutil.WriteFile(filepath.Join(dir, "p.go"), []byte("//go:build custom\n\npackage p\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("go", "tool", "compile", "-p", "p", "-o", "p.a", "p.go")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go tool compile failed: %v\n%s", err, out)
+	}
+	tags, err := BuildConstraints("./p", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) < 2 || tags[0] != runtime.GOOS || tags[1] != runtime.GOARCH {
+		t.Errorf("BuildConstraints(p) = %v; want %s, %s, ...", tags, runtime.GOOS, runtime.GOARCH)
+	}
+	for _, tag := range tags {
+		if tag == "custom" {
+			t.Errorf("BuildConstraints(p) = %v includes custom tag", tags)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.5
+
+package gcimporter
+
+import (
+	"bufio"
+	"io"
+	"os"
+)
+
+// ExportDataEqual reports whether the object files for the packages
+// with the import paths aPath and bPath and srcDir (see FindPkg) hold
+// the same export data. Only the export data sections are compared,
+// byte by byte and without decoding them; the object headers, which
+// record build-time information such as the toolchain version and the
+// build ID, are ignored, as is the object code. Since binary export
+// data records the positions of declarations, changes that move
+// declarations make the export data differ even if the API of the
+// package is unchanged. ExportDataEqual is meant as a cheap check
+// before comparing the packages' imported objects.
+//
+func ExportDataEqual(aPath, bPath, srcDir string) (bool, error) {
+	a, err := openExportData(aPath, srcDir)
+	if err != nil {
+		return false, err
+	}
+	defer a.Close()
+	b, err := openExportData(bPath, srcDir)
+	if err != nil {
+		return false, err
+	}
+	defer b.Close()
+
+	if a.hdr != b.hdr {
+		return false, nil
+	}
+	for {
+		ca, enda, err := a.next()
+		if err != nil {
+			return false, err
+		}
+		cb, endb, err := b.next()
+		if err != nil {
+			return false, err
+		}
+		if enda || endb {
+			return enda == endb, nil
+		}
+		if ca != cb {
+			return false, nil
+		}
+	}
+}
+
+// An exportDataReader reads the export data section of an object file.
+type exportDataReader struct {
+	*os.File
+	filename string
+	hdr      string // "$$\n" or "$$B\n"
+	r        *bufio.Reader
+}
+
+// openExportData opens the object file for the package path and
+// positions it at the start of the export data.
+func openExportData(path, srcDir string) (*exportDataReader, error) {
+	filename, id := FindPkg(path, srcDir)
+	if filename == "" {
+		return nil, &notFoundError{path: id}
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(f)
+	hdr, err := FindExportData(r)
+	if err != nil {
+		f.Close()
+		return nil, &fileError{filename, err}
+	}
+	return &exportDataReader{f, filename, hdr, r}, nil
+}
+
+// next returns the next byte of the export data, or end set if the
+// export data ends. Export data ends with "$$", which it does not
+// contain otherwise; binary export data escapes '$' bytes.
+func (d *exportDataReader) next() (c byte, end bool, err error) {
+	c, err = d.r.ReadByte()
+	if err == nil && c == '$' {
+		var next []byte
+		if next, err = d.r.Peek(1); err == nil && next[0] == '$' {
+			return 0, true, nil
+		}
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		err = &fileError{d.filename, err}
+	}
+	return
+}
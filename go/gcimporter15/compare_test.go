@@ -0,0 +1,58 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.6
+
+package gcimporter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportDataEqual(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	data := exportSource(t, "p", "package p; const C = 0; type T struct{ X int }")
+	writeObject(t, dir, "p", data)
+	writeObject(t, dir, "changed", exportSource(t, "p", "package p; const C = 1; type T struct{ X int }"))
+	writeObject(t, dir, "truncated", data[:len(data)/2])
+
+	// the same export data with a different object header
+	rebuilt := bytes.Replace(objectFile("amd64", data), []byte("go1.7 X:none\n"), []byte("go1.8 X:none\nbuild id \"abc/def\"\n"), 1)
+	if err := ioutil.WriteFile(filepath.Join(dir, "rebuilt.o"), rebuilt, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		a, b string
+		want bool
+	}{
+		{"./p", "./p", true},
+		{"./p", "./rebuilt", true},
+		{"./p", "./changed", false},
+		{"./changed", "./p", false},
+	} {
+		got, err := ExportDataEqual(test.a, test.b, dir)
+		if err != nil {
+			t.Errorf("ExportDataEqual(%s, %s): %v", test.a, test.b, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ExportDataEqual(%s, %s) = %t; want %t", test.a, test.b, got, test.want)
+		}
+	}
+
+	// a truncated file differs from the complete one
+	if equal, err := ExportDataEqual("./p", "./truncated", dir); equal {
+		t.Errorf("ExportDataEqual reports truncated export data as equal (error %v)", err)
+	}
+	if _, err := ExportDataEqual("./p", "./missing", dir); err == nil {
+		t.Errorf("ExportDataEqual with missing package succeeded")
+	}
+}
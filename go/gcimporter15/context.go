@@ -0,0 +1,26 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.7
+
+package gcimporter
+
+import (
+	"context"
+	"go/types"
+)
+
+// ImportContext is like Import but also imports the dependencies of
+// the package that are not complete yet, as an Importer does, and gives
+// up as soon as ctx is done, returning ctx.Err() itself. Cancellation
+// is checked before each package, including each dependency, is
+// located and, unless the packages map already holds an incomplete
+// package for it, between the objects decoded from its export data.
+// A package whose import is interrupted is removed from the packages
+// map again; it is never left marked complete.
+//
+func ImportContext(ctx context.Context, packages map[string]*types.Package, path, srcDir string) (*types.Package, error) {
+	imp := &Importer{packages: packages, ctxErr: ctx.Err}
+	return imp.importTransitive(path, srcDir)
+}
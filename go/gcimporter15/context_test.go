@@ -0,0 +1,124 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.7
+
+package gcimporter
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// countdownContext is canceled once Err has been called n times.
+type countdownContext struct {
+	context.Context
+	n int
+}
+
+func (ctx *countdownContext) Err() error {
+	if ctx.n--; ctx.n < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestImportContext(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeObject(t, dir, "p", exportSource(t, "p", "package p; const A, B, C, D = 1, 2, 3, 4"))
+	id := filepath.Join(dir, "p")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	packages := make(map[string]*types.Package)
+	if _, err := ImportContext(ctx, packages, "./p", dir); err != context.Canceled {
+		t.Fatalf("canceled context: got error %v; want context.Canceled", err)
+	}
+	if len(packages) != 0 {
+		t.Errorf("canceled context: packages = %v; want none", packages)
+	}
+
+	// canceled while decoding the objects of p
+	if _, err := ImportContext(&countdownContext{context.Background(), 3}, packages, "./p", dir); err != context.Canceled {
+		t.Fatalf("canceled import: got error %v; want context.Canceled", err)
+	}
+	if pkg := packages[id]; pkg != nil {
+		t.Errorf("interrupted import left %s in packages (complete: %v)", id, pkg.Complete())
+	}
+
+	pkg, err := ImportContext(context.Background(), packages, "./p", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pkg.Complete() || pkg.Scope().Lookup("D") == nil {
+		t.Errorf("import after interrupted import is incomplete")
+	}
+}
+
+func TestImportContextDependencies(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// a depends on b
+	path := func(name string) string { return filepath.Join(dir, name) }
+	fset := token.NewFileSet()
+	b := typecheck(t, fset, path("b"), "package b; type B int; const C, D = 1, 2")
+	a := typecheck(t, fset, path("a"), fmt.Sprintf("package a; import %q; var V b.B", b.Path()), b)
+	writeObject(t, dir, "a", BExportData(fset, a))
+	writeObject(t, dir, "b", BExportData(fset, b))
+
+	// cancel after an increasing number of checks until the import
+	// succeeds; some of the imports must be interrupted in b
+	interruptedDep := false
+	for n := 0; ; n++ {
+		packages := make(map[string]*types.Package)
+		pkg, err := ImportContext(&countdownContext{context.Background(), n}, packages, "./a", dir)
+		if err == nil {
+			if !pkg.Complete() || !packages[b.Path()].Complete() {
+				t.Errorf("n = %d: import of a and b is incomplete", n)
+			}
+			break
+		}
+		if err != context.Canceled {
+			t.Fatalf("n = %d: got error %v; want context.Canceled", n, err)
+		}
+		if pkg := packages[a.Path()]; pkg != nil && pkg.Complete() {
+			interruptedDep = true
+			if pkg := packages[b.Path()]; pkg != nil && pkg.Complete() {
+				t.Errorf("n = %d: interrupted import left b complete", n)
+			}
+		}
+	}
+	if !interruptedDep {
+		t.Errorf("import of dependency b was never interrupted")
+	}
+}
+
+func TestImportContextTextual(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	src := fmt.Sprintf("go object %s %s go1.6 X:none\n\n$$\npackage p\nconst @\"\".A = 1\nconst @\"\".B = 2\n$$\n", runtime.GOOS, runtime.GOARCH)
+	if err := ioutil.WriteFile(filepath.Join(dir, "p.o"), []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	// canceled while parsing the declarations of p
+	packages := make(map[string]*types.Package)
+	_, err := ImportContext(&countdownContext{context.Background(), 2}, packages, "./p", dir)
+	if err != context.Canceled {
+		// not a CorruptError, nor wrapped in one
+		t.Fatalf("got error %v; want context.Canceled", err)
+	}
+	if len(packages) != 0 {
+		t.Errorf("interrupted import left packages %v", packages)
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.5
+
+package gcimporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"go/types"
+	"io"
+	"io/ioutil"
+)
+
+// dumpBytes is the number of bytes of export data DumpExportData
+// writes as hex dump.
+const dumpBytes = 256
+
+// DumpExportData writes a description of the export data of the
+// package with the given import path and srcDir (see FindPkg) to w,
+// for debugging failed imports: the file name, the object header, the
+// offset and header of the export data in the file, its format and
+// version and, depending on the format, the string table of indexed
+// export data or the lines of textual export data, followed by a hex
+// dump of the first bytes of the export data. The export data is not
+// decoded beyond that, so that the description is available for
+// corrupt export data, too: DumpExportData notes any problem found at
+// the end of the description and returns it, typically as an error
+// wrapping a CorruptError.
+//
+func DumpExportData(w io.Writer, path, srcDir string) error {
+	filename, id := FindPkg(path, srcDir)
+	if filename == "" {
+		return &notFoundError{path: id}
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "file %s (%d bytes)\n", filename, len(data))
+	err = dumpFile(bw, data, id)
+	if err != nil {
+		fmt.Fprintf(bw, "error: %v\n", err)
+	}
+	if werr := bw.Flush(); werr != nil {
+		return werr
+	}
+	if err != nil {
+		return &fileError{filename, err}
+	}
+	return nil
+}
+
+// dumpFile describes the export data of the package id in the object
+// file or archive data.
+func dumpFile(w io.Writer, data []byte, id string) error {
+	r := bytes.NewReader(data)
+	buf := bufio.NewReader(r)
+	objhdr, hdr, err := findExportData(buf, false)
+	fmt.Fprintf(w, "object header %q\n", objhdr)
+	if err != nil {
+		return err
+	}
+	start := len(data) - r.Len() - buf.Buffered()
+	fmt.Fprintf(w, "export data header %q at offset %d\n", hdr, start-len(hdr))
+	data = data[start:]
+
+	switch {
+	case hdr == "$$\n":
+		if i := bytes.Index(data, []byte("\n$$")); i >= 0 {
+			data = data[:i+1]
+		}
+		err = dumpTextual(w, data)
+	case hdr != "$$B\n":
+		return &CorruptError{0, fmt.Sprintf("unknown export data header: %q", hdr)}
+	case len(data) > 0 && data[0] == 'i':
+		err = dumpIndexed(w, data)
+	default:
+		err = dumpBinary(w, data, id)
+	}
+
+	n := len(data)
+	if n > dumpBytes {
+		n = dumpBytes
+	}
+	fmt.Fprintf(w, "first %d bytes of export data (offsets relative to %d):\n%s", n, start, hex.Dump(data[:n]))
+	return err
+}
+
+// dumpTextual lists the lines of the textual export data.
+func dumpTextual(w io.Writer, data []byte) error {
+	fmt.Fprintf(w, "format textual\n")
+	for off := 0; off < len(data); {
+		line := data[off:]
+		if i := bytes.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i+1]
+		}
+		fmt.Fprintf(w, "\t%6d %q\n", off, line)
+		off += len(line)
+	}
+	return nil
+}
+
+// dumpBinary describes the header of the binary export data.
+func dumpBinary(w io.Writer, data []byte, id string) (err error) {
+	fmt.Fprintf(w, "format binary\n")
+	p := importer{
+		conf:    new(Importer),
+		imports: make(map[string]*types.Package),
+		data:    data,
+		path:    id,
+		strList: []string{""}, // empty string is mapped to 0
+	}
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+			// nothing to do
+		case formatError:
+			err = &CorruptError{int64(p.read), string(r)}
+		default:
+			err = &CorruptError{int64(p.read), fmt.Sprint(r)}
+		}
+	}()
+
+	version := ""
+	if err = p.header(); err != nil {
+		e, ok := err.(*VersionError)
+		if !ok {
+			return err
+		}
+		version = e.Version
+	} else {
+		version = fmt.Sprintf("v%d", p.version)
+	}
+	encoding := "compact"
+	if p.debugFormat {
+		encoding = "debug"
+	}
+	fmt.Fprintf(w, "encoding %s, all types tracked: %v, position information: %v\n", encoding, p.trackAllTypes, p.posInfoFormat)
+	fmt.Fprintf(w, "version %q\n", version)
+	fmt.Fprintf(w, "header ends at offset %d\n", p.read)
+	return err
+}
+
+// dumpIndexed describes the header and string table of the indexed
+// export data.
+func dumpIndexed(w io.Writer, data []byte) error {
+	fmt.Fprintf(w, "format indexed\n")
+	off := 1
+	uvarint := func(what string) (uint64, error) {
+		x, n := binary.Uvarint(data[off:])
+		if n <= 0 {
+			return 0, &CorruptError{int64(off), "invalid " + what}
+		}
+		off += n
+		return x, nil
+	}
+
+	version, err := uvarint("version")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "version %d\n", version)
+	sLen, err := uvarint("string section length")
+	if err != nil {
+		return err
+	}
+	dLen, err := uvarint("declaration section length")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "string section at offset %d, %d bytes\n", off, sLen)
+	fmt.Fprintf(w, "declaration section at offset %d, %d bytes\n", uint64(off)+sLen, dLen)
+	if sLen > uint64(len(data)-off) || dLen > uint64(len(data)-off)-sLen {
+		return &CorruptError{int64(off), fmt.Sprintf("section lengths %d and %d exceed data size", sLen, dLen)}
+	}
+
+	fmt.Fprintf(w, "strings:\n")
+	sdata := data[off : uint64(off)+sLen]
+	for soff := 0; soff < len(sdata); {
+		n, m := binary.Uvarint(sdata[soff:])
+		if m <= 0 || n > uint64(len(sdata)-soff-m) {
+			return &CorruptError{int64(off + soff), "invalid string length"}
+		}
+		fmt.Fprintf(w, "\t%6d %q\n", soff, sdata[soff+m:soff+m+int(n)])
+		soff += m + int(n)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.13
+
+package gcimporter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDumpExportData(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	data := exportSource(t, "p", "package p; const C = 0")
+	objhdr := fmt.Sprintf("go object %s %s go1.7 X:none\n", runtime.GOOS, runtime.GOARCH)
+	for _, test := range []struct {
+		name, obj string
+		want      []string
+		corrupt   bool
+	}{
+		{"binary", string(objectFile(runtime.GOARCH, data)), []string{"format binary", `version "v3"`, "export data header \"$$B\\n\" at offset"}, false},
+		{"textual", objhdr + "\n$$\npackage p\nconst @\"\".C = 1\n$$\n", []string{"format textual", `"package p\n"`}, false},
+		{"truncated", string(objectFile(runtime.GOARCH, data[:3])), []string{"format binary", "error: "}, true},
+		{"unknown header", objhdr + "\n$$X\n", []string{"unknown export data header"}, true},
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "p.o"), []byte(test.obj), 0666); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		err := DumpExportData(&buf, "./p", dir)
+		var cerr *CorruptError
+		if test.corrupt != errors.As(err, &cerr) {
+			t.Errorf("%s: got error %v; want CorruptError = %v", test.name, err, test.corrupt)
+		}
+		dump := buf.String()
+		for _, want := range append(test.want, objhdr[:len(objhdr)-1]) {
+			if !strings.Contains(dump, want) {
+				t.Errorf("%s: dump does not contain %q:\n%s", test.name, want, dump)
+			}
+		}
+	}
+
+	if err := DumpExportData(new(bytes.Buffer), "./missing", dir); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing package: got error %v; want ErrNotFound", err)
+	}
+}
@@ -19,13 +19,22 @@ import (
 	"strings"
 )
 
+// gopackHeaderSize is the size of an archive member header;
+// see $GOROOT/include/ar.h.
+const gopackHeaderSize = 16 + 12 + 6 + 6 + 8 + 10 + 2
+
 func readGopackHeader(r *bufio.Reader) (name string, size int, err error) {
-	// See $GOROOT/include/ar.h.
-	hdr := make([]byte, 16+12+6+6+8+10+2)
+	hdr := make([]byte, gopackHeaderSize)
 	_, err = io.ReadFull(r, hdr)
 	if err != nil {
 		return
 	}
+	return parseGopackHeader(hdr)
+}
+
+// parseGopackHeader returns the member name and size recorded in the
+// archive member header hdr.
+func parseGopackHeader(hdr []byte) (name string, size int, err error) {
 	// leave for debugging
 	if false {
 		fmt.Printf("header: %s", hdr)
@@ -47,6 +56,21 @@ func readGopackHeader(r *bufio.Reader) (name string, size int, err error) {
 // is the string before the export data, either "$$" or "$$B".
 //
 func FindExportData(r *bufio.Reader) (hdr string, err error) {
+	_, hdr, err = findExportData(r, false)
+	return
+}
+
+// findExportData is like FindExportData but also returns the object
+// header line, "go object $GOOS $GOARCH $GOVERSION ...".
+// If lenient is set, a leading UTF-8 byte order mark and white space
+// before the start of the file are ignored.
+func findExportData(r *bufio.Reader, lenient bool) (objhdr, hdr string, err error) {
+	if lenient {
+		if err = skipBOM(r); err != nil {
+			return
+		}
+	}
+
 	// Read first line to make sure this is an object file.
 	line, err := r.ReadSlice('\n')
 	if err != nil {
@@ -98,18 +122,79 @@ func FindExportData(r *bufio.Reader) (hdr string, err error) {
 	// Now at __.PKGDEF in archive or still at beginning of file.
 	// Either way, line should begin with "go object ".
 	if !strings.HasPrefix(string(line), "go object ") {
+		if isGccgoData(line) {
+			err = errors.New("not a go object file: gccgo export data (see ImportGccgo)")
+			return
+		}
 		err = errors.New("not a go object file")
 		return
 	}
+	objhdr = strings.TrimSuffix(string(line), "\n")
 
 	// Skip over object header to export data.
 	// Begins after first line starting with $$.
+	off := int64(len(line))
 	for line[0] != '$' {
 		if line, err = r.ReadSlice('\n'); err != nil {
+			if err == io.EOF {
+				err = &CorruptError{off + int64(len(line)), "object file ends before export data"}
+			}
 			return
 		}
+		off += int64(len(line))
 	}
 	hdr = string(line)
 
 	return
 }
+
+// checkExportDataEnd reports a CorruptError unless the n bytes of
+// binary or indexed export data decoded from data are followed by the
+// "\n$$\n" that ends the export data section.
+func checkExportDataEnd(data []byte, n int) error {
+	if !strings.HasPrefix(string(data[n:]), "\n$$\n") {
+		return &CorruptError{int64(n), "export data does not end with \"\\n$$\\n\""}
+	}
+	return nil
+}
+
+// isGccgoData reports whether line, the first line of a file, starts
+// gccgo export data or an ELF object file, as written by gccgo.
+func isGccgoData(line []byte) bool {
+	for _, magic := range []string{"v1;\n", "v2;\n", "v3;\n", "\x7fELF"} {
+		if strings.HasPrefix(string(line), magic) {
+			return true
+		}
+	}
+	return false
+}
+
+// skipBOM skips a leading UTF-8 byte order mark and any
+// ASCII white space following it.
+func skipBOM(r *bufio.Reader) error {
+	if bom, _ := r.Peek(3); string(bom) == "\xef\xbb\xbf" {
+		r.Discard(3)
+	}
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			// skip
+		default:
+			return r.UnreadByte()
+		}
+	}
+}
+
+// objectArch returns the GOARCH recorded in the object header line
+// objhdr, or "" if there is none.
+func objectArch(objhdr string) string {
+	// "go object" $GOOS $GOARCH ...
+	if fields := strings.Fields(objhdr); len(fields) >= 4 {
+		return fields[3]
+	}
+	return ""
+}
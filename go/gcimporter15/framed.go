@@ -0,0 +1,98 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.5
+
+// This file implements a simple container format for the export data
+// of several packages.
+
+package gcimporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+)
+
+// A framed stream is a sequence of frames, each consisting of a 4-byte
+// big-endian length followed by that many bytes of frame data. The frame
+// data holds the import path of a package, terminated by a newline, and
+// the binary export data of the package as produced by BExportData.
+
+// WriteFrame writes a frame holding the export data of the package
+// with the given import path to w.
+func WriteFrame(w io.Writer, path string, data []byte) error {
+	n := len(path) + 1 + len(data)
+	if uint64(n) > 1<<32-1 {
+		return errors.New("export data too large for frame")
+	}
+	var hdr [4]byte
+	binary.BigEndian.PutUint32(hdr[:], uint32(n))
+	_, err := w.Write(append(append(append(hdr[:], path...), '\n'), data...))
+	return err
+}
+
+// ImportFramed imports the packages of the framed stream read from r,
+// until EOF, into the packages map and returns them in stream order
+// (see WriteFrame). Frames must appear in dependency order for the
+// imported packages to be complete.
+func ImportFramed(packages map[string]*types.Package, r io.Reader) ([]*types.Package, error) {
+	segs, err := ImportFramedSegments(packages, r)
+	var list []*types.Package
+	for _, seg := range segs {
+		list = append(list, seg.Pkg)
+	}
+	return list, err
+}
+
+// A Segment describes the frame of a package in a framed stream.
+type Segment struct {
+	Pkg    *types.Package
+	Offset int64 // offset of the frame in the stream, including its length
+	Length int64 // length of the frame, including its length
+}
+
+// ImportFramedSegments is like ImportFramed but returns the segments
+// of the stream occupied by the frames of the imported packages.
+// The segments are contiguous, starting at offset 0; each of them is
+// a framed stream holding just the package's frame.
+func ImportFramedSegments(packages map[string]*types.Package, r io.Reader) ([]Segment, error) {
+	fset := token.NewFileSet()
+	var list []Segment
+	var offset int64
+	var hdr [4]byte
+	for {
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			if err == io.EOF {
+				return list, nil
+			}
+			return list, fmt.Errorf("frame %d: reading length: %v", len(list), err)
+		}
+
+		// Don't trust the length: read no more than is available.
+		n := int64(binary.BigEndian.Uint32(hdr[:]))
+		var buf bytes.Buffer
+		if m, _ := io.CopyN(&buf, r, n); m != n {
+			return list, fmt.Errorf("frame %d: got %d bytes of data; want %d", len(list), m, n)
+		}
+
+		frame := buf.Bytes()
+		i := bytes.IndexByte(frame, '\n')
+		if i <= 0 {
+			return list, fmt.Errorf("frame %d: missing import path", len(list))
+		}
+		path := string(frame[:i])
+		_, pkg, err := BImportData(fset, packages, frame[i+1:], path)
+		if err != nil {
+			return list, fmt.Errorf("frame %d: importing %s: %v", len(list), path, err)
+		}
+		length := int64(len(hdr)) + n
+		list = append(list, Segment{pkg, offset, length})
+		offset += length
+	}
+}
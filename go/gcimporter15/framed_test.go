@@ -0,0 +1,104 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.6
+
+package gcimporter
+
+import (
+	"bytes"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+func TestImportFramed(t *testing.T) {
+	fset := token.NewFileSet()
+	q := typecheck(t, fset, "example.com/q", "package q; type Q int")
+	p := typecheck(t, fset, "example.com/p", `package p; import "example.com/q"; var V q.Q`, q)
+
+	var buf bytes.Buffer
+	for _, pkg := range []*types.Package{q, p} {
+		if err := WriteFrame(&buf, pkg.Path(), BExportData(fset, pkg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream := buf.Bytes()
+
+	packages := make(map[string]*types.Package)
+	list, err := ImportFramed(packages, bytes.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Path() != q.Path() || list[1].Path() != p.Path() {
+		t.Fatalf("got packages %v", list)
+	}
+	if got := list[1].Scope().Lookup("V").Type(); got != list[0].Scope().Lookup("Q").Type() {
+		t.Errorf("type of V is %s; want imported q.Q", got)
+	}
+
+	// truncated and corrupt frames are rejected
+	for _, test := range []struct {
+		data []byte
+		want string
+	}{
+		{stream[:len(stream)-1], "frame 1: got"},
+		{stream[:2], "frame 0: reading length"},
+		{[]byte{0xff, 0xff, 0xff, 0xff, 'x'}, "frame 0: got 1 bytes of data; want 4294967295"},
+		{[]byte{0, 0, 0, 1, '\n'}, "frame 0: missing import path"},
+	} {
+		_, err := ImportFramed(make(map[string]*types.Package), bytes.NewReader(test.data))
+		if err == nil || !strings.HasPrefix(err.Error(), test.want) {
+			t.Errorf("got error %v; want %s...", err, test.want)
+		}
+	}
+}
+
+func TestImportFramedSegments(t *testing.T) {
+	fset := token.NewFileSet()
+	q := typecheck(t, fset, "example.com/q", "package q; type Q int")
+	p := typecheck(t, fset, "example.com/p", `package p; import "example.com/q"; var V q.Q; func F() {}`, q)
+
+	var buf bytes.Buffer
+	var lengths []int64
+	for _, pkg := range []*types.Package{q, p} {
+		n := buf.Len()
+		if err := WriteFrame(&buf, pkg.Path(), BExportData(fset, pkg)); err != nil {
+			t.Fatal(err)
+		}
+		lengths = append(lengths, int64(buf.Len()-n))
+	}
+	stream := buf.Bytes()
+
+	segs, err := ImportFramedSegments(make(map[string]*types.Package), bytes.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segs) != 2 || segs[0].Pkg.Path() != q.Path() || segs[1].Pkg.Path() != p.Path() {
+		t.Fatalf("got segments %v", segs)
+	}
+	var offset int64
+	for i, seg := range segs {
+		if seg.Offset != offset || seg.Length != lengths[i] {
+			t.Errorf("segment %d: got offset %d, length %d; want %d, %d", i, seg.Offset, seg.Length, offset, lengths[i])
+		}
+		offset += seg.Length
+	}
+	if offset != int64(len(stream)) {
+		t.Errorf("segments cover %d bytes; want %d", offset, len(stream))
+	}
+
+	// each segment can be imported on its own
+	packages := make(map[string]*types.Package)
+	for _, seg := range segs {
+		list, err := ImportFramed(packages, bytes.NewReader(stream[seg.Offset:seg.Offset+seg.Length]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 1 || list[0].Path() != seg.Pkg.Path() {
+			t.Errorf("segment of %s holds %v", seg.Pkg.Path(), list)
+		}
+	}
+}
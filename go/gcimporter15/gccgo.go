@@ -0,0 +1,32 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.18
+
+package gcimporter
+
+import (
+	"go/build"
+	"go/types"
+	"path/filepath"
+
+	"golang.org/x/tools/go/gcimporter15/internal/gccgoimporter"
+)
+
+// ImportGccgo is like Import but for packages compiled by gccgo. It
+// imports the package with the given import path from the gccgo export
+// data found in srcDir or in the gccgo package directories of GOPATH,
+// $GOPATH/pkg/gccgo_$GOOS_$GOARCH. Export data is read from files
+// path.gox, libname.so, libname.a, and path.o, where name is the last
+// element of path, as gccgo does. The packages map must contain all
+// packages already imported; packages imported from gc export data
+// may be shared with those imported from gccgo export data.
+//
+func ImportGccgo(packages map[string]*types.Package, path, srcDir string) (*types.Package, error) {
+	searchpaths := []string{srcDir}
+	for _, dir := range filepath.SplitList(build.Default.GOPATH) {
+		searchpaths = append(searchpaths, filepath.Join(dir, "pkg", "gccgo_"+build.Default.GOOS+"_"+build.Default.GOARCH))
+	}
+	return gccgoimporter.GetImporter(searchpaths, nil)(packages, path, srcDir, nil)
+}
@@ -0,0 +1,46 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.18
+
+package gcimporter
+
+import (
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImportGccgo(t *testing.T) {
+	testdata := filepath.Join("internal", "gccgoimporter", "testdata")
+	pkg, err := ImportGccgo(make(map[string]*types.Package), "pointer", testdata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := pkg.Scope().Lookup("Int8Ptr")
+	if obj == nil {
+		t.Fatal("Int8Ptr not found")
+	}
+	if got, want := types.ObjectString(obj, types.RelativeTo(pkg)), "type Int8Ptr *int8"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	// The gc importer must reject gccgo export data with a clear error.
+	data, err := ioutil.ReadFile(filepath.Join(testdata, "pointer.gox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "pointer.o"), data, 0666); err != nil {
+		t.Fatal(err)
+	}
+	_, err = Import(make(map[string]*types.Package), "./pointer", dir)
+	if err == nil || !strings.Contains(err.Error(), "gccgo") {
+		t.Errorf("Import of gccgo export data: got error %v; want error mentioning gccgo", err)
+	}
+}
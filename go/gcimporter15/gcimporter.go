@@ -33,6 +33,7 @@ import (
 	"strconv"
 	"strings"
 	"text/scanner"
+	"unicode"
 )
 
 // debugging/development support
@@ -46,12 +47,57 @@ var pkgExts = [...]string{".a", ".o"}
 // relative to the current working directory.
 // If no file was found, an empty filename is returned.
 //
+// Import and Importer resolve import paths using FindPkg: vendored
+// packages are found relative to srcDir, and unclean paths such as
+// "./././testdata//p" denote the same file and package id as their
+// clean form. Import records the package in the packages map under id.
+//
 func FindPkg(path, srcDir string) (filename, id string) {
+	return findPkgIn(&build.Default, path, srcDir)
+}
+
+// findPkgIn is like FindPkg but uses the build context ctxt.
+func findPkgIn(ctxt *build.Context, path, srcDir string) (filename, id string) {
+	noext, id := pkgBase(ctxt, path, srcDir)
+	if noext == "" {
+		return
+	}
+
+	// try extensions
+	for _, ext := range pkgExts {
+		filename = noext + ext
+		if f, err := os.Stat(filename); err == nil && !f.IsDir() {
+			return
+		}
+	}
+
+	filename = "" // not found
+	return
+}
+
+// pkgCandidates returns the file names FindPkg checks for path and
+// srcDir, in order. The result is empty if path cannot be resolved
+// to a location at all.
+func pkgCandidates(path, srcDir string) []string {
+	noext, _ := pkgBase(&build.Default, path, srcDir)
+	if noext == "" {
+		return nil
+	}
+	var list []string
+	for _, ext := range pkgExts {
+		list = append(list, noext+ext)
+	}
+	return list
+}
+
+// pkgBase returns the file name, without extension, of the export data
+// of path and srcDir, and the package id, as used by findPkgIn. If path
+// cannot be resolved, noext is empty.
+func pkgBase(ctxt *build.Context, path, srcDir string) (noext, id string) {
 	if path == "" {
 		return
 	}
 
-	var noext string
 	switch {
 	default:
 		// "x" -> "$GOPATH/pkg/$GOOS_$GOARCH/x.ext", "x"
@@ -59,7 +105,7 @@ func FindPkg(path, srcDir string) (filename, id string) {
 		if abs, err := filepath.Abs(srcDir); err == nil { // see issue 14282
 			srcDir = abs
 		}
-		bp, _ := build.Import(path, srcDir, build.FindOnly|build.AllowBinary)
+		bp, _ := ctxt.Import(path, srcDir, build.FindOnly|build.AllowBinary)
 		if bp.PkgObj == "" {
 			return
 		}
@@ -85,15 +131,6 @@ func FindPkg(path, srcDir string) (filename, id string) {
 		}
 	}
 
-	// try extensions
-	for _, ext := range pkgExts {
-		filename = noext + ext
-		if f, err := os.Stat(filename); err == nil && !f.IsDir() {
-			return
-		}
-	}
-
-	filename = "" // not found
 	return
 }
 
@@ -110,13 +147,20 @@ func FindPkg(path, srcDir string) (filename, id string) {
 // there is also no harm but for extra time used).
 //
 func ImportData(packages map[string]*types.Package, filename, id string, data io.Reader) (pkg *types.Package, err error) {
+	return new(Importer).importData(packages, filename, id, data)
+}
+
+// importData is like ImportData but subject to the configuration of imp.
+func (imp *Importer) importData(packages map[string]*types.Package, filename, id string, data io.Reader) (pkg *types.Package, err error) {
 	// support for parser error handling
 	defer func() {
 		switch r := recover().(type) {
 		case nil:
 			// nothing to do
 		case importError:
-			err = r
+			err = r.corrupt()
+		case interruption:
+			err = r.err
 		default:
 			panic(r) // internal error
 		}
@@ -124,6 +168,8 @@ func ImportData(packages map[string]*types.Package, filename, id string, data io
 
 	var p parser
 	p.init(filename, id, data, packages)
+	p.names = imp.names()
+	p.imp = imp
 	pkg = p.parseExport()
 
 	return
@@ -131,60 +177,243 @@ func ImportData(packages map[string]*types.Package, filename, id string, data io
 
 // Import imports a gc-generated package given its import path and srcDir, adds
 // the corresponding package object to the packages map, and returns the object.
-// The packages map must contain all packages already imported.
+// The packages map must contain all packages already imported. If there is
+// no export data for the package, the error wraps ErrNotFound; export data
+// that is truncated or malformed is reported as a wrapped *CorruptError.
 //
 func Import(packages map[string]*types.Package, path, srcDir string) (pkg *types.Package, err error) {
+	return new(Importer).importPkg(packages, path, srcDir)
+}
+
+// ImportReader is like Import but reads the contents of the object file
+// or archive holding the export data of the package from data instead
+// of locating it by import path, for instance to import packages kept
+// in memory. Textual, binary and indexed export data are recognized as by
+// Import. The package is recorded in the packages map under path,
+// which is also used in error messages. Unlike ImportData, ImportReader
+// expects data to start at the beginning of the file.
+//
+func ImportReader(packages map[string]*types.Package, data io.Reader, path string) (*types.Package, error) {
+	return new(Importer).importFile(packages, path, path, data)
+}
+
+// ImportHeader returns the name of the package with the given import
+// path and srcDir together with the import paths recorded in its export
+// data, without importing the package's objects. For binary export data
+// of version 3 or later, only the header is decoded; older binary export
+// data and indexed export data are imported in full. For textual export
+// data, the import paths are those of the import declarations.
+//
+func ImportHeader(path, srcDir string) (name string, imports []string, err error) {
 	filename, id := FindPkg(path, srcDir)
 	if filename == "" {
 		if path == "unsafe" {
-			return types.Unsafe, nil
+			return "unsafe", nil, nil
 		}
-		err = fmt.Errorf("can't find import: %s", id)
+		err = &notFoundError{path: id}
 		return
 	}
 
-	// no need to re-import if the package was imported completely before
-	if pkg = packages[id]; pkg != nil && pkg.Complete() {
-		return
-	}
-
-	// open file
 	f, err := os.Open(filename)
 	if err != nil {
 		return
 	}
+	defer f.Close()
+
 	defer func() {
-		f.Close()
 		if err != nil {
 			// add file name to error
-			err = fmt.Errorf("reading export data: %s: %v", filename, err)
+			err = &fileError{filename, err}
 		}
 	}()
 
-	var hdr string
 	buf := bufio.NewReader(f)
-	if hdr, err = FindExportData(buf); err != nil {
+	_, hdr, err := findExportData(buf, false)
+	if err != nil {
 		return
 	}
 
 	switch hdr {
 	case "$$\n":
-		return ImportData(packages, filename, id, buf)
+		return importHeaderData(filename, id, buf)
 	case "$$B\n":
 		var data []byte
 		data, err = ioutil.ReadAll(buf)
-		if err == nil {
-			fset := token.NewFileSet()
-			_, pkg, err = BImportData(fset, packages, data, id)
+		if err != nil {
+			return
+		}
+		var pkg *types.Package
+		if len(data) > 0 && data[0] == 'i' {
+			// the indexed format has no separate import list
+			_, pkg, err = IImportData(token.NewFileSet(), make(map[string]*types.Package), data, id)
+		} else {
+			var ok bool
+			if name, imports, ok, err = bimportHeader(data); ok || err != nil {
+				return
+			}
+			// no import list in export data before version 3
+			_, pkg, err = BImportData(token.NewFileSet(), make(map[string]*types.Package), data, id)
+		}
+		if err != nil {
 			return
 		}
+		name = pkg.Name()
+		for _, imp := range pkg.Imports() {
+			imports = append(imports, imp.Path())
+		}
 	default:
-		err = fmt.Errorf("unknown export data header: %q", hdr)
+		err = &CorruptError{0, fmt.Sprintf("unknown export data header: %q", hdr)}
+	}
+
+	return
+}
+
+// RepairNames sets the names of the packages in packages, or reachable
+// from them through their imports, that have no name, as may be left by
+// importing export data written by older, buggy toolchains. The name of
+// such a package is taken from its export data, if ImportHeader finds
+// it; otherwise it is derived from the last element of the import path
+// not denoting a major version, such as "v2", with characters invalid in
+// identifiers replaced by underscores. RepairNames returns the import
+// paths of the repaired packages, sorted.
+//
+func RepairNames(packages map[string]*types.Package) (repaired []string) {
+	seen := make(map[*types.Package]bool)
+	var repair func(pkg *types.Package)
+	repair = func(pkg *types.Package) {
+		if seen[pkg] {
+			return
+		}
+		seen[pkg] = true
+		if pkg.Name() == "" {
+			name, _, err := ImportHeader(pkg.Path(), "")
+			if err != nil || name == "" {
+				name = nameFromPath(pkg.Path())
+			}
+			setName(pkg, name)
+			repaired = append(repaired, pkg.Path())
+		}
+		for _, dep := range pkg.Imports() {
+			repair(dep)
+		}
 	}
+	for _, pkg := range packages {
+		repair(pkg)
+	}
+	sort.Strings(repaired)
+	return repaired
+}
+
+// nameFromPath returns a package name for the import path path.
+func nameFromPath(path string) string {
+	elems := strings.Split(strings.TrimRight(path, "/"), "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = elems[len(elems)-2] // major version suffix
+	}
+	name = strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "_" + name
+	}
+	return name
+}
+
+// importHeaderData is like ImportHeader for textual export data read
+// from data.
+func importHeaderData(filename, id string, data io.Reader) (name string, imports []string, err error) {
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+			// nothing to do
+		case importError:
+			err = r.corrupt()
+		default:
+			panic(r) // internal error
+		}
+	}()
 
+	var p parser
+	p.init(filename, id, data, make(map[string]*types.Package))
+	name, imports = p.parseHeader()
 	return
 }
 
+// ErrNotFound is reported, possibly wrapped, by Lookup functions
+// for packages that do not exist, and by imports of packages for
+// which no export data can be found.
+var ErrNotFound = errors.New("package not found")
+
+// A notFoundError reports that there is no export data for path.
+type notFoundError struct {
+	path   string
+	detail string // optional
+}
+
+func (e *notFoundError) Error() string {
+	if e.detail != "" {
+		return fmt.Sprintf("can't find import: %s: %s", e.path, e.detail)
+	}
+	return fmt.Sprintf("can't find import: %s", e.path)
+}
+
+func (e *notFoundError) Is(target error) bool { return target == ErrNotFound }
+
+// A fileError reports an error reading the export data in filename.
+type fileError struct {
+	filename string
+	err      error
+}
+
+func (e *fileError) Error() string {
+	return fmt.Sprintf("reading export data: %s: %v", e.filename, e.err)
+}
+
+func (e *fileError) Unwrap() error { return e.err }
+
+// A CorruptError reports export data that is truncated or otherwise
+// malformed, or in an unknown format. Such data cannot be imported no
+// matter how often the import is retried, unlike export data that does
+// not exist (yet), which is reported as ErrNotFound. Unsupported format
+// versions are reported as VersionError instead.
+type CorruptError struct {
+	// Offset is the byte offset in the export data at which the
+	// problem was detected or, for problems before the export data,
+	// the offset in the object file; it is -1 if unknown.
+	Offset int64
+	Reason string
+}
+
+func (e *CorruptError) Error() string {
+	if e.Offset < 0 {
+		return e.Reason
+	}
+	return fmt.Sprintf("%s (offset %d)", e.Reason, e.Offset)
+}
+
+// corrupt returns the CorruptError reported by e.
+func (e importError) corrupt() *CorruptError {
+	return &CorruptError{int64(e.pos.Offset), fmt.Sprintf("import error %s: %v", e.pos, e.err)}
+}
+
+// ErrArchMismatch is reported when the object file header records
+// a different architecture than the one requested via Importer.GOARCH.
+var ErrArchMismatch = errors.New("export data architecture mismatch")
+
+type archError struct {
+	got, want string
+}
+
+func (e *archError) Error() string {
+	return fmt.Sprintf("%v: got %s, want %s", ErrArchMismatch, e.got, e.want)
+}
+
+func (e *archError) Is(target error) bool { return target == ErrArchMismatch }
+
 // ----------------------------------------------------------------------------
 // Parser
 
@@ -203,6 +432,8 @@ type parser struct {
 	id         string                    // package id of imported package
 	sharedPkgs map[string]*types.Package // package id -> package object (across importer)
 	localPkgs  map[string]*types.Package // package id -> package object (just this package)
+	names      map[string]string         // package id -> package name overriding the recorded one
+	imp        *Importer                 // if set, creates placeholders for missing packages and reports cancellation
 }
 
 func (p *parser) init(filename, id string, src io.Reader, packages map[string]*types.Package) {
@@ -263,6 +494,13 @@ func (e importError) Error() string {
 	return fmt.Sprintf("import error %s (byte offset = %d): %s", e.pos, e.pos.Offset, e.err)
 }
 
+// An interruption carries the error of an interrupted import (see
+// ImportContext) out of the parser; unlike an importError, it is
+// returned as is.
+type interruption struct {
+	err error
+}
+
 func (p *parser) error(err interface{}) {
 	if s, ok := err.(string); ok {
 		err = errors.New(s)
@@ -375,6 +613,9 @@ func (p *parser) getPkg(id, name string) *types.Package {
 	if id == "unsafe" {
 		return types.Unsafe
 	}
+	if n, ok := p.names[id]; ok {
+		name = n
+	}
 
 	pkg := p.localPkgs[id]
 	if pkg == nil {
@@ -383,7 +624,12 @@ func (p *parser) getPkg(id, name string) *types.Package {
 		if pkg == nil {
 			// first import of id by this importer;
 			// add (possibly unnamed) pkg to shared packages
-			pkg = types.NewPackage(id, name)
+			if id != p.id {
+				pkg = p.imp.placeholder(id, name)
+			}
+			if pkg == nil {
+				pkg = types.NewPackage(id, name)
+			}
 			p.sharedPkgs[id] = pkg
 		}
 		// add (possibly unnamed) pkg to local packages
@@ -391,7 +637,7 @@ func (p *parser) getPkg(id, name string) *types.Package {
 			p.localPkgs = make(map[string]*types.Package)
 		}
 		p.localPkgs[id] = pkg
-	} else if name != "" {
+	} else if name != "" && !p.imp.isPlaceholder(pkg) {
 		// package exists already and we have an expected package name;
 		// make sure names match or set package name if necessary
 		if pname := pkg.Name(); pname == "" {
@@ -576,8 +822,13 @@ func (p *parser) parseParameter() (par *types.Var, isVariadic bool) {
 	if p.tok == scanner.String {
 		p.next()
 	}
-	// TODO(gri) should we provide a package?
-	par = types.NewVar(token.NoPos, nil, name, typ)
+	// named parameters belong to the imported package
+	// (as with the binary importer, blank names don't)
+	var pkg *types.Package
+	if name != "" && name != "_" {
+		pkg = p.sharedPkgs[p.id]
+	}
+	par = types.NewVar(token.NoPos, pkg, name, typ)
 	return
 }
 
@@ -978,6 +1229,26 @@ func (p *parser) parseDecl() {
 // Export        = "PackageClause { Decl } "$$" .
 // PackageClause = "package" PackageName [ "safe" ] "\n" .
 //
+// Header = "package" PackageName [ "safe" ] "\n" { ImportDecl "\n" } .
+//
+func (p *parser) parseHeader() (name string, imports []string) {
+	p.expectKeyword("package")
+	name = p.parsePackageName()
+	if p.tok == scanner.Ident && p.lit == "safe" {
+		// package was compiled with -u option - ignore
+		p.next()
+	}
+	p.expect('\n')
+
+	for p.tok == scanner.Ident && p.lit == "import" {
+		p.expectKeyword("import")
+		p.parsePackageName()
+		imports = append(imports, p.parsePackageId())
+		p.expect('\n')
+	}
+	return
+}
+
 func (p *parser) parseExport() *types.Package {
 	p.expectKeyword("package")
 	name := p.parsePackageName()
@@ -987,12 +1258,22 @@ func (p *parser) parseExport() *types.Package {
 	}
 	p.expect('\n')
 
+	// a package created here can be discarded if the import
+	// is interrupted (see ImportContext)
+	_, existed := p.sharedPkgs[p.id]
 	pkg := p.getPkg(p.id, name)
 
 	for p.tok != '$' && p.tok != scanner.EOF {
+		if err := p.imp.interrupted(); err != nil && !existed {
+			delete(p.sharedPkgs, p.id)
+			panic(interruption{err})
+		}
 		p.parseDecl()
 	}
 
+	if p.tok == scanner.EOF {
+		p.errorf("unexpected end of export data; want '$$'")
+	}
 	if ch := p.scanner.Peek(); p.tok != '$' || ch != '$' {
 		// don't call next()/expect() since reading past the
 		// export data may cause scanner errors (e.g. NUL chars)
@@ -427,3 +427,61 @@ func TestIssue15920(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// resultNames returns the names of the results of the function
+// or method named by sel ("F" or "T.M") in pkg.
+func resultNames(t *testing.T, pkg *types.Package, sel string) []string {
+	var obj types.Object
+	if i := strings.Index(sel, "."); i >= 0 {
+		tname := pkg.Scope().Lookup(sel[:i])
+		if tname == nil {
+			t.Fatalf("%s.%s not found", pkg.Path(), sel[:i])
+		}
+		obj, _, _ = types.LookupFieldOrMethod(tname.Type(), true, pkg, sel[i+1:])
+	} else {
+		obj = pkg.Scope().Lookup(sel)
+	}
+	fn, _ := obj.(*types.Func)
+	if fn == nil {
+		t.Fatalf("%s.%s is not a function", pkg.Path(), sel)
+	}
+	res := fn.Type().(*types.Signature).Results()
+	names := make([]string, res.Len())
+	for i := range names {
+		names[i] = res.At(i).Name()
+	}
+	return names
+}
+
+const resultNamesSrc = `package p
+type @"".T struct {}
+func (@"".t @"".T) Read(@"".p []byte) (@"".n int, @"".err error)
+func @"".Named() (@"".n int, @"".err error)
+func @"".Blank() (@""._ int, @"".err error)
+func @"".Unnamed() (? int, ? error)
+$$
+`
+
+func TestResultNames(t *testing.T) {
+	pkg, err := ImportData(make(map[string]*types.Package), "p.o", "p", strings.NewReader(resultNamesSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range resultNameTests {
+		got := fmt.Sprint(resultNames(t, pkg, test.sel))
+		if got != test.want {
+			t.Errorf("%s results: got %s; want %s", test.sel, got, test.want)
+		}
+	}
+}
+
+var resultNameTests = []struct {
+	sel  string
+	want string
+}{
+	{"T.Read", "[n err]"},
+	{"Named", "[n err]"},
+	{"Blank", "[_ err]"},
+	{"Unnamed", "[ ]"},
+}
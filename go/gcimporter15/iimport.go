@@ -0,0 +1,940 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.5
+
+// Indexed package import.
+// See cmd/compile/internal/gc/iexport.go for the export data format.
+
+package gcimporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"io"
+	"math/big"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Versions of the indexed export data format.
+const (
+	iexportVersionGo1_11   = 0 // initial version
+	iexportVersionPosCol   = 1 // positions record columns
+	iexportVersionGenerics = 2 // type parameters
+
+	iexportVersionCurrent = iexportVersionGenerics
+)
+
+// predeclReserved is the number of type offsets reserved for
+// the predeclared types; see predeclaredTypes.
+const predeclReserved = 32
+
+// blankMarker prefixes the export names of blank type parameters.
+const blankMarker = "$"
+
+// deltaNewFile is the line delta announcing a new file in
+// version 0 positions.
+const deltaNewFile = -64
+
+// Type tags of the indexed format.
+type itag uint64
+
+const (
+	definedType itag = iota
+	pointerType
+	sliceType
+	arrayType
+	chanType
+	mapType
+	signatureType
+	structType
+	interfaceType
+	typeParamType
+	instanceType
+	unionType
+)
+
+type intReader struct {
+	*bytes.Reader
+	path string
+}
+
+func (r *intReader) int64() int64 {
+	i, err := binary.ReadVarint(r.Reader)
+	if err != nil {
+		iformatErrorf(r.path, "read error: %v", err)
+	}
+	return i
+}
+
+func (r *intReader) uint64() uint64 {
+	i, err := binary.ReadUvarint(r.Reader)
+	if err != nil {
+		iformatErrorf(r.path, "read error: %v", err)
+	}
+	return i
+}
+
+type iimporter struct {
+	conf    *Importer
+	ipath   string
+	version int
+
+	stringData  []byte
+	stringCache map[uint64]string
+	pkgCache    map[uint64]*types.Package
+
+	declData    []byte
+	pkgIndex    map[*types.Package]map[string]uint64
+	typCache    map[uint64]types.Type
+	tparamIndex map[iident]types.Type
+
+	// aliases being declared, innermost last
+	aliases []iident
+
+	interfaceList []*types.Interface
+	constraints   []setConstraintArgs // deferred until all types are set up
+
+	fset  *token.FileSet
+	files map[string]*token.File
+}
+
+type iident struct {
+	pkg  *types.Package
+	name string
+}
+
+type setConstraintArgs struct {
+	tparam, constraint types.Type
+}
+
+// IImportData imports a package from the indexed export data, as
+// written by cmd/compile since Go 1.11, and returns the number of
+// bytes consumed and a reference to the package. The data starts
+// with the format byte 'i' following the "$$B\n" header. If data is
+// obviously malformed, an error is returned but in general it is not
+// recommended to call IImportData on untrusted data.
+func IImportData(fset *token.FileSet, imports map[string]*types.Package, data []byte, path string) (int, *types.Package, error) {
+	return new(Importer).iimportData(fset, imports, data, path)
+}
+
+// iimportData is like IImportData but subject to the configuration of imp.
+func (imp *Importer) iimportData(fset *token.FileSet, imports map[string]*types.Package, data []byte, path string) (n int, pkg *types.Package, err error) {
+	r := &intReader{bytes.NewReader(data), path}
+	declaring := false // decoding the declaration section
+	offset := func() int64 {
+		// offsets within the declaration section are not tracked
+		if declaring {
+			return -1
+		}
+		return int64(len(data) - r.Len())
+	}
+
+	defer func() {
+		switch e := recover().(type) {
+		case nil:
+			// nothing to do
+		case formatError:
+			n, pkg, err = len(data)-r.Len(), nil, &CorruptError{offset(), string(e)}
+		case *aliasCycleError:
+			n, pkg, err = len(data)-r.Len(), nil, e
+		case runtime.Error:
+			// undetected format error, such as an index out of range
+			n, pkg, err = len(data)-r.Len(), nil, &CorruptError{offset(), fmt.Sprintf("invalid export data for %s: %v", path, e)}
+		default:
+			panic(e) // internal error
+		}
+	}()
+
+	if format, _ := r.ReadByte(); format != 'i' {
+		return 0, nil, &CorruptError{0, fmt.Sprintf("invalid encoding format in export data: got %q; want 'i'", format)}
+	}
+	version := int64(r.uint64())
+	if version < iexportVersionGo1_11 || version > iexportVersionCurrent {
+		return len(data) - r.Len(), nil, &VersionError{fmt.Sprintf("v%d", version), iexportVersionGo1_11, iexportVersionCurrent}
+	}
+
+	sLen := int64(r.uint64())
+	dLen := int64(r.uint64())
+	whence := int64(len(data) - r.Len())
+	if sLen < 0 || dLen < 0 || whence+sLen+dLen > int64(len(data)) {
+		iformatErrorf(path, "section lengths %d and %d exceed data size", sLen, dLen)
+	}
+	stringData := data[whence : whence+sLen]
+	declData := data[whence+sLen : whence+sLen+dLen]
+	r.Reader = bytes.NewReader(data[whence+sLen+dLen:]) // skip sections
+
+	p := iimporter{
+		conf:    imp,
+		ipath:   path,
+		version: int(version),
+
+		stringData:  stringData,
+		stringCache: make(map[uint64]string),
+		pkgCache:    make(map[uint64]*types.Package),
+
+		declData:    declData,
+		pkgIndex:    make(map[*types.Package]map[string]uint64),
+		typCache:    make(map[uint64]types.Type),
+		tparamIndex: make(map[iident]types.Type),
+
+		fset:  fset,
+		files: make(map[string]*token.File),
+	}
+
+	predecl := predeclaredTypes(0)
+	if p.version >= iexportVersionGenerics {
+		predecl = predeclaredTypes(2)
+	}
+	for i, pt := range predecl {
+		p.typCache[uint64(i)] = pt
+	}
+
+	// read the main index; the first package is the one being imported,
+	// which can be discarded if the import is interrupted unless it
+	// existed before (see ImportContext)
+	_, existed := imports[path]
+	// each package takes at least 3 bytes of the index
+	npkgs := r.uint64()
+	if npkgs == 0 {
+		iformatErrorf(path, "no packages in index")
+	}
+	if npkgs > uint64(r.Len()/3) {
+		iformatErrorf(path, "invalid package count %d", npkgs)
+	}
+	pkgList := make([]*types.Package, npkgs)
+	for i := range pkgList {
+		pkgPathOff := r.uint64()
+		pkgPath := p.stringAt(pkgPathOff)
+		pkgName := p.stringAt(r.uint64())
+		_ = r.uint64() // package height; unused by go/types
+
+		if (pkgPath == "") != (i == 0) {
+			iformatErrorf(path, "package path %q for pkg index %d", pkgPath, i)
+		}
+		if pkgPath == "" {
+			pkgPath = path
+		}
+		if name, ok := imp.names()[pkgPath]; ok && pkgPath != "unsafe" {
+			pkgName = name
+		}
+		pkg := imports[pkgPath]
+		if pkg == nil {
+			if pkgPath == "unsafe" {
+				pkg = types.Unsafe
+			} else {
+				if i > 0 {
+					pkg = imp.placeholder(pkgPath, pkgName)
+				}
+				if pkg == nil {
+					pkg = types.NewPackage(pkgPath, pkgName)
+				}
+				imports[pkgPath] = pkg
+			}
+		} else if pkg.Name() != pkgName && !imp.isPlaceholder(pkg) {
+			iformatErrorf(path, "conflicting names %s and %s for package %q", pkg.Name(), pkgName, pkgPath)
+		}
+
+		p.pkgCache[pkgPathOff] = pkg
+
+		nameIndex := make(map[string]uint64)
+		for nSyms := r.uint64(); nSyms > 0; nSyms-- {
+			name := p.stringAt(r.uint64())
+			nameIndex[name] = r.uint64()
+		}
+		p.pkgIndex[pkg] = nameIndex
+		pkgList[i] = pkg
+	}
+
+	// declare the objects of the imported package, in name order
+	declaring = true
+	pkg = pkgList[0]
+	names := make([]string, 0, len(p.pkgIndex[pkg]))
+	for name := range p.pkgIndex[pkg] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := imp.interrupted(); err != nil && !existed {
+			delete(imports, path)
+			return len(data) - r.Len(), nil, err
+		}
+		p.doDecl(pkg, name)
+	}
+
+	for _, d := range p.constraints {
+		setConstraint(d.tparam, d.constraint)
+	}
+
+	// complete interfaces
+	for _, typ := range p.interfaceList {
+		typ.Complete()
+	}
+
+	// record all referenced packages as imports; the index lists
+	// every package the export data refers to, directly or not
+	list := make([]*types.Package, 0, len(pkgList)-1)
+	seen := make(map[*types.Package]bool)
+	for _, dep := range pkgList[1:] {
+		if dep != pkg && !seen[dep] {
+			seen[dep] = true
+			list = append(list, dep)
+		}
+	}
+	sort.Sort(byPath(list))
+	pkg.SetImports(list)
+
+	// package was imported completely and without errors
+	pkg.MarkComplete()
+
+	return len(data) - r.Len(), pkg, nil
+}
+
+// iformatErrorf panics with a formatError for the export data of path.
+func iformatErrorf(path, format string, args ...interface{}) {
+	panic(formatError(fmt.Sprintf("invalid export data for %s: ", path) + fmt.Sprintf(format, args...)))
+}
+
+func (p *iimporter) errorf(format string, args ...interface{}) {
+	iformatErrorf(p.ipath, format, args...)
+}
+
+func (p *iimporter) doDecl(pkg *types.Package, name string) {
+	// See if we've already imported this declaration.
+	if obj := pkg.Scope().Lookup(name); obj != nil {
+		return
+	}
+	for i, id := range p.aliases {
+		if id.pkg == pkg && id.name == name {
+			var names []string
+			for _, id := range p.aliases[i:] {
+				names = append(names, id.name)
+			}
+			panic(&aliasCycleError{append(names, name)})
+		}
+	}
+
+	off, ok := p.pkgIndex[pkg][name]
+	if !ok {
+		p.errorf("%v.%v not in index", pkg, name)
+	}
+	if off >= uint64(len(p.declData)) {
+		p.errorf("%v.%v has invalid offset %d", pkg, name, off)
+	}
+
+	r := &importReader{p: p, currPkg: pkg}
+	r.declReader = *bytes.NewReader(p.declData[off:])
+
+	r.obj(name)
+}
+
+func (p *iimporter) stringAt(off uint64) string {
+	if s, ok := p.stringCache[off]; ok {
+		return s
+	}
+
+	if off >= uint64(len(p.stringData)) {
+		p.errorf("invalid string offset %d", off)
+	}
+	slen, n := binary.Uvarint(p.stringData[off:])
+	if n <= 0 {
+		p.errorf("varint error at string offset %d", off)
+	}
+	spos := off + uint64(n)
+	if spos+slen > uint64(len(p.stringData)) {
+		p.errorf("string at offset %d exceeds string section", off)
+	}
+	s := string(p.stringData[spos : spos+slen])
+	p.stringCache[off] = s
+	return s
+}
+
+func (p *iimporter) pkgAt(off uint64) *types.Package {
+	if pkg, ok := p.pkgCache[off]; ok {
+		return pkg
+	}
+	p.errorf("missing package %q", p.stringAt(off))
+	return nil
+}
+
+func (p *iimporter) typAt(off uint64, base *types.Named) types.Type {
+	if t, ok := p.typCache[off]; ok && canReuse(base, t) {
+		return t
+	}
+
+	if off < predeclReserved {
+		p.errorf("predeclared type missing from cache: %d", off)
+	}
+	if off-predeclReserved >= uint64(len(p.declData)) {
+		p.errorf("invalid type offset %d", off)
+	}
+
+	r := &importReader{p: p}
+	r.declReader = *bytes.NewReader(p.declData[off-predeclReserved:])
+	t := r.doType(base)
+
+	if canReuse(base, t) {
+		p.typCache[off] = t
+	}
+	return t
+}
+
+// canReuse reports whether the type rhs on the RHS of the declaration
+// for def may be re-used.
+//
+// Specifically, if def is non-nil and rhs is an interface type with
+// methods, it may not be re-used because we have a convention of
+// "attaching" methods to interfaces via the receiver type of the
+// method signatures, as in bimport.go.
+func canReuse(def *types.Named, rhs types.Type) bool {
+	if def == nil {
+		return true
+	}
+	iface, _ := rhs.(*types.Interface)
+	if iface == nil {
+		return true
+	}
+	// Don't use iface.Empty() here as iface may not be complete.
+	return iface.NumEmbeddeds() == 0 && iface.NumExplicitMethods() == 0
+}
+
+type importReader struct {
+	p          *iimporter
+	declReader bytes.Reader
+	currPkg    *types.Package
+	prevFile   string
+	prevLine   int64
+	prevColumn int64
+}
+
+func (r *importReader) obj(name string) {
+	tag := r.byte()
+	pos := r.pos()
+
+	switch tag {
+	case 'A':
+		r.p.aliases = append(r.p.aliases, iident{r.currPkg, name})
+		typ := r.typ()
+		r.p.aliases = r.p.aliases[:len(r.p.aliases)-1]
+		r.declare(newAlias(pos, r.currPkg, name, typ, nil))
+
+	case 'C':
+		typ, val := r.value()
+		r.declare(types.NewConst(pos, r.currPkg, name, typ, val))
+
+	case 'F', 'G':
+		var tparams []types.Type
+		if tag == 'G' {
+			tparams = r.tparamList()
+		}
+		sig := r.signature(nil, nil, tparams)
+		r.declare(types.NewFunc(pos, r.currPkg, name, sig))
+
+	case 'T', 'U':
+		// Types can be recursive. We need to setup a stub
+		// declaration before recursing.
+		obj := types.NewTypeName(pos, r.currPkg, name, nil)
+		named := types.NewNamed(obj, nil, nil)
+		r.declare(obj)
+		if tag == 'U' {
+			setTypeParams(named, r.tparamList())
+		}
+
+		underlying := r.p.typAt(r.uint64(), named).Underlying()
+		named.SetUnderlying(underlying)
+
+		if !types.IsInterface(underlying) {
+			for n := r.uint64(); n > 0; n-- {
+				mpos := r.pos()
+				mname := r.ident()
+				recv := r.param()
+
+				// If the receiver has type arguments, they are
+				// the receiver type parameters of the method.
+				var rparams []types.Type
+				if base, ok := deref(recv.Type()).(*types.Named); ok {
+					_, rparams = instanceOf(base)
+				}
+				msig := r.signature(recv, rparams, nil)
+
+				named.AddMethod(types.NewFunc(mpos, r.currPkg, mname, msig))
+			}
+		}
+
+	case 'P':
+		// We need to "declare" a type parameter in order to have a
+		// name that can be referenced recursively (if needed) in the
+		// type parameter's constraint.
+		if r.p.version < iexportVersionGenerics {
+			r.p.errorf("unexpected type parameter %s", name)
+		}
+		tn := types.NewTypeName(pos, r.currPkg, tparamName(name), nil)
+		t := newTypeParam(tn)
+		// To handle recursive references to the type parameter within
+		// its constraint, save the partial type before reading it.
+		r.p.tparamIndex[iident{r.currPkg, name}] = t
+		implicit := r.bool()
+		constraint := r.typ()
+		if implicit {
+			iface, _ := constraint.(*types.Interface)
+			if iface == nil {
+				r.p.errorf("non-interface constraint marked implicit")
+			}
+			iface = newInterface(explicitMethods(iface), embeddedTypes(iface), true)
+			r.p.interfaceList = append(r.p.interfaceList, iface)
+			constraint = iface
+		}
+		// The constraint may not be complete if we are in the middle
+		// of a type recursion involving constraints, so setting it is
+		// deferred until all types are set up.
+		r.p.constraints = append(r.p.constraints, setConstraintArgs{t, constraint})
+
+	case 'V':
+		typ := r.typ()
+		r.declare(types.NewVar(pos, r.currPkg, name, typ))
+
+	default:
+		r.p.errorf("unexpected tag: %v", tag)
+	}
+}
+
+// tparamName returns the name of the type parameter recorded as
+// exportName in the index, stripping the prefix that makes it unique.
+func tparamName(exportName string) string {
+	name := exportName[strings.LastIndex(exportName, ".")+1:]
+	if strings.HasPrefix(name, blankMarker) {
+		return "_"
+	}
+	return name
+}
+
+func explicitMethods(t *types.Interface) []*types.Func {
+	methods := make([]*types.Func, t.NumExplicitMethods())
+	for i := range methods {
+		methods[i] = t.ExplicitMethod(i)
+	}
+	return methods
+}
+
+func (r *importReader) declare(obj types.Object) {
+	obj.Pkg().Scope().Insert(obj)
+}
+
+func (r *importReader) value() (typ types.Type, val constant.Value) {
+	typ = r.typ()
+
+	b, ok := typ.Underlying().(*types.Basic)
+	if !ok {
+		r.p.errorf("unexpected constant type %v", typ)
+	}
+	switch b.Info() & types.IsConstType {
+	case types.IsBoolean:
+		val = constant.MakeBool(r.bool())
+	case types.IsString:
+		val = constant.MakeString(r.string())
+	case types.IsInteger:
+		var x big.Int
+		r.mpint(&x, b)
+		val = intValue(&x)
+	case types.IsFloat:
+		val = r.mpfloat(b)
+	case types.IsComplex:
+		re := r.mpfloat(b)
+		im := r.mpfloat(b)
+		val = constant.BinaryOp(re, token.ADD, constant.MakeImag(im))
+	default:
+		if b.Kind() == types.Invalid {
+			val = constant.MakeUnknown()
+			return
+		}
+		r.p.errorf("unexpected type %v", typ)
+	}
+	return
+}
+
+// intSize returns whether constants of type b are signed and the
+// maximum number of bytes of their encoded magnitude.
+func intSize(b *types.Basic) (signed bool, maxBytes uint) {
+	if (b.Info() & types.IsUntyped) != 0 {
+		return true, 64
+	}
+
+	switch b.Kind() {
+	case types.Float32, types.Complex64:
+		return true, 3
+	case types.Float64, types.Complex128:
+		return true, 7
+	}
+
+	signed = (b.Info() & types.IsUnsigned) == 0
+	switch b.Kind() {
+	case types.Int8, types.Uint8:
+		maxBytes = 1
+	case types.Int16, types.Uint16:
+		maxBytes = 2
+	case types.Int32, types.Uint32:
+		maxBytes = 4
+	default:
+		maxBytes = 8
+	}
+
+	return
+}
+
+func (r *importReader) mpint(x *big.Int, typ *types.Basic) {
+	signed, maxBytes := intSize(typ)
+
+	maxSmall := 256 - maxBytes
+	if signed {
+		maxSmall = 256 - 2*maxBytes
+	}
+	if maxBytes == 1 {
+		maxSmall = 256
+	}
+
+	n := r.byte()
+	if uint(n) < maxSmall {
+		v := int64(n)
+		if signed {
+			v >>= 1
+			if n&1 != 0 {
+				v = ^v
+			}
+		}
+		x.SetInt64(v)
+		return
+	}
+
+	v := -n
+	if signed {
+		v = -(n &^ 1) >> 1
+	}
+	if v < 1 || uint(v) > maxBytes {
+		r.p.errorf("weird decoding: %v, %v => %v", n, signed, v)
+	}
+	b := make([]byte, v)
+	if _, err := io.ReadFull(&r.declReader, b); err != nil {
+		r.p.errorf("read error: %v", err)
+	}
+	x.SetBytes(b)
+	if signed && n&1 != 0 {
+		x.Neg(x)
+	}
+}
+
+func (r *importReader) mpfloat(typ *types.Basic) constant.Value {
+	var mant big.Int
+	r.mpint(&mant, typ)
+	x := intValue(&mant)
+	if mant.Sign() == 0 {
+		return x
+	}
+
+	// x * 2**exp; constant.Make, which accepts a *big.Float, requires go1.13
+	exp := r.int64()
+	if exp < -maxFloatExp || exp > maxFloatExp {
+		r.p.errorf("float exponent %d out of range", exp)
+	}
+	switch {
+	case exp < 0:
+		d := constant.Shift(constant.MakeInt64(1), token.SHL, uint(-exp))
+		x = constant.BinaryOp(x, token.QUO, d)
+	case exp > 0:
+		x = constant.Shift(x, token.SHL, uint(exp))
+	}
+	return x
+}
+
+// intValue returns the constant value of x.
+func intValue(x *big.Int) constant.Value {
+	val := constant.MakeFromLiteral(new(big.Int).Abs(x).String(), token.INT, 0)
+	if x.Sign() < 0 {
+		val = constant.UnaryOp(token.SUB, val, 0)
+	}
+	return val
+}
+
+func (r *importReader) ident() string {
+	return r.string()
+}
+
+func (r *importReader) qualifiedIdent() (*types.Package, string) {
+	name := r.string()
+	pkg := r.pkg()
+	return pkg, name
+}
+
+func (r *importReader) pos() token.Pos {
+	if r.p.version >= iexportVersionPosCol {
+		r.posv1()
+	} else {
+		r.posv0()
+	}
+
+	if r.prevFile == "" && r.prevLine == 0 && r.prevColumn == 0 {
+		return token.NoPos
+	}
+	if r.p.fset == nil {
+		return token.NoPos
+	}
+
+	f := r.p.files[r.prevFile]
+	if f == nil {
+		f = fakeFile(r.p.fset, r.p.conf.posFilename(r.prevFile))
+		r.p.files[r.prevFile] = f
+	}
+
+	// Columns are not represented in the fake file; see importer.pos.
+	line := r.prevLine
+	if line < 1 || line > maxlines {
+		line = 1
+	}
+	return f.Pos(int(line) - 1)
+}
+
+func (r *importReader) posv0() {
+	delta := r.int64()
+	if delta != deltaNewFile {
+		r.prevLine += delta
+	} else if l := r.int64(); l == -1 {
+		r.prevLine += deltaNewFile
+	} else {
+		r.prevFile = r.string()
+		r.prevLine = l
+	}
+}
+
+func (r *importReader) posv1() {
+	delta := r.int64()
+	r.prevColumn += delta >> 1
+	if delta&1 != 0 {
+		delta = r.int64()
+		r.prevLine += delta >> 1
+		if delta&1 != 0 {
+			r.prevFile = r.string()
+		}
+	}
+}
+
+func (r *importReader) typ() types.Type {
+	return r.p.typAt(r.uint64(), nil)
+}
+
+func (r *importReader) doType(base *types.Named) types.Type {
+	switch k := r.kind(); k {
+	default:
+		r.p.errorf("unexpected kind tag in %q: %v", r.p.ipath, k)
+		return nil
+
+	case definedType:
+		pkg, name := r.qualifiedIdent()
+		r.p.doDecl(pkg, name)
+		obj, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok {
+			r.p.errorf("%v.%v is not a type", pkg, name)
+		}
+		return obj.Type()
+	case pointerType:
+		return types.NewPointer(r.typ())
+	case sliceType:
+		return types.NewSlice(r.typ())
+	case arrayType:
+		n := r.uint64()
+		return types.NewArray(r.typ(), int64(n))
+	case chanType:
+		dir := r.chanDir(r.uint64())
+		return types.NewChan(dir, r.typ())
+	case mapType:
+		return types.NewMap(r.typ(), r.typ())
+	case signatureType:
+		r.currPkg = r.pkg()
+		return r.signature(nil, nil, nil)
+
+	case structType:
+		r.currPkg = r.pkg()
+
+		fields := make([]*types.Var, r.uint64())
+		tags := make([]string, len(fields))
+		for i := range fields {
+			fpos := r.pos()
+			fname := r.ident()
+			ftyp := r.typ()
+			emb := r.bool()
+			tag := r.string()
+
+			fields[i] = types.NewField(fpos, r.currPkg, fname, ftyp, emb)
+			tags[i] = tag
+		}
+		return types.NewStruct(fields, tags)
+
+	case interfaceType:
+		r.currPkg = r.pkg()
+
+		embeddeds := make([]types.Type, r.uint64())
+		for i := range embeddeds {
+			_ = r.pos()
+			embeddeds[i] = r.typ()
+		}
+
+		methods := make([]*types.Func, r.uint64())
+		for i := range methods {
+			mpos := r.pos()
+			mname := r.ident()
+
+			// As in bimport.go, the receiver of an interface
+			// method is the named interface type, if any.
+			var recv *types.Var
+			if base != nil {
+				recv = types.NewVar(token.NoPos, r.currPkg, "", base)
+			}
+
+			msig := r.signature(recv, nil, nil)
+			methods[i] = types.NewFunc(mpos, r.currPkg, mname, msig)
+		}
+
+		typ := newInterface(methods, embeddeds, false)
+		r.p.interfaceList = append(r.p.interfaceList, typ)
+		return typ
+
+	case typeParamType:
+		if r.p.version < iexportVersionGenerics {
+			r.p.errorf("unexpected type parameter type")
+		}
+		pkg, name := r.qualifiedIdent()
+		id := iident{pkg, name}
+		if t, ok := r.p.tparamIndex[id]; ok {
+			// We're already in the process of importing this
+			// type parameter.
+			return t
+		}
+		// Otherwise, import the declaration of the type parameter.
+		r.p.doDecl(pkg, name)
+		t, ok := r.p.tparamIndex[id]
+		if !ok {
+			r.p.errorf("%v.%v is not a type parameter", pkg, name)
+		}
+		return t
+
+	case instanceType:
+		if r.p.version < iexportVersionGenerics {
+			r.p.errorf("unexpected instantiation type")
+		}
+		// pos does not matter for instances: they are
+		// positioned on the original type
+		_ = r.pos()
+		targs := make([]types.Type, r.uint64())
+		for i := range targs {
+			targs[i] = r.typ()
+		}
+		baseType := r.typ()
+		return instantiate(baseType, targs)
+
+	case unionType:
+		if r.p.version < iexportVersionGenerics {
+			r.p.errorf("unexpected union type")
+		}
+		n := r.uint64()
+		terms := make([]types.Type, n)
+		tilde := make([]bool, n)
+		for i := range terms {
+			tilde[i] = r.bool()
+			terms[i] = r.typ()
+		}
+		return newUnion(terms, tilde)
+	}
+}
+
+func (r *importReader) kind() itag {
+	return itag(r.uint64())
+}
+
+func (r *importReader) chanDir(d uint64) types.ChanDir {
+	// tag values must match the constants in cmd/compile/internal/types
+	switch d {
+	case 1:
+		return types.RecvOnly
+	case 2:
+		return types.SendOnly
+	case 3:
+		return types.SendRecv
+	default:
+		r.p.errorf("unexpected channel dir %d", d)
+		return 0
+	}
+}
+
+func (r *importReader) signature(recv *types.Var, rparams, tparams []types.Type) *types.Signature {
+	params := r.paramList()
+	results := r.paramList()
+	variadic := params.Len() > 0 && r.bool()
+	return newSignature(recv, rparams, tparams, params, results, variadic)
+}
+
+func (r *importReader) tparamList() []types.Type {
+	xs := make([]types.Type, r.uint64())
+	for i := range xs {
+		xs[i] = r.typ()
+		if !isTypeParam(xs[i]) {
+			r.p.errorf("%v is not a type parameter", xs[i])
+		}
+	}
+	return xs
+}
+
+func (r *importReader) paramList() *types.Tuple {
+	xs := make([]*types.Var, r.uint64())
+	for i := range xs {
+		xs[i] = r.param()
+	}
+	return types.NewTuple(xs...)
+}
+
+func (r *importReader) param() *types.Var {
+	pos := r.pos()
+	name := r.ident()
+	typ := r.typ()
+	return types.NewParam(pos, r.currPkg, name, typ)
+}
+
+func (r *importReader) bool() bool {
+	return r.uint64() != 0
+}
+
+func (r *importReader) int64() int64 {
+	n, err := binary.ReadVarint(&r.declReader)
+	if err != nil {
+		r.p.errorf("readVarint: %v", err)
+	}
+	return n
+}
+
+func (r *importReader) uint64() uint64 {
+	n, err := binary.ReadUvarint(&r.declReader)
+	if err != nil {
+		r.p.errorf("readUvarint: %v", err)
+	}
+	return n
+}
+
+func (r *importReader) byte() byte {
+	x, err := r.declReader.ReadByte()
+	if err != nil {
+		r.p.errorf("declReader.ReadByte: %v", err)
+	}
+	return x
+}
+
+func (r *importReader) string() string {
+	return r.p.stringAt(r.uint64())
+}
+
+func (r *importReader) pkg() *types.Package {
+	return r.p.pkgAt(r.uint64())
+}
@@ -0,0 +1,782 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.18
+
+package gcimporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// iexportData returns the indexed export data of pkg in the given
+// format version, following the "$$B\n" header, as written by
+// cmd/compile. Only the declarations needed by go/types are written;
+// there are no inline bodies.
+func iexportData(fset *token.FileSet, pkg *types.Package, version int) []byte {
+	p := &iexporter{
+		fset:        fset,
+		version:     version,
+		localpkg:    pkg,
+		allPkgs:     make(map[*types.Package]bool),
+		stringIndex: make(map[string]uint64),
+		declIndex:   make(map[types.Object]uint64),
+		tparamNames: make(map[types.Object]string),
+		typIndex:    make(map[types.Type]uint64),
+	}
+	predecl := predeclaredTypes(0)
+	if version >= iexportVersionGenerics {
+		predecl = predeclaredTypes(2)
+	}
+	for i, pt := range predecl {
+		p.typIndex[pt] = uint64(i)
+	}
+
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		if ast.IsExported(name) {
+			p.pushDecl(scope.Lookup(name))
+		}
+	}
+	for len(p.declTodo) > 0 {
+		obj := p.declTodo[0]
+		p.declTodo = p.declTodo[1:]
+		p.doDecl(obj)
+	}
+
+	dataLen := uint64(p.data0.Len())
+	w := p.newWriter()
+	w.writeIndex()
+	index := w.data.Bytes()
+
+	var hdr bytes.Buffer
+	hdr.WriteByte('i')
+	for _, x := range []uint64{uint64(version), uint64(p.strings.Len()), dataLen} {
+		writeUvarint(&hdr, x)
+	}
+	hdr.Write(p.strings.Bytes())
+	hdr.Write(p.data0.Bytes())
+	hdr.Write(index)
+	return hdr.Bytes()
+}
+
+type iexporter struct {
+	fset     *token.FileSet
+	version  int
+	localpkg *types.Package
+	allPkgs  map[*types.Package]bool
+	declTodo []types.Object
+
+	strings     bytes.Buffer
+	stringIndex map[string]uint64
+
+	data0       bytes.Buffer
+	declIndex   map[types.Object]uint64
+	tparamNames map[types.Object]string
+	typIndex    map[types.Type]uint64
+}
+
+func (p *iexporter) stringOff(s string) uint64 {
+	off, ok := p.stringIndex[s]
+	if !ok {
+		off = uint64(p.strings.Len())
+		p.stringIndex[s] = off
+		writeUvarint(&p.strings, uint64(len(s)))
+		p.strings.WriteString(s)
+	}
+	return off
+}
+
+func (p *iexporter) pushDecl(obj types.Object) {
+	if _, ok := p.declIndex[obj]; ok {
+		return
+	}
+	p.declIndex[obj] = ^uint64(0) // in work queue
+	p.declTodo = append(p.declTodo, obj)
+}
+
+func (p *iexporter) exportName(obj types.Object) string {
+	if name, ok := p.tparamNames[obj]; ok {
+		return name
+	}
+	return obj.Name()
+}
+
+func (p *iexporter) newWriter() *iexportWriter {
+	return &iexportWriter{p: p}
+}
+
+func (p *iexporter) doDecl(obj types.Object) {
+	w := p.newWriter()
+	switch obj := obj.(type) {
+	case *types.Var:
+		w.tag('V')
+		w.pos(obj.Pos())
+		w.typ(obj.Type(), obj.Pkg())
+
+	case *types.Func:
+		sig := obj.Type().(*types.Signature)
+		if sig.TypeParams().Len() > 0 {
+			w.tag('G')
+			w.pos(obj.Pos())
+			w.tparamList(obj.Name(), sig.TypeParams(), obj.Pkg())
+		} else {
+			w.tag('F')
+			w.pos(obj.Pos())
+		}
+		w.signature(sig)
+
+	case *types.Const:
+		w.tag('C')
+		w.pos(obj.Pos())
+		w.value(obj.Type(), obj.Val())
+
+	case *types.TypeName:
+		if tparam, ok := obj.Type().(*types.TypeParam); ok {
+			w.tag('P')
+			w.pos(obj.Pos())
+			iface, _ := tparam.Constraint().(*types.Interface)
+			w.bool(iface != nil && iface.IsImplicit())
+			w.typ(tparam.Constraint(), obj.Pkg())
+			break
+		}
+
+		if obj.IsAlias() {
+			w.tag('A')
+			w.pos(obj.Pos())
+			w.typ(types.Unalias(obj.Type()), obj.Pkg())
+			break
+		}
+
+		named := obj.Type().(*types.Named)
+		if named.TypeParams().Len() > 0 {
+			w.tag('U')
+			w.pos(obj.Pos())
+			w.tparamList(obj.Name(), named.TypeParams(), obj.Pkg())
+		} else {
+			w.tag('T')
+			w.pos(obj.Pos())
+		}
+		w.typ(named.Underlying(), obj.Pkg())
+		if types.IsInterface(named) {
+			break
+		}
+		w.uint64(uint64(named.NumMethods()))
+		for i := 0; i < named.NumMethods(); i++ {
+			m := named.Method(i)
+			sig := m.Type().(*types.Signature)
+			rparams := sig.RecvTypeParams()
+			for i := 0; i < rparams.Len(); i++ {
+				rparam := rparams.At(i).Obj()
+				p.tparamNames[rparam] = obj.Name() + "." + m.Name() + "." + rparam.Name()
+			}
+			w.pos(m.Pos())
+			w.string(m.Name())
+			w.param(sig.Recv())
+			w.signature(sig)
+		}
+
+	default:
+		panic(fmt.Sprintf("unexpected object: %v", obj))
+	}
+	p.declIndex[obj] = w.flush()
+}
+
+type iexportWriter struct {
+	p          *iexporter
+	data       bytes.Buffer
+	prevFile   string
+	prevLine   int64
+	prevColumn int64
+}
+
+// flush appends the data written by w to the declaration section
+// and returns its offset.
+func (w *iexportWriter) flush() uint64 {
+	off := uint64(w.p.data0.Len())
+	w.p.data0.Write(w.data.Bytes())
+	return off
+}
+
+func (w *iexportWriter) writeIndex() {
+	pkgObjs := map[*types.Package][]types.Object{w.p.localpkg: nil}
+	for pkg := range w.p.allPkgs {
+		pkgObjs[pkg] = nil
+	}
+	for obj := range w.p.declIndex {
+		pkgObjs[obj.Pkg()] = append(pkgObjs[obj.Pkg()], obj)
+	}
+	var pkgs []*types.Package
+	for pkg, objs := range pkgObjs {
+		pkgs = append(pkgs, pkg)
+		sort.Slice(objs, func(i, j int) bool {
+			return w.p.exportName(objs[i]) < w.p.exportName(objs[j])
+		})
+	}
+	sort.Slice(pkgs, func(i, j int) bool {
+		return w.exportPath(pkgs[i]) < w.exportPath(pkgs[j])
+	})
+
+	w.uint64(uint64(len(pkgs)))
+	for _, pkg := range pkgs {
+		w.string(w.exportPath(pkg))
+		w.string(pkg.Name())
+		w.uint64(0) // package height
+		objs := pkgObjs[pkg]
+		w.uint64(uint64(len(objs)))
+		for _, obj := range objs {
+			w.string(w.p.exportName(obj))
+			w.uint64(w.p.declIndex[obj])
+		}
+	}
+}
+
+func (w *iexportWriter) exportPath(pkg *types.Package) string {
+	if pkg == w.p.localpkg {
+		return ""
+	}
+	return pkg.Path()
+}
+
+func (w *iexportWriter) tag(tag byte) { w.data.WriteByte(tag) }
+
+func (w *iexportWriter) bool(b bool) {
+	var x uint64
+	if b {
+		x = 1
+	}
+	w.uint64(x)
+}
+
+func (w *iexportWriter) int64(x int64) {
+	var buf [binary.MaxVarintLen64]byte
+	w.data.Write(buf[:binary.PutVarint(buf[:], x)])
+}
+
+func (w *iexportWriter) uint64(x uint64) { writeUvarint(&w.data, x) }
+func (w *iexportWriter) string(s string) { w.uint64(w.p.stringOff(s)) }
+
+func writeUvarint(b *bytes.Buffer, x uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	b.Write(buf[:binary.PutUvarint(buf[:], x)])
+}
+
+func (w *iexportWriter) pkg(pkg *types.Package) {
+	w.p.allPkgs[pkg] = true
+	w.string(w.exportPath(pkg))
+}
+
+func (w *iexportWriter) qualifiedIdent(obj types.Object) {
+	w.p.pushDecl(obj)
+	w.string(w.p.exportName(obj))
+	w.pkg(obj.Pkg())
+}
+
+func (w *iexportWriter) pos(pos token.Pos) {
+	if w.p.version < iexportVersionPosCol {
+		w.posv0(pos)
+		return
+	}
+	p := w.p.fset.Position(pos)
+	file, line, column := p.Filename, int64(p.Line), int64(p.Column)
+
+	deltaColumn := (column - w.prevColumn) << 1
+	deltaLine := (line - w.prevLine) << 1
+	if file != w.prevFile {
+		deltaLine |= 1
+	}
+	if deltaLine != 0 {
+		deltaColumn |= 1
+	}
+	w.int64(deltaColumn)
+	if deltaColumn&1 != 0 {
+		w.int64(deltaLine)
+		if deltaLine&1 != 0 {
+			w.string(file)
+		}
+	}
+	w.prevFile, w.prevLine, w.prevColumn = file, line, column
+}
+
+func (w *iexportWriter) posv0(pos token.Pos) {
+	p := w.p.fset.Position(pos)
+	file, line := p.Filename, int64(p.Line)
+	if file == w.prevFile {
+		delta := line - w.prevLine
+		w.int64(delta)
+		if delta == deltaNewFile {
+			w.int64(-1)
+		}
+	} else {
+		w.int64(deltaNewFile)
+		w.int64(line) // line >= 0
+		w.string(file)
+		w.prevFile = file
+	}
+	w.prevLine = line
+}
+
+func (w *iexportWriter) typ(t types.Type, pkg *types.Package) {
+	w.uint64(w.p.typOff(t, pkg))
+}
+
+func (p *iexporter) typOff(t types.Type, pkg *types.Package) uint64 {
+	off, ok := p.typIndex[t]
+	if !ok {
+		w := p.newWriter()
+		w.doTyp(t, pkg)
+		off = predeclReserved + w.flush()
+		p.typIndex[t] = off
+	}
+	return off
+}
+
+func (w *iexportWriter) doTyp(t types.Type, pkg *types.Package) {
+	if a, ok := t.(*types.Alias); ok {
+		w.typ(types.Unalias(a), pkg)
+		return
+	}
+	switch t := t.(type) {
+	case *types.Named:
+		if targs := t.TypeArgs(); targs.Len() > 0 {
+			w.uint64(uint64(instanceType))
+			w.pos(t.Obj().Pos())
+			w.uint64(uint64(targs.Len()))
+			for i := 0; i < targs.Len(); i++ {
+				w.typ(targs.At(i), pkg)
+			}
+			w.typ(t.Origin(), pkg)
+			return
+		}
+		w.uint64(uint64(definedType))
+		w.qualifiedIdent(t.Obj())
+
+	case *types.TypeParam:
+		w.uint64(uint64(typeParamType))
+		w.qualifiedIdent(t.Obj())
+
+	case *types.Pointer:
+		w.uint64(uint64(pointerType))
+		w.typ(t.Elem(), pkg)
+
+	case *types.Slice:
+		w.uint64(uint64(sliceType))
+		w.typ(t.Elem(), pkg)
+
+	case *types.Array:
+		w.uint64(uint64(arrayType))
+		w.uint64(uint64(t.Len()))
+		w.typ(t.Elem(), pkg)
+
+	case *types.Chan:
+		w.uint64(uint64(chanType))
+		dir := map[types.ChanDir]uint64{types.RecvOnly: 1, types.SendOnly: 2, types.SendRecv: 3}[t.Dir()]
+		w.uint64(dir)
+		w.typ(t.Elem(), pkg)
+
+	case *types.Map:
+		w.uint64(uint64(mapType))
+		w.typ(t.Key(), pkg)
+		w.typ(t.Elem(), pkg)
+
+	case *types.Signature:
+		w.uint64(uint64(signatureType))
+		w.pkg(pkg)
+		w.signature(t)
+
+	case *types.Struct:
+		w.uint64(uint64(structType))
+		w.pkg(pkg)
+		w.uint64(uint64(t.NumFields()))
+		for i := 0; i < t.NumFields(); i++ {
+			f := t.Field(i)
+			w.pos(f.Pos())
+			w.string(f.Name())
+			w.typ(f.Type(), pkg)
+			w.bool(f.Anonymous())
+			w.string(t.Tag(i))
+		}
+
+	case *types.Interface:
+		w.uint64(uint64(interfaceType))
+		w.pkg(pkg)
+		w.uint64(uint64(t.NumEmbeddeds()))
+		for i := 0; i < t.NumEmbeddeds(); i++ {
+			e := t.EmbeddedType(i)
+			if named, ok := e.(*types.Named); ok {
+				w.pos(named.Obj().Pos())
+			} else {
+				w.pos(token.NoPos)
+			}
+			w.typ(e, pkg)
+		}
+		w.uint64(uint64(t.NumExplicitMethods()))
+		for i := 0; i < t.NumExplicitMethods(); i++ {
+			m := t.ExplicitMethod(i)
+			w.pos(m.Pos())
+			w.string(m.Name())
+			w.signature(m.Type().(*types.Signature))
+		}
+
+	case *types.Union:
+		w.uint64(uint64(unionType))
+		w.uint64(uint64(t.Len()))
+		for i := 0; i < t.Len(); i++ {
+			w.bool(t.Term(i).Tilde())
+			w.typ(t.Term(i).Type(), pkg)
+		}
+
+	default:
+		panic(fmt.Sprintf("unexpected type: %v (%T)", t, t))
+	}
+}
+
+func (w *iexportWriter) tparamList(prefix string, list *types.TypeParamList, pkg *types.Package) {
+	w.uint64(uint64(list.Len()))
+	for i := 0; i < list.Len(); i++ {
+		tparam := list.At(i)
+		w.p.tparamNames[tparam.Obj()] = prefix + "." + tparam.Obj().Name()
+		w.typ(tparam, pkg)
+	}
+}
+
+func (w *iexportWriter) signature(sig *types.Signature) {
+	w.paramList(sig.Params())
+	w.paramList(sig.Results())
+	if sig.Params().Len() > 0 {
+		w.bool(sig.Variadic())
+	}
+}
+
+func (w *iexportWriter) paramList(tup *types.Tuple) {
+	w.uint64(uint64(tup.Len()))
+	for i := 0; i < tup.Len(); i++ {
+		w.param(tup.At(i))
+	}
+}
+
+func (w *iexportWriter) param(obj *types.Var) {
+	w.pos(obj.Pos())
+	w.string(obj.Name())
+	w.typ(obj.Type(), obj.Pkg())
+}
+
+func (w *iexportWriter) value(typ types.Type, v constant.Value) {
+	w.typ(typ, nil)
+	b := typ.Underlying().(*types.Basic)
+	switch b.Info() & types.IsConstType {
+	case types.IsBoolean:
+		w.bool(constant.BoolVal(v))
+	case types.IsInteger:
+		var i big.Int
+		i.SetString(v.ExactString(), 10)
+		w.mpint(&i, b)
+	case types.IsFloat:
+		w.mpfloat(constantToFloat(v), b)
+	case types.IsComplex:
+		w.mpfloat(constantToFloat(constant.Real(v)), b)
+		w.mpfloat(constantToFloat(constant.Imag(v)), b)
+	case types.IsString:
+		w.string(constant.StringVal(v))
+	}
+}
+
+func constantToFloat(x constant.Value) *big.Float {
+	var f big.Float
+	f.SetPrec(512)
+	switch v := constant.Val(constant.ToFloat(x)).(type) {
+	case float64:
+		f.SetFloat64(v)
+	case *big.Rat:
+		f.SetRat(v)
+	case *big.Float:
+		f.Set(v)
+	}
+	return &f
+}
+
+func (w *iexportWriter) mpint(x *big.Int, b *types.Basic) {
+	signed, maxBytes := intSize(b)
+	negative := x.Sign() < 0
+	mag := x.Bytes()
+
+	maxSmall := 256 - maxBytes
+	if signed {
+		maxSmall = 256 - 2*maxBytes
+	}
+	if maxBytes == 1 {
+		maxSmall = 256
+	}
+
+	if len(mag) <= 1 {
+		var ux uint
+		if len(mag) == 1 {
+			ux = uint(mag[0])
+		}
+		if signed {
+			ux <<= 1
+			if negative {
+				ux--
+			}
+		}
+		if ux < maxSmall {
+			w.data.WriteByte(byte(ux))
+			return
+		}
+	}
+
+	n := 256 - uint(len(mag))
+	if signed {
+		n = 256 - 2*uint(len(mag))
+		if negative {
+			n |= 1
+		}
+	}
+	w.data.WriteByte(byte(n))
+	w.data.Write(mag)
+}
+
+func (w *iexportWriter) mpfloat(f *big.Float, b *types.Basic) {
+	// Break into f = mant × 2**exp, with 0.5 <= mant < 1,
+	// and scale so that mant is an integer.
+	var mant big.Float
+	exp := int64(f.MantExp(&mant))
+	prec := mant.MinPrec()
+	mant.SetMantExp(&mant, int(prec))
+	exp -= int64(prec)
+
+	manti, _ := mant.Int(nil)
+	w.mpint(manti, b)
+	if manti.Sign() != 0 {
+		w.int64(exp)
+	}
+}
+
+const iimportSrc = `package p
+
+import "io"
+
+const (
+	B    = true
+	I    = -1 << 70
+	I8   int8 = -128
+	U64  uint64 = 1<<64 - 1
+	Fl   = 1.5e300
+	F32  float32 = 3.14
+	Pi   = 3.14159265358979323846264338327950288419716939937510582097494459
+	C    = 2 - 3i
+	S    = "hello"
+	R    = 'x'
+)
+
+type (
+	T struct {
+		io.Reader
+		X, y int ` + "`json:\"x\"`" + `
+		Next *T
+	}
+	A   = map[string][]*T
+	Ch  chan<- <-chan [4]byte
+	Fn  func(string, ...interface{}) (int, error)
+	RW  interface {
+		io.ReadWriter
+		Close() error
+	}
+)
+
+func (t *T) M(x, _ int, rest ...string) (n int, err error) { return }
+func (T) V() {}
+
+var V struct{ T }
+
+func F(RW) *T { return nil }
+
+type Number interface{ ~int | ~float64 }
+
+type List[E any] struct {
+	next *List[E]
+	Val  E
+}
+
+func (l *List[E]) Push(v E) *List[E] { return l }
+
+func Sum[N Number](xs ...N) N { var s N; return s }
+
+func Keys[M ~map[K]V, K comparable, V any](m M) []K { return nil }
+
+var L List[string]
+`
+
+func TestIImportData(t *testing.T) {
+	fset := token.NewFileSet()
+	pkg := typecheck(t, fset, "p", iimportSrc, ioPackage(t))
+	data := iexportData(fset, pkg, iexportVersionGenerics)
+
+	ifset := token.NewFileSet()
+	n, imported, err := IImportData(ifset, make(map[string]*types.Package), data, "p")
+	if err != nil {
+		t.Fatalf("IImportData: %v", err)
+	}
+	if n != len(data) {
+		t.Errorf("consumed %d bytes; want %d", n, len(data))
+	}
+	got := strings.Join(objectStrings(ifset, imported), "\n")
+	if want := strings.Join(objectStrings(fset, pkg), "\n"); got != want {
+		t.Errorf("imported objects:\n%s\nwant:\n%s", got, want)
+	}
+	for _, name := range pkg.Scope().Names() {
+		if c, ok := pkg.Scope().Lookup(name).(*types.Const); ok {
+			got := imported.Scope().Lookup(name).(*types.Const).Val()
+			want := c.Val()
+			if want.Kind() == constant.Float {
+				// floats are exported with 512-bit mantissas
+				want = constant.Make(constantToFloat(want))
+			}
+			if !constant.Compare(got, token.EQL, want) {
+				t.Errorf("%s = %s; want %s", name, got.ExactString(), want.ExactString())
+			}
+		}
+	}
+	if !imported.Complete() {
+		t.Errorf("package not complete")
+	}
+}
+
+func TestIImportPositions(t *testing.T) {
+	const src = "package p\n\nvar X int\n\n\nfunc F(a, b string) {}\n"
+	fset := token.NewFileSet()
+	pkg := typecheck(t, fset, "p", src)
+
+	for _, version := range []int{iexportVersionGo1_11, iexportVersionPosCol} {
+		data := iexportData(fset, pkg, version)
+		ifset := token.NewFileSet()
+		_, imported, err := IImportData(ifset, make(map[string]*types.Package), data, "p")
+		if err != nil {
+			t.Fatalf("v%d: IImportData: %v", version, err)
+		}
+		for name, line := range map[string]int{"X": 3, "F": 6} {
+			pos := ifset.Position(imported.Scope().Lookup(name).Pos())
+			if pos.Filename != "p.go" || pos.Line != line {
+				t.Errorf("v%d: %s at %s; want p.go:%d", version, name, pos, line)
+			}
+		}
+	}
+}
+
+// ioPackage returns a type-checked package io with the
+// declarations needed by iimportSrc.
+func ioPackage(t *testing.T) *types.Package {
+	const src = `package io
+type Reader interface{ Read(p []byte) (n int, err error) }
+type Writer interface{ Write(p []byte) (n int, err error) }
+type ReadWriter interface { Reader; Writer }
+`
+	return typecheck(t, token.NewFileSet(), "io", src)
+}
+
+func TestIImportIndirect(t *testing.T) {
+	fset := token.NewFileSet()
+	a := typecheck(t, fset, "example.com/a", "package a; type A struct{ N int }")
+	b := typecheck(t, fset, "example.com/b", `package b; import "example.com/a"; type B struct{ X a.A }`, a)
+	c := typecheck(t, fset, "example.com/c", `package c; import "example.com/b"; var V b.B`, b)
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeObject(t, dir, "c", iexportData(fset, c, iexportVersionPosCol))
+
+	pkg, err := Import(make(map[string]*types.Package), "./c", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(pkg.Imports()), "[package a (\"example.com/a\") package b (\"example.com/b\")]"; got != want {
+		t.Errorf("Imports() = %s; want %s", got, want)
+	}
+	if got, want := pkg.Scope().Lookup("V").Type().Underlying().String(), "struct{X example.com/a.A}"; got != want {
+		t.Errorf("V has type %s; want %s", got, want)
+	}
+
+	name, imports, err := ImportHeader("./c", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "c" || fmt.Sprint(imports) != "[example.com/a example.com/b]" {
+		t.Errorf("ImportHeader = %s, %v; want c, [example.com/a example.com/b]", name, imports)
+	}
+}
+
+func TestIImportErrors(t *testing.T) {
+	fset := token.NewFileSet()
+	pkg := typecheck(t, fset, "p", "package p; type T struct{ X int }")
+	data := iexportData(fset, pkg, iexportVersionPosCol)
+
+	for _, test := range []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"version", append([]byte("i\x09"), data[2:]...), "unknown export data version: v9"},
+		{"package count", []byte("i\x01\x00\x00\xff\xff\xff\xff\x0f"), "invalid package count"},
+		{"truncated", data[:len(data)/2], "invalid export data for p"},
+	} {
+		_, _, err := IImportData(token.NewFileSet(), make(map[string]*types.Package), test.data, "p")
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got error %v; want %q", test.name, err, test.want)
+		}
+	}
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeObject(t, dir, "p", data)
+	imp := &Importer{OnType: func(t types.Type) types.Type { return t }}
+	if _, err := imp.ImportFrom("./p", dir, 0); err == nil || !strings.Contains(err.Error(), "indexed export data") {
+		t.Errorf("OnType: got error %v; want failure for indexed export data", err)
+	}
+}
+
+func TestIImportInfo(t *testing.T) {
+	fset := token.NewFileSet()
+	pkg := typecheck(t, fset, "p", "package p; type T struct{ X int }")
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeObject(t, dir, "p", iexportData(fset, pkg, iexportVersionGenerics))
+
+	_, info, err := ImportInfo(make(map[string]*types.Package), "./p", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ExportInfo{"indexed", iexportVersionGenerics}); info != want {
+		t.Errorf("got %+v; want %+v", info, want)
+	}
+}
+
+func TestDumpIndexed(t *testing.T) {
+	fset := token.NewFileSet()
+	pkg := typecheck(t, fset, "p", "package p; type Tee struct{ Ex int }")
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeObject(t, dir, "p", iexportData(fset, pkg, iexportVersionGenerics))
+
+	var buf bytes.Buffer
+	if err := DumpExportData(&buf, "./p", dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"format indexed", fmt.Sprintf("version %d", iexportVersionGenerics), `"Tee"`, `"Ex"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("dump does not contain %q:\n%s", want, &buf)
+		}
+	}
+}
@@ -0,0 +1,1010 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.6
+
+package gcimporter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go/build"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// An Importer imports gc-generated packages, satisfying the
+// types.Importer and types.ImporterFrom interfaces.
+//
+// All packages imported by an Importer, directly or indirectly,
+// share one packages map, so that each package is represented by
+// a single *types.Package. Since an import path may denote different
+// packages in different modules, packages imported for source
+// directories in a module other than that of the first import, as
+// identified by the nearest enclosing directory with a go.mod file,
+// are kept in a separate map for that module. Before returning a newly imported package,
+// an Importer also imports all of its dependencies that are not
+// complete yet, unless SurfaceOnly is set.
+//
+// The zero value for Importer is ready to use. An Importer is safe for
+// concurrent use by multiple goroutines; its configuration fields must
+// not be changed once it is in use.
+type Importer struct {
+	// GOARCH, if not empty, is the architecture the export data is
+	// expected to be compiled for. Importing an object file whose
+	// header records a different architecture fails with
+	// ErrArchMismatch. If GOARCH is empty, no check is made.
+	GOARCH string
+
+	// Lenient permits object files with a leading UTF-8 byte order
+	// mark or leading white space, as written by some tools.
+	// By default such files are rejected as not being object files.
+	Lenient bool
+
+	// Fset, if not nil, is the file set in which the positions of
+	// imported objects are recorded. Position information is only
+	// present in binary export data.
+	Fset *token.FileSet
+
+	// PosStrip and PosRoot, if not empty, rewrite the file names of
+	// the positions recorded in binary export data, for instance to map
+	// the absolute paths of the machine that compiled a package to a
+	// local checkout: a file name starting with PosStrip, or any file
+	// name if PosStrip is empty, has PosStrip replaced by PosRoot, as
+	// if joined by filepath.Join. File names not starting with PosStrip
+	// are left unchanged.
+	PosStrip, PosRoot string
+
+	// SourceFallback, if not nil, is consulted for packages without
+	// compiled export data, typically to type-check them from source.
+	// Its results are recorded in the packages map like any other
+	// package. Note that types of a package imported this way are
+	// distinct from those recorded for it earlier while importing
+	// compiled packages that depend on it.
+	SourceFallback types.ImporterFrom
+
+	// Lookup, if not nil, is used instead of FindPkg to obtain the
+	// export data of packages by import path. Import paths passed to
+	// the Importer must then be canonical; srcDir is ignored.
+	Lookup Lookup
+
+	// OnResolveFail, if not nil, is called when FindPkg finds no export
+	// data for an import path, before SourceFallback is consulted, with
+	// the import path, srcDir, and the file names FindPkg checked, if
+	// any. If it returns ok, the package is imported from the export
+	// data in filename instead, for instance to find packages compiled
+	// into an unconventional location. OnResolveFail is not called for
+	// packages imported using Lookup or Overlay.
+	OnResolveFail func(path, srcDir string, tried []string) (filename string, ok bool)
+
+	// GoList, if set, makes an Importer run "go list -export -deps" in
+	// srcDir for import paths FindPkg finds no export data for, before
+	// OnResolveFail is consulted, building the packages as necessary.
+	// The package is then imported from the export data file in the
+	// build cache reported by go list, and the files reported for its
+	// dependencies are used for later imports, as by NewModuleImporter.
+	// Each import path is listed at most once; local import paths are
+	// not listed.
+	GoList bool
+
+	// Overlay, if not nil, maps import paths to the contents of object
+	// files or archives that are used instead of the export data found
+	// by FindPkg or Lookup for these paths, for instance to import a
+	// modified version of a package. The paths of dependencies recorded
+	// in export data are canonical import paths.
+	Overlay map[string][]byte
+
+	// OnPackage, if not nil, is called for each package the Importer
+	// imports completely, including indirectly imported dependencies.
+	// Packages are reported in dependency order: a package is reported
+	// after all of its dependencies and before it is returned.
+	OnPackage func(pkg *types.Package)
+
+	// OnType, if not nil, is called with each named type declared at
+	// package level by a package imported from binary export data,
+	// once the package is completely decoded, and before the type name
+	// is entered into the package scope. If OnType returns a type other
+	// than its argument, the package scope instead holds a new type
+	// name for the returned type, which is then in effect an alias.
+	//
+	// OnType is meant for experimental tools annotating or replacing
+	// imported types; it is rarely what you want. Objects of the package
+	// referring to the original type continue to do so, and packages
+	// importing the package later see the original type as well, so
+	// replacing types easily breaks type identity. Type names already
+	// declared before the package was imported, because packages
+	// imported earlier refer to them, are not passed to OnType.
+	// Importing textual or indexed export data fails if OnType is set.
+	OnType func(t types.Type) types.Type
+
+	// MaxPackages, if positive, is the maximum number of packages a
+	// single import may import, counting the imported package and
+	// the dependencies imported along with it. Imports exceeding it
+	// fail with an error wrapping ErrTooManyPackages, guarding against
+	// export data with pathological dependency graphs.
+	MaxPackages int
+
+	// RequireComplete, if set, makes an import fail with an error
+	// wrapping ErrIncompleteClosure unless all packages reachable from
+	// the imported package through their imports are complete.
+	RequireComplete bool
+
+	// SurfaceOnly, if set, makes an Importer import only the packages
+	// it is asked for, not their dependencies. The dependencies remain
+	// incomplete placeholder packages holding only the objects their
+	// importers' export data refers to, such as the types used in the
+	// API of the imported packages, saving the memory taken by the
+	// rest of them. Importing a dependency later imports it completely.
+	// SurfaceOnly is meant for tools inspecting the API of a few
+	// packages of a large dependency graph; it makes imports of
+	// packages with dependencies fail if RequireComplete is set.
+	SurfaceOnly bool
+
+	// Names, if not nil, maps import paths to the names of the packages
+	// they denote. The packages an Importer creates for these paths,
+	// including placeholder packages for dependencies, are given these
+	// names instead of those recorded in export data, for instance to
+	// correct the names of packages of renamed modules. Packages imported
+	// using SourceFallback are not renamed.
+	Names map[string]string
+
+	// OnAllocProfile, if not nil, is called after each package the
+	// Importer decodes with the number of bytes allocated meanwhile,
+	// an estimate of the memory taken by the package. Since it is
+	// measured with runtime.ReadMemStats, which stops the world,
+	// setting OnAllocProfile slows down imports considerably; it is
+	// meant for finding the packages dominating the memory use of
+	// bulk imports. Allocations of concurrently running goroutines
+	// are included in the estimate.
+	OnAllocProfile func(path string, bytes int64)
+
+	// PlaceholderFactory, if not nil, makes imports tolerate
+	// dependencies for which no export data can be found, as
+	// ImportPartial does, and is called with the import path of each
+	// such dependency to create the placeholder package representing
+	// it. The placeholder holds the objects its importers refer to and
+	// is not imported further, whether it is complete or not. If
+	// PlaceholderFactory returns nil, or a package with a different
+	// path, the placeholder is a complete package named as recorded in
+	// export data, or after its path if no name is recorded.
+	PlaceholderFactory func(path string) *types.Package
+
+	mu          sync.Mutex // serializes imports
+	count       int        // packages imported by current import; see MaxPackages
+	packages    map[string]*types.Package
+	found       map[findKey]findResult // cached FindPkg results; see ClearFindCache
+	exportFiles map[string]string      // package path -> export data file; see NewModuleImporter
+	listed      map[string]bool        // package paths listed by go list; see GoList
+	warnings    *[]Warning             // if set, collects warnings; see ImportVerbose
+	missing     *[]string              // if set, collects missing dependencies; see ImportPartial
+	redact      func(string) bool      // if set, hides matching objects; see ImportRedacted
+	ctxErr      func() error           // if set, reports cancellation; see ImportContext
+	info        *ExportInfo            // if set, receives the format of the export data; see ImportInfo
+	lru         *lruState              // if set, bounds the decoded packages; see NewLRUImporter
+	stamps      map[string]fileStamp   // if set, export data file -> state when imported; see NewCachingImporter
+	closer      io.Closer              // if set, releases the files held by imp; see Close
+
+	srcDir       string                    // srcDir of current import; see PlaceholderFactory
+	placeholders map[string]*types.Package // package path -> placeholder; see PlaceholderFactory
+
+	modRoots     map[string]string                    // srcDir -> module root; see packagesFor
+	mainRoot     string                               // module root of first import
+	rootPackages map[string]map[string]*types.Package // module root -> packages of other modules
+}
+
+var _ types.ImporterFrom = (*Importer)(nil) // *Importer implements types.ImporterFrom
+
+// NewImporter returns a new Importer that records imported packages
+// in the packages map, which must contain all packages already imported.
+// If packages is nil, the Importer starts with an empty map. Since the
+// Importer serializes its imports, concurrent imports sharing packages
+// are safe as long as the map is accessed only through the Importer
+// while it is in use; in particular, it must not be passed to the
+// package-level Import function at the same time.
+func NewImporter(packages map[string]*types.Package) *Importer {
+	return &Importer{packages: packages}
+}
+
+var defaultImporter Importer
+
+// Default returns the shared Importer used by the package-level
+// ImportFrom function. Its packages map is allocated on first use.
+func Default() *Importer {
+	return &defaultImporter
+}
+
+// ImportFrom imports a package using the Default importer, so that
+// all callers share its packages. It is safe for concurrent use.
+func ImportFrom(path, srcDir string, mode types.ImportMode) (*types.Package, error) {
+	return Default().ImportFrom(path, srcDir, mode)
+}
+
+// A Warning describes a problem with the export data of a package
+// that did not prevent it from being imported.
+type Warning struct {
+	Path    string // package path
+	Message string
+}
+
+// ImportVerbose is like Import but also returns the warnings
+// encountered while decoding the export data.
+//
+func ImportVerbose(packages map[string]*types.Package, path, srcDir string) (pkg *types.Package, warnings []Warning, err error) {
+	imp := &Importer{warnings: &warnings}
+	pkg, err = imp.importPkg(packages, path, srcDir)
+	return
+}
+
+// An ExportInfo describes the format of the export data of a package.
+type ExportInfo struct {
+	Format  string // "textual", "binary" (as written by BExportData), or "indexed"
+	Version int    // format version recorded in the export data; -1 for textual export data
+}
+
+// ImportInfo is like Import but also reports the format of the export
+// data of the package and its version as recorded in the data, even if
+// the package was imported completely before. If the export data is of
+// an unsupported version, ImportInfo reports it together with the
+// VersionError, for instance to detect object files written by old
+// toolchains.
+//
+func ImportInfo(packages map[string]*types.Package, path, srcDir string) (pkg *types.Package, info ExportInfo, err error) {
+	imp := &Importer{info: &info}
+	pkg, err = imp.importPkg(packages, path, srcDir)
+	return
+}
+
+// exportInfo returns the ExportInfo of the export data following the
+// header line hdr in r, without consuming it.
+func exportInfo(hdr string, r *bufio.Reader) (ExportInfo, error) {
+	switch hdr {
+	case "$$\n":
+		return ExportInfo{"textual", -1}, nil
+	case "$$B\n":
+		// the version is recorded within the first few bytes
+		data, _ := r.Peek(64)
+		if len(data) > 0 && data[0] == 'i' {
+			v, n := binary.Uvarint(data[1:])
+			if n <= 0 {
+				return ExportInfo{}, &CorruptError{1, "invalid indexed export data version"}
+			}
+			return ExportInfo{"indexed", int(v)}, nil
+		}
+		v, err := bimportVersion(data)
+		return ExportInfo{"binary", v}, err
+	}
+	return ExportInfo{}, &CorruptError{0, fmt.Sprintf("unknown export data header: %q", hdr)}
+}
+
+// readInfo sets *imp.info from the export data in filename.
+func (imp *Importer) readInfo(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := bufio.NewReader(f)
+	_, hdr, err := findExportData(buf, imp.Lenient)
+	if err == nil {
+		*imp.info, err = exportInfo(hdr, buf)
+	}
+	if err != nil {
+		return &fileError{filename, err}
+	}
+	return nil
+}
+
+// ImportPartial is like Import but also imports the dependencies of
+// the package, tolerating those for which no export data can be found.
+// Such missing dependencies remain incomplete placeholder packages,
+// holding only the objects referenced by their importers; their paths
+// are returned in missing. ImportPartial is meant for analyses of an
+// incomplete set of compiled packages and should not be used where
+// complete type information is required.
+//
+func ImportPartial(packages map[string]*types.Package, path, srcDir string) (pkg *types.Package, missing []string, err error) {
+	imp := &Importer{packages: packages, missing: &missing}
+	pkg, err = imp.importTransitive(path, srcDir)
+	return
+}
+
+// ImportAll is like Import but also imports every package reachable
+// from the package through Imports, recursively, that has not been
+// imported completely yet, including the dependencies of packages
+// imported completely before. When ImportAll returns successfully,
+// each of these packages is complete and recorded in packages.
+//
+func ImportAll(packages map[string]*types.Package, path, srcDir string) (*types.Package, error) {
+	imp := &Importer{packages: packages}
+	pkg, err := imp.importTransitive(path, srcDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// importTransitive does not visit the dependencies of complete
+	// packages; walk the whole import graph, visiting each package once
+	seen := make(map[*types.Package]bool)
+	list := []*types.Package{pkg}
+	for len(list) > 0 {
+		p := list[len(list)-1]
+		list = list[:len(list)-1]
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		if !p.Complete() {
+			if _, err := imp.importTransitive(p.Path(), srcDir); err != nil {
+				return nil, err
+			}
+		}
+		list = append(list, p.Imports()...)
+	}
+	return pkg, nil
+}
+
+// ImportRedacted is like Import but omits the package-level objects
+// whose names satisfy redact from the scope of the imported package.
+// The types declared by redacted type names remain available through
+// the objects referring to them. Redaction requires binary export data
+// in the format of BExportData.
+//
+func ImportRedacted(packages map[string]*types.Package, path, srcDir string, redact func(name string) bool) (*types.Package, error) {
+	imp := &Importer{redact: redact}
+	return imp.importPkg(packages, path, srcDir)
+}
+
+// Import imports the package with the given import path,
+// as if imported from the current directory.
+func (imp *Importer) Import(path string) (*types.Package, error) {
+	return imp.ImportFrom(path, "", 0)
+}
+
+// ImportFrom imports the package with the given import path
+// resolved relative to srcDir (see FindPkg). The mode must be 0.
+func (imp *Importer) ImportFrom(path, srcDir string, mode types.ImportMode) (*types.Package, error) {
+	if mode != 0 {
+		panic("mode must be 0")
+	}
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+	if imp.packages == nil {
+		imp.packages = make(map[string]*types.Package)
+	}
+	if imp.stamps != nil {
+		imp.invalidate()
+	}
+	if packages := imp.packagesFor(srcDir); packages != nil {
+		defer func(main map[string]*types.Package) { imp.packages = main }(imp.packages)
+		imp.packages = packages
+	}
+	imp.count = 0
+	pkg, err := imp.importTransitive(path, srcDir)
+	if err == nil && imp.RequireComplete {
+		if err = checkComplete(pkg); err != nil {
+			return nil, err
+		}
+	}
+	if err == nil && imp.lru != nil {
+		imp.lru.clock++
+		imp.lru.touch(pkg)
+		imp.evict()
+	}
+	return pkg, err
+}
+
+// ErrIncompleteClosure is reported, possibly wrapped, by Importers with
+// RequireComplete set for packages depending on incomplete packages.
+var ErrIncompleteClosure = errors.New("incomplete package closure")
+
+type incompleteError struct {
+	path, dep string // dep is an incomplete package reachable from path
+}
+
+func (e *incompleteError) Error() string {
+	return fmt.Sprintf("%v: %s depends on incomplete package %s", ErrIncompleteClosure, e.path, e.dep)
+}
+
+func (e *incompleteError) Is(target error) bool { return target == ErrIncompleteClosure }
+
+// checkComplete reports an incompleteError if pkg or a package
+// reachable from it is incomplete.
+func checkComplete(pkg *types.Package) error {
+	seen := make(map[*types.Package]bool)
+	var check func(p *types.Package) error
+	check = func(p *types.Package) error {
+		if seen[p] {
+			return nil
+		}
+		seen[p] = true
+		if !p.Complete() {
+			return &incompleteError{pkg.Path(), p.Path()}
+		}
+		for _, dep := range p.Imports() {
+			if err := check(dep); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return check(pkg)
+}
+
+// ErrTooManyPackages is reported, possibly wrapped, by imports
+// exceeding Importer.MaxPackages.
+var ErrTooManyPackages = errors.New("too many packages")
+
+type tooManyPackagesError struct {
+	path string // package whose import exceeded the limit
+	max  int
+}
+
+func (e *tooManyPackagesError) Error() string {
+	return fmt.Sprintf("%v: importing %s exceeds the limit of %d packages", ErrTooManyPackages, e.path, e.max)
+}
+
+func (e *tooManyPackagesError) Is(target error) bool { return target == ErrTooManyPackages }
+
+// importTransitive imports path and the dependencies recorded in
+// its export data that have not been imported completely yet,
+// unless imp.SurfaceOnly is set.
+func (imp *Importer) importTransitive(path, srcDir string) (*types.Package, error) {
+	imp.srcDir = srcDir
+	id := path
+	if _, ok := imp.Overlay[path]; !ok && imp.Lookup == nil && path != "unsafe" {
+		_, id = imp.findPkg(path, srcDir)
+	}
+	if id != "" {
+		if pkg := imp.packages[id]; pkg != nil && pkg.Complete() {
+			return pkg, nil
+		}
+	}
+
+	if imp.count++; imp.MaxPackages > 0 && imp.count > imp.MaxPackages {
+		return nil, &tooManyPackagesError{path, imp.MaxPackages}
+	}
+	var before runtime.MemStats
+	if imp.OnAllocProfile != nil {
+		runtime.ReadMemStats(&before)
+	}
+	pkg, err := imp.importPkg(imp.packages, path, srcDir)
+	if err == nil && pkg != types.Unsafe && imp.OnAllocProfile != nil {
+		var after runtime.MemStats
+		runtime.ReadMemStats(&after)
+		imp.OnAllocProfile(pkg.Path(), int64(after.TotalAlloc-before.TotalAlloc))
+	}
+	if err != nil || pkg == types.Unsafe {
+		return pkg, err
+	}
+	for _, dep := range pkg.Imports() {
+		if dep.Complete() || imp.SurfaceOnly || imp.isPlaceholder(dep) {
+			continue
+		}
+		if imp.missing != nil && !imp.available(dep.Path(), srcDir) {
+			imp.addMissing(dep)
+			continue
+		}
+		if _, err := imp.importTransitive(dep.Path(), srcDir); err != nil {
+			return nil, err
+		}
+	}
+	if imp.OnPackage != nil {
+		imp.OnPackage(pkg)
+	}
+	return pkg, nil
+}
+
+// importPkg is like Import but subject to the configuration of imp.
+func (imp *Importer) importPkg(packages map[string]*types.Package, path, srcDir string) (pkg *types.Package, err error) {
+	if err = imp.interrupted(); err != nil {
+		return
+	}
+	if path == "unsafe" {
+		// package unsafe is built into the compiler and has no export data
+		return types.Unsafe, nil
+	}
+	if data, ok := imp.Overlay[path]; ok {
+		if pkg = packages[path]; pkg != nil && pkg.Complete() {
+			return
+		}
+		return imp.importFile(packages, path, path, bytes.NewReader(data))
+	}
+	if imp.Lookup != nil {
+		return imp.importLookup(packages, path, srcDir)
+	}
+
+	filename, id := imp.resolve(path, srcDir)
+	if filename == "" {
+		if imp.SourceFallback != nil {
+			return imp.importSource(packages, path, srcDir)
+		}
+		err = &notFoundError{path: id}
+		return
+	}
+
+	// no need to re-import if the package was imported completely before
+	if pkg = packages[id]; pkg != nil && pkg.Complete() {
+		if imp.info != nil {
+			err = imp.readInfo(filename)
+		}
+		return
+	}
+
+	if imp.stamps != nil {
+		if s := newStamp(packages, filename, id); s != nil {
+			defer func() {
+				if err == nil {
+					imp.stamps[filename] = *s
+				}
+			}()
+		}
+	}
+
+	if imp.lru != nil {
+		var data []byte
+		if data, err = imp.lru.readFile(filename, id); err != nil {
+			return
+		}
+		return imp.importFile(packages, filename, id, bytes.NewReader(data))
+	}
+
+	// open file
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	return imp.importFile(packages, filename, id, f)
+}
+
+// importFile imports the package id from the object file or archive
+// read from r. The filename is only used in error messages.
+func (imp *Importer) importFile(packages map[string]*types.Package, filename, id string, r io.Reader) (pkg *types.Package, err error) {
+	defer func() {
+		if err != nil && err != imp.interrupted() {
+			// add file name to error
+			err = &fileError{filename, err}
+		}
+	}()
+
+	var objhdr, hdr string
+	buf := bufio.NewReader(r)
+	if objhdr, hdr, err = findExportData(buf, imp.Lenient); err != nil {
+		return
+	}
+
+	if imp.GOARCH != "" {
+		if arch := objectArch(objhdr); arch != "" && arch != imp.GOARCH {
+			err = &archError{got: arch, want: imp.GOARCH}
+			return
+		}
+	}
+
+	if imp.info != nil {
+		if *imp.info, err = exportInfo(hdr, buf); err != nil {
+			return
+		}
+	}
+
+	switch hdr {
+	case "$$\n":
+		if imp.redact != nil {
+			err = errors.New("cannot redact objects of textual export data")
+			return
+		}
+		if imp.OnType != nil {
+			err = errors.New("cannot intercept types of textual export data")
+			return
+		}
+		return imp.importData(packages, filename, id, buf)
+	case "$$B\n":
+		var data []byte
+		data, err = ioutil.ReadAll(buf)
+		if err == nil {
+			fset := imp.Fset
+			if fset == nil {
+				fset = token.NewFileSet()
+			}
+			if len(data) > 0 && data[0] == 'i' {
+				// indexed format, written by cmd/compile since Go 1.11
+				if imp.redact != nil {
+					err = errors.New("cannot redact objects of indexed export data")
+					return
+				}
+				if imp.OnType != nil {
+					err = errors.New("cannot intercept types of indexed export data")
+					return
+				}
+				var n int
+				if n, pkg, err = imp.iimportData(fset, packages, data, id); err == nil {
+					err = checkExportDataEnd(data, n)
+				}
+				return
+			}
+			var n int
+			if n, pkg, err = imp.bimportData(fset, packages, data, id); err == nil {
+				err = checkExportDataEnd(data, n)
+			}
+			return
+		}
+	default:
+		err = &CorruptError{0, fmt.Sprintf("unknown export data header: %q", hdr)}
+	}
+
+	return
+}
+
+// packagesFor returns the packages map for imports from srcDir if its
+// module differs from the module of the first import, and nil if the
+// packages belong in imp.packages.
+func (imp *Importer) packagesFor(srcDir string) map[string]*types.Package {
+	root, ok := imp.modRoots[srcDir]
+	if !ok {
+		root = moduleRoot(srcDir)
+		if imp.modRoots == nil {
+			imp.modRoots = make(map[string]string)
+			imp.mainRoot = root
+		}
+		imp.modRoots[srcDir] = root
+	}
+	if root == imp.mainRoot {
+		return nil
+	}
+	packages := imp.rootPackages[root]
+	if packages == nil {
+		if imp.rootPackages == nil {
+			imp.rootPackages = make(map[string]map[string]*types.Package)
+		}
+		packages = make(map[string]*types.Package)
+		imp.rootPackages[root] = packages
+	}
+	return packages
+}
+
+// moduleRoot returns the nearest directory enclosing dir that
+// contains a go.mod file, or "" if there is none.
+func moduleRoot(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		if fi, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil && !fi.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// findPkgFunc is the function used by an Importer to locate export
+// data; tests may replace it.
+var findPkgFunc = FindPkg
+
+type findKey struct{ path, srcDir string }
+
+type findResult struct{ filename, id string }
+
+// findPkg is like FindPkg but consults the export data files
+// known to imp first. Results are cached, so that each normalized
+// path (see NormalizePath) and srcDir pair is resolved at most once
+// until ClearFindCache is called. They are also cached by the path
+// as given, so that repeated imports of cached packages need not
+// normalize their paths again.
+func (imp *Importer) findPkg(path, srcDir string) (filename, id string) {
+	if filename, ok := imp.exportFiles[path]; ok {
+		return filename, path
+	}
+	if r, ok := imp.found[findKey{path, srcDir}]; ok {
+		return r.filename, r.id
+	}
+	key := findKey{NormalizePath(path), srcDir}
+	r, ok := imp.found[key]
+	if !ok {
+		r.filename, r.id = findPkgFunc(key.path, srcDir)
+		if imp.found == nil {
+			imp.found = make(map[findKey]findResult)
+		}
+		imp.found[key] = r
+	}
+	imp.found[findKey{path, srcDir}] = r
+	return r.filename, r.id
+}
+
+// resolve is like findPkg but consults go list, if imp.GoList is set,
+// and imp.OnResolveFail for paths findPkg does not find export data
+// for. If either supplies a file for a path that findPkg could not
+// resolve to a package id at all, the path is used as id.
+func (imp *Importer) resolve(path, srcDir string) (filename, id string) {
+	filename, id = imp.findPkg(path, srcDir)
+	if filename != "" || path == "unsafe" {
+		return
+	}
+	if imp.GoList && !build.IsLocalImport(path) {
+		if filename = imp.goList(path, srcDir); filename != "" {
+			return filename, path
+		}
+	}
+	if imp.OnResolveFail == nil {
+		return
+	}
+	if alt, ok := imp.OnResolveFail(path, srcDir, pkgCandidates(NormalizePath(path), srcDir)); ok {
+		if id == "" {
+			id = path
+		}
+		return alt, id
+	}
+	return
+}
+
+// NormalizePath returns the import path path in the normal form used
+// by an Importer to cache the results of FindPkg: duplicate slashes,
+// trailing slashes, and "." and ".." elements are removed as by
+// path.Clean, except that local import paths remain local; for
+// instance, "./././testdata//p/" becomes "./testdata/p".
+// NormalizePath is idempotent.
+func NormalizePath(path string) string {
+	if path == "" {
+		return ""
+	}
+	clean := pathpkg.Clean(path)
+	if build.IsLocalImport(path) && !build.IsLocalImport(clean) {
+		clean = "./" + clean
+	}
+	return clean
+}
+
+// ClearFindCache discards the cached locations of export data files,
+// so that subsequent imports resolve import paths anew, for instance
+// after packages have been installed. Packages imported completely
+// before are not imported again.
+func (imp *Importer) ClearFindCache() {
+	imp.mu.Lock()
+	imp.found = nil
+	imp.mu.Unlock()
+}
+
+// Reset removes all packages imported by imp so far from its packages
+// map, so that subsequent imports import them anew. Packages returned
+// before remain valid, but packages imported after Reset are distinct
+// from them, as are their types.
+func (imp *Importer) Reset() {
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+	for path := range imp.packages {
+		delete(imp.packages, path)
+	}
+	imp.rootPackages = nil
+	imp.placeholders = nil
+	if imp.stamps != nil {
+		imp.stamps = make(map[string]fileStamp)
+	}
+	if imp.lru != nil {
+		imp.lru.used = make(map[string]int)
+		imp.lru.blobs = make(map[string][]byte)
+	}
+}
+
+// Close releases the files an Importer keeps open, such as the ZIP
+// file of an Importer returned by ImportZip. Imports of packages not
+// imported before fail after Close. For other Importers, Close does
+// nothing.
+func (imp *Importer) Close() error {
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+	if imp.closer == nil {
+		return nil
+	}
+	err := imp.closer.Close()
+	imp.closer = nil
+	return err
+}
+
+// Packages returns a copy of the packages imported by imp so far, by
+// import path, including incomplete placeholder packages created for
+// the dependencies of imported packages. Packages kept apart for other
+// modules than that of the first import (see Importer) are omitted.
+// Packages is meant for diagnosing unexpected import results.
+func (imp *Importer) Packages() map[string]*types.Package {
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+	packages := make(map[string]*types.Package, len(imp.packages))
+	for path, pkg := range imp.packages {
+		packages[path] = pkg
+	}
+	return packages
+}
+
+// Completeness reports, for each package in Packages, whether it is
+// complete.
+func (imp *Importer) Completeness() map[string]bool {
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+	complete := make(map[string]bool, len(imp.packages))
+	for path, pkg := range imp.packages {
+		complete[path] = pkg.Complete()
+	}
+	return complete
+}
+
+// posFilename returns the file name recorded for positions in the file
+// filename, as rewritten by imp.PosStrip and imp.PosRoot.
+func (imp *Importer) posFilename(filename string) string {
+	if imp.PosStrip == "" && imp.PosRoot == "" || !strings.HasPrefix(filename, imp.PosStrip) {
+		return filename
+	}
+	filename = filename[len(imp.PosStrip):]
+	if imp.PosRoot == "" {
+		return filename
+	}
+	return filepath.Join(imp.PosRoot, filename)
+}
+
+// available reports whether imp can import path.
+func (imp *Importer) available(path, srcDir string) bool {
+	if _, ok := imp.Overlay[path]; ok || imp.Lookup != nil {
+		return true
+	}
+	filename, _ := imp.resolve(path, srcDir)
+	return filename != "" || imp.SourceFallback != nil
+}
+
+// placeholder returns the placeholder package for the dependency path,
+// named name in export data, of the package being imported, creating
+// it using imp.PlaceholderFactory if path cannot be imported. It
+// returns nil if there is no factory or path can be imported.
+func (imp *Importer) placeholder(path, name string) *types.Package {
+	if imp == nil || imp.PlaceholderFactory == nil || path == "unsafe" {
+		return nil
+	}
+	if pkg := imp.placeholders[path]; pkg != nil {
+		return pkg
+	}
+	if imp.available(path, imp.srcDir) {
+		return nil
+	}
+	pkg := imp.PlaceholderFactory(path)
+	if pkg == nil || pkg.Path() != path {
+		if name == "" {
+			name = nameFromPath(path)
+		}
+		pkg = types.NewPackage(path, name)
+		pkg.MarkComplete()
+	}
+	if imp.placeholders == nil {
+		imp.placeholders = make(map[string]*types.Package)
+	}
+	imp.placeholders[path] = pkg
+	return pkg
+}
+
+// interrupted returns the error reported by imp.ctxErr, if any.
+func (imp *Importer) interrupted() error {
+	if imp == nil || imp.ctxErr == nil {
+		return nil
+	}
+	return imp.ctxErr()
+}
+
+// names returns imp.Names.
+func (imp *Importer) names() map[string]string {
+	if imp == nil {
+		return nil
+	}
+	return imp.Names
+}
+
+// onType returns imp.OnType.
+func (imp *Importer) onType() func(types.Type) types.Type {
+	if imp == nil {
+		return nil
+	}
+	return imp.OnType
+}
+
+// redacts reports whether imp omits the object name of the imported
+// package; see ImportRedacted.
+func (imp *Importer) redacts(name string) bool {
+	return imp != nil && imp.redact != nil && imp.redact(name)
+}
+
+// warn records a warning about the export data of the package path
+// if imp collects warnings; see ImportVerbose.
+func (imp *Importer) warn(path, msg string) {
+	if imp != nil && imp.warnings != nil {
+		*imp.warnings = append(*imp.warnings, Warning{path, msg})
+	}
+}
+
+// isPlaceholder reports whether pkg was created by placeholder.
+func (imp *Importer) isPlaceholder(pkg *types.Package) bool {
+	return imp != nil && imp.placeholders[pkg.Path()] == pkg
+}
+
+// addMissing records the placeholder package dep as missing.
+func (imp *Importer) addMissing(dep *types.Package) {
+	for _, path := range *imp.missing {
+		if path == dep.Path() {
+			return
+		}
+	}
+	*imp.missing = append(*imp.missing, dep.Path())
+	if dep.Name() == "" {
+		// textual export data may not record the package name
+		setName(dep, pathpkg.Base(dep.Path()))
+	}
+}
+
+// importSource imports path using imp.SourceFallback.
+func (imp *Importer) importSource(packages map[string]*types.Package, path, srcDir string) (*types.Package, error) {
+	if pkg := packages[path]; pkg != nil && pkg.Complete() {
+		return pkg, nil
+	}
+	pkg, err := imp.SourceFallback.ImportFrom(path, srcDir, 0)
+	if err != nil {
+		return nil, err
+	}
+	packages[pkg.Path()] = pkg
+	return pkg, nil
+}
+
+// FileRanges returns, for each source file declaring objects of pkg,
+// the highest line number of such a declaration. It requires pkg to
+// have been imported with imp.Fset set; otherwise it returns nil.
+func (imp *Importer) FileRanges(pkg *types.Package) map[string]int {
+	if imp.Fset == nil {
+		return nil
+	}
+	ranges := make(map[string]int)
+	record := func(obj types.Object) {
+		if !obj.Pos().IsValid() || obj.Pkg() != pkg {
+			return
+		}
+		posn := imp.Fset.Position(obj.Pos())
+		if posn.Line > ranges[posn.Filename] {
+			ranges[posn.Filename] = posn.Line
+		}
+	}
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		record(obj)
+		if _, ok := obj.(*types.TypeName); !ok {
+			continue
+		}
+		named, ok := obj.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		for i := 0; i < named.NumMethods(); i++ {
+			record(named.Method(i))
+		}
+		switch u := named.Underlying().(type) {
+		case *types.Struct:
+			for i := 0; i < u.NumFields(); i++ {
+				record(u.Field(i))
+			}
+		case *types.Interface:
+			for i := 0; i < u.NumExplicitMethods(); i++ {
+				record(u.ExplicitMethod(i))
+			}
+		}
+	}
+	return ranges
+}
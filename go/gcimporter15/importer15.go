@@ -0,0 +1,106 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.5,!go1.6
+
+package gcimporter
+
+import (
+	"bufio"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// An Importer imports gc-generated packages. It requires go1.6, which
+// provides the types.ImporterFrom interface; for go1.5, the zero value
+// only configures the export data decoders used by Import, ImportData,
+// and ImportReader, which import packages as they always did.
+type Importer struct{}
+
+// importPkg imports the package path as described by Import.
+func (imp *Importer) importPkg(packages map[string]*types.Package, path, srcDir string) (pkg *types.Package, err error) {
+	filename, id := FindPkg(path, srcDir)
+	if filename == "" {
+		if path == "unsafe" {
+			return types.Unsafe, nil
+		}
+		err = &notFoundError{path: id}
+		return
+	}
+
+	// no need to re-import if the package was imported completely before
+	if pkg = packages[id]; pkg != nil && pkg.Complete() {
+		return
+	}
+
+	// open file
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	return imp.importFile(packages, filename, id, f)
+}
+
+// importFile imports the package id from the object file or archive
+// read from r. The filename is only used in error messages.
+func (imp *Importer) importFile(packages map[string]*types.Package, filename, id string, r io.Reader) (pkg *types.Package, err error) {
+	defer func() {
+		if err != nil {
+			// add file name to error
+			err = &fileError{filename, err}
+		}
+	}()
+
+	var hdr string
+	buf := bufio.NewReader(r)
+	if _, hdr, err = findExportData(buf, false); err != nil {
+		return
+	}
+
+	switch hdr {
+	case "$$\n":
+		return imp.importData(packages, filename, id, buf)
+	case "$$B\n":
+		var data []byte
+		data, err = ioutil.ReadAll(buf)
+		if err == nil {
+			fset := token.NewFileSet()
+			if len(data) > 0 && data[0] == 'i' {
+				// indexed format, written by cmd/compile since Go 1.11
+				var n int
+				if n, pkg, err = imp.iimportData(fset, packages, data, id); err == nil {
+					err = checkExportDataEnd(data, n)
+				}
+				return
+			}
+			var n int
+			if n, pkg, err = imp.bimportData(fset, packages, data, id); err == nil {
+				err = checkExportDataEnd(data, n)
+			}
+			return
+		}
+	default:
+		err = &CorruptError{0, fmt.Sprintf("unknown export data header: %q", hdr)}
+	}
+
+	return
+}
+
+// The export data decoders consult the following methods for the
+// configuration of an Importer; for go1.5, there is none.
+
+func (imp *Importer) interrupted() error                           { return nil }
+func (imp *Importer) placeholder(path, name string) *types.Package { return nil }
+func (imp *Importer) isPlaceholder(pkg *types.Package) bool        { return false }
+func (imp *Importer) posFilename(filename string) string           { return filename }
+func (imp *Importer) names() map[string]string                     { return nil }
+func (imp *Importer) onType() func(types.Type) types.Type          { return nil }
+func (imp *Importer) redacts(name string) bool                     { return false }
+func (imp *Importer) warn(path, msg string)                        {}
@@ -0,0 +1,1762 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.13
+
+package gcimporter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	goparser "go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchMismatch(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	data := exportSource(t, "p", "package p; const C = 0")
+	if err := ioutil.WriteFile(filepath.Join(dir, "p.o"), objectFile("amd64", data), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, goarch := range []string{"", "amd64"} {
+		imp := &Importer{GOARCH: goarch}
+		if _, err := imp.ImportFrom("./p", dir, 0); err != nil {
+			t.Errorf("GOARCH=%q: %v", goarch, err)
+		}
+	}
+
+	imp := &Importer{GOARCH: "arm64"}
+	_, err := imp.ImportFrom("./p", dir, 0)
+	if !errors.Is(err, ErrArchMismatch) {
+		t.Fatalf("GOARCH=arm64: got error %v; want ErrArchMismatch", err)
+	}
+	t.Log(err)
+}
+
+func TestImportErrorTypes(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	_, err := Import(make(map[string]*types.Package), "./missing", dir)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing package: got error %v; want ErrNotFound", err)
+	}
+
+	data := exportSource(t, "p", "package p; type T struct{ X, Y int }; func F(T) T { return T{} }")
+	objhdr := fmt.Sprintf("go object %s %s go1.7 X:none\n", runtime.GOOS, runtime.GOARCH)
+	for _, test := range []struct {
+		name, obj string
+	}{
+		{"no export data", objhdr},
+		{"truncated binary", objhdr + "\n$$B\n" + string(data[:len(data)/2])},
+		{"truncated textual", objhdr + "\n$$\npackage p\nconst @\"\".C = 1\n"},
+		{"unknown header", objhdr + "\n$$X\n"},
+		{"unterminated binary", objhdr + "\n$$B\n" + string(data)},
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "p.o"), []byte(test.obj), 0666); err != nil {
+			t.Fatal(err)
+		}
+		_, err := Import(make(map[string]*types.Package), "./p", dir)
+		var cerr *CorruptError
+		if !errors.As(err, &cerr) {
+			t.Errorf("%s: got error %v; want CorruptError", test.name, err)
+			continue
+		}
+		if errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: error %v wraps ErrNotFound", test.name, err)
+		}
+		t.Logf("%s: %v", test.name, err)
+	}
+}
+
+func TestImportCorruptBinary(t *testing.T) {
+	data := exportSource(t, "p", "package p; type T struct{ X, Y int }; func F(T) T { return T{} }; const C = 1.5")
+	obj := objectFile(runtime.GOARCH, data)
+	start := len(obj) - len(data) - len("\n$$\n")
+
+	// any corruption of the export data must be reported as a typed
+	// error, not a panic
+	for i := start; i < len(obj); i++ {
+		corrupt := append([]byte(nil), obj...)
+		corrupt[i] ^= 0xff
+		_, err := ImportReader(make(map[string]*types.Package), bytes.NewReader(corrupt), "p")
+		var cerr *CorruptError
+		var verr *VersionError
+		if err != nil && !errors.As(err, &cerr) && !errors.As(err, &verr) {
+			t.Errorf("byte %d flipped: got error %v; want CorruptError or VersionError", i, err)
+		}
+	}
+}
+
+func TestImportInfo(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	data := exportSource(t, "p", "package p; const C = 0")
+	objhdr := fmt.Sprintf("go object %s %s go1.7 X:none\n", runtime.GOOS, runtime.GOARCH)
+	for _, test := range []struct {
+		name, obj string
+		want      ExportInfo
+		ok        bool
+	}{
+		{"binary", string(objectFile(runtime.GOARCH, data)), ExportInfo{"binary", exportVersion}, true},
+		{"textual", objhdr + "\n$$\npackage p\nconst @\"\".C = 1\n$$\n", ExportInfo{"textual", -1}, true},
+		{"unsupported", string(objectFile(runtime.GOARCH, bytes.Replace(data, []byte("v3"), []byte("v9"), 1))), ExportInfo{"binary", 9}, false},
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "p.o"), []byte(test.obj), 0666); err != nil {
+			t.Fatal(err)
+		}
+		packages := make(map[string]*types.Package)
+		_, info, err := ImportInfo(packages, "./p", dir)
+		if (err == nil) != test.ok {
+			t.Errorf("%s: got error %v; want ok = %v", test.name, err, test.ok)
+		}
+		if info != test.want {
+			t.Errorf("%s: got %+v; want %+v", test.name, info, test.want)
+		}
+		if !test.ok {
+			continue
+		}
+
+		// the info is reported for a package imported before, too
+		_, info, err = ImportInfo(packages, "./p", dir)
+		if err != nil || info != test.want {
+			t.Errorf("%s: reimport: got %+v, %v; want %+v", test.name, info, err, test.want)
+		}
+	}
+}
+
+func TestLenient(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	data := exportSource(t, "p", "package p; const C = 0")
+	obj := append([]byte("\xef\xbb\xbf \n"), objectFile(runtime.GOARCH, data)...)
+	if err := ioutil.WriteFile(filepath.Join(dir, "p.o"), obj, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := new(Importer).ImportFrom("./p", dir, 0); err == nil {
+		t.Errorf("strict import succeeded unexpectedly")
+	}
+	imp := &Importer{Lenient: true}
+	pkg, err := imp.ImportFrom("./p", dir, 0)
+	if err != nil {
+		t.Fatalf("lenient import: %v", err)
+	}
+	if pkg.Scope().Lookup("C") == nil {
+		t.Errorf("C not found in lenient import")
+	}
+}
+
+func TestFileRanges(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, src := range []struct{ name, src string }{
+		{"a.go", "package p\n\nconst A = 0\n\nvar B int\n"},
+		{"b.go", "package p\n\ntype T struct {\n\tX int\n\n\tY int\n}\n\nfunc (T) M() {}\n"},
+	} {
+		f, err := goparser.ParseFile(fset, src.name, src.src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	pkg, err := new(types.Config).Check("p", fset, files, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeObject(t, dir, "p", BExportData(fset, pkg))
+
+	imp := &Importer{Fset: token.NewFileSet()}
+	pkg, err = imp.ImportFrom("./p", dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := fmt.Sprint(imp.FileRanges(pkg))
+	if want := "map[a.go:5 b.go:9]"; got != want {
+		t.Errorf("FileRanges = %s; want %s", got, want)
+	}
+
+	if ranges := new(Importer).FileRanges(pkg); ranges != nil {
+		t.Errorf("FileRanges without file set = %v; want nil", ranges)
+	}
+}
+
+func TestFsetPositions(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// a depends on b
+	path := func(name string) string { return filepath.Join(dir, name) }
+	fset := token.NewFileSet()
+	b := typecheck(t, fset, path("b"), "package b\n\ntype B struct {\n\tX int\n}\n")
+	a := typecheck(t, fset, path("a"), fmt.Sprintf("package a\n\nimport %q\n\nconst C = 0\n\nvar V b.B\n\nfunc F() {}\n\ntype T int\n\nfunc (T) M() {}\n", b.Path()), b)
+	writeObject(t, dir, "a", BExportData(fset, a))
+	writeObject(t, dir, "b", BExportData(fset, b))
+
+	imp := &Importer{Fset: token.NewFileSet()}
+	pkg, err := imp.ImportFrom("./a", dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	named := pkg.Scope().Lookup("V").Type().(*types.Named)
+	for _, test := range []struct {
+		obj  types.Object
+		want string
+	}{
+		{pkg.Scope().Lookup("C"), "a.go:5"},
+		{pkg.Scope().Lookup("V"), "a.go:7"},
+		{pkg.Scope().Lookup("F"), "a.go:9"},
+		{pkg.Scope().Lookup("T"), "a.go:11"},
+		{pkg.Scope().Lookup("T").Type().(*types.Named).Method(0), "a.go:13"},
+		{named.Obj(), "b.go:3"},
+		{named.Underlying().(*types.Struct).Field(0), "b.go:4"},
+	} {
+		posn := imp.Fset.Position(test.obj.Pos())
+		if got := fmt.Sprintf("%s:%d", filepath.Base(posn.Filename), posn.Line); got != test.want {
+			t.Errorf("%s declared at %s; want %s", test.obj.Name(), got, test.want)
+		}
+	}
+}
+
+func TestPosRoot(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// positions recorded on the build machine
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, src := range []struct{ name, src string }{
+		{"/build/work/src/p/a.go", "package p\n\nconst A = 0\n"},
+		{"/usr/local/go/src/p/b.go", "package p\n\nvar B int\n"},
+	} {
+		f, err := goparser.ParseFile(fset, src.name, src.src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	pkg, err := new(types.Config).Check("p", fset, files, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeObject(t, dir, "p", BExportData(fset, pkg))
+
+	local := filepath.Join("home", "me", "checkout")
+	for _, test := range []struct {
+		strip, root string
+		a, b        string // file names of A and B
+	}{
+		{"", "", "/build/work/src/p/a.go", "/usr/local/go/src/p/b.go"},
+		{"/build/work/src", local, filepath.Join(local, "p", "a.go"), "/usr/local/go/src/p/b.go"},
+		{"/build/work/", "", "src/p/a.go", "/usr/local/go/src/p/b.go"},
+		{"", local, filepath.Join(local, "build", "work", "src", "p", "a.go"), filepath.Join(local, "usr", "local", "go", "src", "p", "b.go")},
+	} {
+		imp := &Importer{Fset: token.NewFileSet(), PosStrip: test.strip, PosRoot: test.root}
+		pkg, err := imp.ImportFrom("./p", dir, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, want := range map[string]string{"A": test.a, "B": test.b} {
+			if got := imp.Fset.Position(pkg.Scope().Lookup(name).Pos()).Filename; got != want {
+				t.Errorf("PosStrip=%q, PosRoot=%q: %s declared in %s; want %s", test.strip, test.root, name, got, want)
+			}
+		}
+	}
+}
+
+// sourceImporter type-checks packages from the sources in its map,
+// counting the number of imports.
+type sourceImporter struct {
+	t       *testing.T
+	fset    *token.FileSet
+	sources map[string]string
+	count   int
+}
+
+func (s *sourceImporter) Import(path string) (*types.Package, error) {
+	return s.ImportFrom(path, "", 0)
+}
+
+func (s *sourceImporter) ImportFrom(path, srcDir string, mode types.ImportMode) (*types.Package, error) {
+	src, ok := s.sources[path]
+	if !ok {
+		return nil, fmt.Errorf("no source for %s", path)
+	}
+	s.count++
+	return typecheck(s.t, s.fset, path, src), nil
+}
+
+func TestImporterFrom(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeObject(t, dir, "a", exportSource(t, "a", "package a; type A int"))
+
+	// x and y import a relative to the directory of their source files
+	imp := new(Importer)
+	fset := token.NewFileSet()
+	var imported []*types.Package
+	for _, name := range []string{"x", "y"} {
+		f, err := goparser.ParseFile(fset, filepath.Join(dir, name+".go"), "package "+name+"; import \"./a\"; var V a.A", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conf := types.Config{Importer: imp}
+		pkg, err := conf.Check(name, fset, []*ast.File{f}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		imported = append(imported, pkg.Imports()[0])
+	}
+	a, err := imp.ImportFrom("./a", dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported[0] != a || imported[1] != a {
+		t.Errorf("type-checked packages import %p and %p; want shared package %p", imported[0], imported[1], a)
+	}
+}
+
+func TestSourceFallback(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// a is compiled, b is only available as source
+	writeObject(t, dir, "a", exportSource(t, "a", "package a; type A int"))
+	fallback := &sourceImporter{t: t, fset: token.NewFileSet(), sources: map[string]string{
+		"b": "package b; const B = 1",
+	}}
+	imp := &Importer{SourceFallback: fallback}
+
+	const src = `package x
+import (
+	"./a"
+	"b"
+)
+var X a.A = b.B
+`
+	fset := token.NewFileSet()
+	f, err := goparser.ParseFile(fset, filepath.Join(dir, "x.go"), src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: imp}
+	x, err := conf.Check("x", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := fmt.Sprint(x.Imports())
+	if want := fmt.Sprintf(`[package a (%q) package b ("b")]`, filepath.Join(dir, "a")); got != want {
+		t.Errorf("x.Imports() = %s; want %s", got, want)
+	}
+
+	// the source package is cached
+	b, err := imp.ImportFrom("b", dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b != x.Imports()[1] || fallback.count != 1 {
+		t.Errorf("source fallback not cached: imported b %d times", fallback.count)
+	}
+}
+
+func TestImportVerbose(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	writeObject(t, dir, "ok", exportSource(t, "ok", "package ok; const C = 0"))
+
+	// declaration beyond the line limit for positions
+	writeObject(t, dir, "long", exportSource(t, "long", "package long"+strings.Repeat("\n", 123456)+"var X int"))
+
+	// package with type errors
+	fset := token.NewFileSet()
+	f, err := goparser.ParseFile(fset, "errs.go", "package errs; const C = \"\" + 0; type T undefined", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Error: func(error) {}}
+	errs, _ := conf.Check("errs", fset, []*ast.File{f}, nil)
+	writeObject(t, dir, "errs", BExportData(fset, errs))
+
+	for _, test := range []struct {
+		path string
+		want []string
+	}{
+		{"./ok", nil},
+		{"./long", []string{"long.go:123457: line number too large; using line 1"}},
+		{"./errs", []string{"constant C has unknown value", "type T has invalid underlying type"}},
+	} {
+		_, warnings, err := ImportVerbose(make(map[string]*types.Package), test.path, dir)
+		if err != nil {
+			t.Errorf("%s: %v", test.path, err)
+			continue
+		}
+		var got []string
+		for _, w := range warnings {
+			if w.Path != filepath.Join(dir, test.path) {
+				t.Errorf("%s: warning for %s", test.path, w.Path)
+			}
+			got = append(got, w.Message)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("%s: got warnings %q; want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestOnPackage(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// a -> b -> c, a -> c; dependencies are recorded with absolute paths
+	path := func(name string) string { return filepath.Join(dir, name) }
+	fset := token.NewFileSet()
+	c := typecheck(t, fset, path("c"), "package c; type C int")
+	b := typecheck(t, fset, path("b"), fmt.Sprintf("package b; import %q; type B struct{ C c.C }", c.Path()), c)
+	a := typecheck(t, fset, path("a"), fmt.Sprintf("package a; import (%q; %q); var A b.B; var C c.C", b.Path(), c.Path()), b, c)
+	for _, pkg := range []*types.Package{a, b, c} {
+		writeObject(t, dir, pkg.Name(), BExportData(fset, pkg))
+	}
+
+	var got []string
+	imp := &Importer{OnPackage: func(pkg *types.Package) {
+		if !pkg.Complete() {
+			t.Errorf("%s reported before it is complete", pkg.Path())
+		}
+		got = append(got, pkg.Name())
+	}}
+	pkg, err := imp.ImportFrom("./a", dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[c b a]"; fmt.Sprint(got) != want {
+		t.Errorf("OnPackage called for %v; want %s", got, want)
+	}
+	for _, dep := range pkg.Imports() {
+		if !dep.Complete() {
+			t.Errorf("dependency %s not imported", dep.Path())
+		}
+	}
+
+	// packages are reported once
+	if _, err := imp.ImportFrom("./b", dir, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Errorf("OnPackage called for %v after re-import", got)
+	}
+}
+
+func TestFindCache(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// a and b both depend on c
+	path := func(name string) string { return filepath.Join(dir, name) }
+	fset := token.NewFileSet()
+	c := typecheck(t, fset, path("c"), "package c; type C int")
+	b := typecheck(t, fset, path("b"), fmt.Sprintf("package b; import %q; var B c.C", c.Path()), c)
+	a := typecheck(t, fset, path("a"), fmt.Sprintf("package a; import %q; var A c.C", c.Path()), c)
+	for _, pkg := range []*types.Package{a, b, c} {
+		writeObject(t, dir, pkg.Name(), BExportData(fset, pkg))
+	}
+
+	calls := make(map[string]int)
+	defer func(f func(path, srcDir string) (string, string)) { findPkgFunc = f }(findPkgFunc)
+	findPkgFunc = func(path, srcDir string) (string, string) {
+		calls[path]++
+		return FindPkg(path, srcDir)
+	}
+
+	imp := new(Importer)
+	for _, name := range []string{"./a", "./b", "./a", ".//a/"} {
+		if _, err := imp.ImportFrom(name, dir, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for path, n := range calls {
+		if n != 1 {
+			t.Errorf("%s resolved %d times; want 1", path, n)
+		}
+	}
+	if len(calls) != 3 {
+		t.Errorf("resolved %v; want ./a, ./b, and c", calls)
+	}
+
+	imp.ClearFindCache()
+	if _, err := imp.ImportFrom("./a", dir, 0); err != nil {
+		t.Fatal(err)
+	}
+	if calls["./a"] != 2 {
+		t.Errorf("./a resolved %d times after ClearFindCache; want 2", calls["./a"])
+	}
+}
+
+// writeChain writes object files for packages a, b, and c to dir,
+// where a depends on b and c, and b on c.
+func TestModuleRoots(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// modules a and b both contain example.com/p and example.com/q,
+	// which depends on p, compiled into obj directories
+	for _, mod := range []struct{ name, val string }{{"a", "1"}, {"b", `"b"`}} {
+		root := filepath.Join(dir, mod.name)
+		writeFiles(t, root, map[string]string{"go.mod": "module example.com\n", "cmd/main.go": "package main\n"})
+		if err := os.Mkdir(filepath.Join(root, "obj"), 0777); err != nil {
+			t.Fatal(err)
+		}
+		fset := token.NewFileSet()
+		p := typecheck(t, fset, "example.com/p", "package p; const C = "+mod.val+"; type T int")
+		q := typecheck(t, fset, "example.com/q", `package q; import "example.com/p"; var V p.T`, p)
+		writeObject(t, filepath.Join(root, "obj"), "p", BExportData(fset, p))
+		writeObject(t, filepath.Join(root, "obj"), "q", BExportData(fset, q))
+	}
+	defer func(f func(path, srcDir string) (string, string)) { findPkgFunc = f }(findPkgFunc)
+	findPkgFunc = func(path, srcDir string) (string, string) {
+		return filepath.Join(moduleRoot(srcDir), "obj", strings.TrimPrefix(path, "example.com/")+".o"), path
+	}
+
+	imp := new(Importer)
+	importFrom := func(path, srcDir string) *types.Package {
+		pkg, err := imp.ImportFrom(path, filepath.Join(dir, srcDir), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pkg
+	}
+	qa := importFrom("example.com/q", "a/cmd")
+	qb := importFrom("example.com/q", "b")
+	pa := importFrom("example.com/p", "a")
+	pb := importFrom("example.com/p", "b/cmd")
+	if pa == pb || qa == qb {
+		t.Fatalf("packages of modules a and b collide")
+	}
+	for _, test := range []struct {
+		p, q *types.Package
+		val  string
+	}{
+		{pa, qa, "1"},
+		{pb, qb, `"b"`},
+	} {
+		if got := test.p.Scope().Lookup("C").(*types.Const).Val().String(); got != test.val {
+			t.Errorf("%s.C = %s; want %s", test.p.Path(), got, test.val)
+		}
+		if got := test.q.Scope().Lookup("V").Type().(*types.Named).Obj().Pkg(); got != test.p {
+			t.Errorf("type of q.V declared in package %p; want %p of the same module", got, test.p)
+		}
+	}
+	if got := imp.Packages()["example.com/p"]; got != pa {
+		t.Errorf("Packages lists package p %p; want %p of module a, imported first", got, pa)
+	}
+}
+
+func writeChain(t testing.TB, dir string) {
+	path := func(name string) string { return filepath.Join(dir, name) }
+	fset := token.NewFileSet()
+	c := typecheck(t, fset, path("c"), "package c; type C struct{ X int }; func (C) M() {}")
+	b := typecheck(t, fset, path("b"), fmt.Sprintf("package b; import %q; type B struct{ C c.C }", c.Path()), c)
+	a := typecheck(t, fset, path("a"), fmt.Sprintf("package a; import (%q; %q); var A b.B; func F(c.C) {}", b.Path(), c.Path()), b, c)
+	for _, pkg := range []*types.Package{a, b, c} {
+		writeObject(t, dir, pkg.Name(), BExportData(fset, pkg))
+	}
+}
+
+func TestCachedImportAllocs(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeChain(t, dir)
+
+	imp := new(Importer)
+	if _, err := imp.ImportFrom("./b", dir, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// a is decoded, but its dependencies are complete
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, err := imp.ImportFrom("./a", dir, 0); err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+	first := after.Mallocs - before.Mallocs
+
+	// a is complete
+	cached := testing.AllocsPerRun(100, func() {
+		if _, err := imp.ImportFrom("./a", dir, 0); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if cached != 0 || first == 0 {
+		t.Errorf("import of a allocated %d times, then %v times; want >0, then 0", first, cached)
+	}
+}
+
+func BenchmarkCachedImport(b *testing.B) {
+	dir, err := ioutil.TempDir("", "gcimporter")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeChain(b, dir)
+
+	imp := new(Importer)
+	if _, err := imp.ImportFrom("./a", dir, 0); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := imp.ImportFrom("./a", dir, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// a -> b, with a proposed new function added to b in the overlay
+	path := func(name string) string { return filepath.Join(dir, name) }
+	fset := token.NewFileSet()
+	b := typecheck(t, fset, path("b"), "package b; type B int")
+	a := typecheck(t, fset, path("a"), fmt.Sprintf("package a; import %q; var A b.B", b.Path()), b)
+	writeObject(t, dir, "a", BExportData(fset, a))
+	writeObject(t, dir, "b", BExportData(fset, b))
+	b2 := typecheck(t, fset, path("b"), "package b; type B int; func New() B { return 0 }")
+
+	imp := &Importer{Overlay: map[string][]byte{
+		b.Path(): objectFile(runtime.GOARCH, BExportData(fset, b2)),
+	}}
+	pkg, err := imp.ImportFrom("./a", dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Scope().Lookup("A") == nil {
+		t.Errorf("A not found in a")
+	}
+	dep, err := imp.ImportFrom(b.Path(), dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dep != pkg.Imports()[0] || dep.Scope().Lookup("New") == nil {
+		t.Errorf("b was not imported from the overlay: scope %v", dep.Scope().Names())
+	}
+
+	// without the overlay, the installed b is used
+	dep, err = new(Importer).ImportFrom("./b", dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dep.Scope().Lookup("New") != nil {
+		t.Errorf("installed b has overlay function New")
+	}
+}
+
+func TestMaxPackages(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// a -> b -> c -> d
+	path := func(name string) string { return filepath.Join(dir, name) }
+	fset := token.NewFileSet()
+	var deps []*types.Package
+	src := "type T int"
+	for _, name := range []string{"d", "c", "b", "a"} {
+		pkg := typecheck(t, fset, path(name), "package "+name+"; "+src, deps...)
+		writeObject(t, dir, name, BExportData(fset, pkg))
+		deps = []*types.Package{pkg}
+		src = fmt.Sprintf("import %q; type T struct{ X %s.T }", pkg.Path(), name)
+	}
+
+	imp := &Importer{MaxPackages: 3}
+	_, err := imp.ImportFrom("./a", dir, 0)
+	if !errors.Is(err, ErrTooManyPackages) {
+		t.Fatalf("got error %v; want ErrTooManyPackages", err)
+	}
+	t.Log(err)
+
+	// the limit applies to each import separately
+	if _, err := imp.ImportFrom("./c", dir, 0); err != nil {
+		t.Errorf("import within limit: %v", err)
+	}
+	imp = &Importer{MaxPackages: 4}
+	if _, err := imp.ImportFrom("./a", dir, 0); err != nil {
+		t.Errorf("import within limit: %v", err)
+	}
+}
+
+func TestFindPkg(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	defer func(gopath string) { build.Default.GOPATH = gopath }(build.Default.GOPATH)
+	build.Default.GOPATH = dir
+
+	// example.com/app vendors example.com/lib; ./p is a local package
+	app := filepath.Join(dir, "src", "example.com", "app")
+	writeFiles(t, dir, map[string]string{
+		"src/example.com/app/app.go":                      "package app\n",
+		"src/example.com/app/vendor/example.com/lib/l.go": "package lib\n",
+	})
+	pkgDir := filepath.Join(dir, "pkg", runtime.GOOS+"_"+runtime.GOARCH, "example.com", "app", "vendor", "example.com")
+	if err := os.MkdirAll(pkgDir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	lib := exportSource(t, "example.com/app/vendor/example.com/lib", "package lib; const C = 0")
+	if err := ioutil.WriteFile(filepath.Join(pkgDir, "lib.a"), objectFile(runtime.GOARCH, lib), 0666); err != nil {
+		t.Fatal(err)
+	}
+	writeObject(t, app, "p", exportSource(t, "p", "package p; const C = 0"))
+
+	for _, test := range []struct {
+		paths    []string
+		filename string
+		id       string
+	}{
+		{[]string{"example.com/lib", "example.com//lib/"}, filepath.Join(pkgDir, "lib.a"), "example.com/app/vendor/example.com/lib"},
+		{[]string{"./p", "./././p", ".//p/"}, filepath.Join(app, "p.o"), filepath.Join(app, "p")},
+		{[]string{"example.com/missing", "./missing"}, "", ""},
+	} {
+		for _, path := range test.paths {
+			filename, id := FindPkg(path, app)
+			if filename != test.filename || test.filename != "" && id != test.id {
+				t.Errorf("FindPkg(%q) = %q, %q; want %q, %q", path, filename, id, test.filename, test.id)
+				continue
+			}
+			if filename == "" {
+				continue
+			}
+			// Import records the package under id
+			packages := make(map[string]*types.Package)
+			pkg, err := Import(packages, path, app)
+			if err != nil {
+				t.Errorf("Import(%q): %v", path, err)
+				continue
+			}
+			if packages[id] != pkg {
+				t.Errorf("Import(%q) did not record the package under %q", path, id)
+			}
+		}
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	for _, test := range []struct {
+		path, want string
+	}{
+		{"", ""},
+		{".", "."},
+		{"./", "."},
+		{"..", ".."},
+		{"../", ".."},
+		{"./p", "./p"},
+		{"./././testdata/p", "./testdata/p"},
+		{"./testdata/../p", "./p"},
+		{"./p/..", "."},
+		{"../x/../y", "../y"},
+		{"../../x", "../../x"},
+		{".//p//q/", "./p/q"},
+		{"/abs//p/", "/abs/p"},
+		{"fmt", "fmt"},
+		{"golang.org//x/tools/", "golang.org/x/tools"},
+		{"a/./b/../c", "a/c"},
+	} {
+		got := NormalizePath(test.path)
+		if got != test.want {
+			t.Errorf("NormalizePath(%q) = %q; want %q", test.path, got, test.want)
+		}
+		if again := NormalizePath(got); again != got {
+			t.Errorf("NormalizePath(%q) = %q; not idempotent", got, again)
+		}
+	}
+}
+
+func TestImportReader(t *testing.T) {
+	for _, test := range []struct {
+		format string
+		data   []byte
+	}{
+		{"binary", objectFile(runtime.GOARCH, exportSource(t, "example.com/p", "package p; type T struct{}; func Named() (n int, err error) { return }"))},
+		{"textual", []byte("go object " + runtime.GOOS + " " + runtime.GOARCH + " go1.7 X:none\n\n$$\n" + resultNamesSrc)},
+	} {
+		packages := make(map[string]*types.Package)
+		pkg, err := ImportReader(packages, bytes.NewReader(test.data), "example.com/p")
+		if err != nil {
+			t.Errorf("%s: %v", test.format, err)
+			continue
+		}
+		if packages["example.com/p"] != pkg || !pkg.Complete() {
+			t.Errorf("%s: package not recorded in packages map", test.format)
+		}
+		if got := fmt.Sprint(resultNames(t, pkg, "Named")); got != "[n err]" {
+			t.Errorf("%s: Named results: got %s; want [n err]", test.format, got)
+		}
+	}
+
+	if _, err := ImportReader(make(map[string]*types.Package), strings.NewReader("package p\n"), "example.com/p"); err == nil {
+		t.Errorf("import of source file succeeded")
+	} else if !strings.Contains(err.Error(), "example.com/p") {
+		t.Errorf("error %q does not mention the package path", err)
+	}
+}
+
+func TestImportPartial(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// a depends on b and on m, which is not available
+	path := func(name string) string { return filepath.Join(dir, name) }
+	fset := token.NewFileSet()
+	m := typecheck(t, fset, path("m"), "package m; type M int")
+	b := typecheck(t, fset, path("b"), "package b; type B int")
+	a := typecheck(t, fset, path("a"), fmt.Sprintf("package a; import (%q; %q); var A b.B; var M m.M", b.Path(), m.Path()), b, m)
+	writeObject(t, dir, "a", BExportData(fset, a))
+	writeObject(t, dir, "b", BExportData(fset, b))
+
+	if _, err := new(Importer).ImportFrom("./a", dir, 0); err == nil {
+		t.Errorf("strict import succeeded despite missing dependency")
+	}
+
+	packages := make(map[string]*types.Package)
+	pkg, missing, err := ImportPartial(packages, "./a", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprint([]string{m.Path()}); fmt.Sprint(missing) != want {
+		t.Errorf("missing = %v; want %s", missing, want)
+	}
+	if !packages[b.Path()].Complete() {
+		t.Errorf("available dependency b not imported")
+	}
+	placeholder := packages[m.Path()]
+	if placeholder.Complete() || placeholder.Name() != "m" {
+		t.Errorf("got placeholder %v (complete = %v); want incomplete package m", placeholder, placeholder.Complete())
+	}
+	if got, want := pkg.Scope().Lookup("M").Type().String(), m.Path()+".M"; got != want {
+		t.Errorf("type of M = %s; want %s", got, want)
+	}
+}
+
+func TestImportUnsafe(t *testing.T) {
+	pkg, err := Import(make(map[string]*types.Package), "unsafe", "")
+	if err != nil || pkg != types.Unsafe {
+		t.Errorf("Import: got %v, %v; want types.Unsafe", pkg, err)
+	}
+
+	// no export data is looked for
+	imp := &Importer{
+		GoList: true,
+		OnResolveFail: func(path, srcDir string, tried []string) (string, bool) {
+			t.Errorf("OnResolveFail called for %s", path)
+			return "", false
+		},
+	}
+	pkg, err = imp.ImportFrom("unsafe", "", 0)
+	if err != nil || pkg != types.Unsafe {
+		t.Errorf("ImportFrom: got %v, %v; want types.Unsafe", pkg, err)
+	}
+	if len(imp.found) != 0 || len(imp.listed) != 0 {
+		t.Errorf("export data looked up for unsafe")
+	}
+}
+
+func TestImportsSorted(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	names := []string{"z", "m", "y", "a", "x", "b"}
+	var deps []*types.Package
+	var src, decls, textual bytes.Buffer
+	src.WriteString("package p\n")
+	fmt.Fprintf(&textual, "go object %s %s go1.7 X:none\n\n$$\npackage p\n", runtime.GOOS, runtime.GOARCH)
+	fset := token.NewFileSet()
+	for _, name := range names {
+		deps = append(deps, typecheck(t, fset, name, fmt.Sprintf("package %s; type T int", name)))
+		fmt.Fprintf(&src, "import %q\n", name)
+		fmt.Fprintf(&decls, "var _ %s.T\n", name)
+		fmt.Fprintf(&textual, "import %s %q\n", name, name)
+	}
+	textual.WriteString("$$\n")
+	p := typecheck(t, fset, "p", src.String()+decls.String(), deps...)
+
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	want := fmt.Sprint(sorted)
+	for _, test := range []struct {
+		name string
+		obj  []byte
+	}{
+		{"binary", objectFile(runtime.GOARCH, BExportData(fset, p))},
+		{"textual", textual.Bytes()},
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "p.o"), test.obj, 0666); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 10; i++ {
+			pkg, err := Import(make(map[string]*types.Package), "./p", dir)
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			var paths []string
+			for _, dep := range pkg.Imports() {
+				paths = append(paths, dep.Path())
+			}
+			if got := fmt.Sprint(paths); got != want {
+				t.Fatalf("%s: Imports() = %s; want %s", test.name, got, want)
+			}
+		}
+	}
+}
+
+func TestImportAll(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// c depends on b, which depends on a
+	path := func(name string) string { return filepath.Join(dir, name) }
+	fset := token.NewFileSet()
+	a := typecheck(t, fset, path("a"), "package a; type A int; func F() {}")
+	b := typecheck(t, fset, path("b"), fmt.Sprintf("package b; import %q; type B a.A", a.Path()), a)
+	c := typecheck(t, fset, path("c"), fmt.Sprintf("package c; import %q; var C b.B", b.Path()), b)
+	writeObject(t, dir, "a", BExportData(fset, a))
+	writeObject(t, dir, "b", BExportData(fset, b))
+	writeObject(t, dir, "c", BExportData(fset, c))
+
+	// importing b alone leaves a incomplete
+	packages := make(map[string]*types.Package)
+	if _, err := Import(packages, "./b", dir); err != nil {
+		t.Fatal(err)
+	}
+	if packages[a.Path()].Complete() {
+		t.Fatalf("a imported completely by Import")
+	}
+
+	pkg, err := ImportAll(packages, "./c", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg != packages[c.Path()] {
+		t.Errorf("ImportAll returned %v; want %v", pkg, packages[c.Path()])
+	}
+	for _, p := range []*types.Package{a, b, c} {
+		if imported := packages[p.Path()]; imported == nil || !imported.Complete() {
+			t.Errorf("%s not imported completely", p.Name())
+		}
+	}
+	if packages[a.Path()].Scope().Lookup("F") == nil {
+		t.Errorf("F not found in a")
+	}
+}
+
+func TestPlaceholderFactory(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// a depends on b, and both depend on m, which is not available
+	path := func(name string) string { return filepath.Join(dir, name) }
+	fset := token.NewFileSet()
+	m := typecheck(t, fset, path("m"), "package m; type M int; type N int")
+	b := typecheck(t, fset, path("b"), fmt.Sprintf("package b; import %q; type B m.N", m.Path()), m)
+	a := typecheck(t, fset, path("a"), fmt.Sprintf("package a; import (%q; %q); var A b.B; var M m.M", b.Path(), m.Path()), b, m)
+	writeObject(t, dir, "a", BExportData(fset, a))
+	writeObject(t, dir, "b", BExportData(fset, b))
+
+	var created []string
+	imp := &Importer{
+		PlaceholderFactory: func(path string) *types.Package {
+			created = append(created, path)
+			return types.NewPackage(path, "missing_"+filepath.Base(path))
+		},
+	}
+	pkg, err := imp.ImportFrom("./a", dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprint([]string{m.Path()}); fmt.Sprint(created) != want {
+		t.Errorf("PlaceholderFactory called for %v; want %s", created, want)
+	}
+	order, err := LoadOrder(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var closure []string
+	for _, dep := range order {
+		closure = append(closure, fmt.Sprintf("%s %s %v", dep.Name(), filepath.Base(dep.Path()), dep.Complete()))
+	}
+	if want := []string{"missing_m m false", "b b true", "a a true"}; fmt.Sprint(closure) != fmt.Sprint(want) {
+		t.Errorf("closure = %q; want %q", closure, want)
+	}
+	placeholder := imp.Packages()[m.Path()]
+	if owner := pkg.Scope().Lookup("M").Type().(*types.Named).Obj().Pkg(); owner != placeholder {
+		t.Errorf("type of M declared in %v; want placeholder %v", owner, placeholder)
+	}
+	if imports := imp.Packages()[b.Path()].Imports(); len(imports) != 1 || imports[0] != placeholder {
+		t.Errorf("b imports %v; want placeholder %v", imports, placeholder)
+	}
+
+	// by default, placeholders are complete and named as recorded
+	imp = &Importer{PlaceholderFactory: func(string) *types.Package { return nil }}
+	if _, err := imp.ImportFrom("./a", dir, 0); err != nil {
+		t.Fatal(err)
+	}
+	if placeholder := imp.Packages()[m.Path()]; placeholder.Name() != "m" || !placeholder.Complete() {
+		t.Errorf("got placeholder %v (complete = %v); want complete package m", placeholder, placeholder.Complete())
+	}
+}
+
+func TestRepairNames(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// the export data of dir/p declares package q
+	fset := token.NewFileSet()
+	writeObject(t, dir, "p", BExportData(fset, typecheck(t, fset, "p", "package q; const C = 0")))
+
+	local := types.NewPackage(filepath.Join(dir, "p"), "")
+	versioned := types.NewPackage("example.com/go-yaml/v2", "")
+	named := types.NewPackage("example.com/named", "other")
+	named.SetImports([]*types.Package{versioned}) // reachable only through named
+	packages := map[string]*types.Package{
+		local.Path(): local,
+		named.Path(): named,
+	}
+
+	repaired := RepairNames(packages)
+	want := []string{versioned.Path(), local.Path()}
+	sort.Strings(want)
+	if got, want := fmt.Sprint(repaired), fmt.Sprint(want); got != want {
+		t.Errorf("RepairNames repaired %s; want %s", got, want)
+	}
+	for _, test := range []struct {
+		pkg  *types.Package
+		want string
+	}{
+		{local, "q"},           // from export data
+		{versioned, "go_yaml"}, // from path
+		{named, "other"},       // untouched
+	} {
+		if got := test.pkg.Name(); got != test.want {
+			t.Errorf("name of %s = %q; want %q", test.pkg.Path(), got, test.want)
+		}
+	}
+
+	if repaired := RepairNames(packages); len(repaired) != 0 {
+		t.Errorf("second RepairNames repaired %v", repaired)
+	}
+}
+
+func TestImportHeader(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// b is imported but unused in the exported API of a
+	path := func(name string) string { return filepath.Join(dir, name) }
+	fset := token.NewFileSet()
+	c := typecheck(t, fset, path("c"), "package c; type C int")
+	b := typecheck(t, fset, path("b"), "package b; func F() {}")
+	a := typecheck(t, fset, path("a"), fmt.Sprintf(`package a
+import (%q; %q; "unsafe")
+var _ = b.F
+var C c.C
+var P unsafe.Pointer
+`, b.Path(), c.Path()), b, c, types.Unsafe)
+	writeObject(t, dir, "a", BExportData(fset, a))
+
+	name, imports, err := ImportHeader("./a", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := Import(make(map[string]*types.Package), "./a", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for _, imp := range pkg.Imports() {
+		want = append(want, imp.Path())
+	}
+	if name != "a" || fmt.Sprint(imports) != fmt.Sprint(want) {
+		t.Errorf("ImportHeader = %s, %v; want a, %v", name, imports, want)
+	}
+
+	// textual export data
+	const src = "go object linux amd64 go1.6 X:none\n\n$$\npackage t\nimport q \"q\"\nimport r \"r\"\nvar @\"\".V @\"q\".T\n$$\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "t.o"), []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+	name, imports, err = ImportHeader("./t", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "t" || fmt.Sprint(imports) != "[q r]" {
+		t.Errorf("ImportHeader = %s, %v; want t, [q r]", name, imports)
+	}
+}
+
+func TestRequireComplete(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// a depends on b and on m, which is not available
+	path := func(name string) string { return filepath.Join(dir, name) }
+	fset := token.NewFileSet()
+	m := typecheck(t, fset, path("m"), "package m; type M int")
+	b := typecheck(t, fset, path("b"), "package b; type B int")
+	a := typecheck(t, fset, path("a"), fmt.Sprintf("package a; import (%q; %q); var A b.B; var M m.M", b.Path(), m.Path()), b, m)
+	writeObject(t, dir, "a", BExportData(fset, a))
+	writeObject(t, dir, "b", BExportData(fset, b))
+
+	// partial imports leave a placeholder for m
+	var missing []string
+	imp := &Importer{RequireComplete: true, missing: &missing}
+	_, err := imp.ImportFrom("./a", dir, 0)
+	if !errors.Is(err, ErrIncompleteClosure) {
+		t.Fatalf("got error %v; want ErrIncompleteClosure", err)
+	}
+	if !strings.Contains(err.Error(), m.Path()) {
+		t.Errorf("error %q does not mention %s", err, m.Path())
+	}
+
+	// b's closure is complete
+	if _, err := imp.ImportFrom("./b", dir, 0); err != nil {
+		t.Errorf("import of b: %v", err)
+	}
+	writeObject(t, dir, "m", BExportData(fset, m))
+	imp = &Importer{RequireComplete: true}
+	if _, err := imp.ImportFrom("./a", dir, 0); err != nil {
+		t.Errorf("import of a with all dependencies: %v", err)
+	}
+}
+
+func TestSurfaceOnly(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// a uses T of b, but not U or F
+	path := func(name string) string { return filepath.Join(dir, name) }
+	fset := token.NewFileSet()
+	b := typecheck(t, fset, path("b"), "package b; type T struct{ X int }; func (T) M() {}; type U int; func F() U { return 0 }")
+	a := typecheck(t, fset, path("a"), fmt.Sprintf("package a; import %q; func A() b.T { return b.T{} }", b.Path()), b)
+	writeObject(t, dir, "a", BExportData(fset, a))
+	writeObject(t, dir, "b", BExportData(fset, b))
+
+	imp := &Importer{SurfaceOnly: true}
+	pkgA, err := imp.ImportFrom("./a", dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgB := imp.Packages()[b.Path()]
+	if pkgB == nil {
+		t.Fatalf("no placeholder for %s", b.Path())
+	}
+	if pkgB.Complete() {
+		t.Errorf("dependency b imported completely")
+	}
+	if got, want := fmt.Sprint(pkgB.Scope().Names()), "[T]"; got != want {
+		t.Errorf("objects of b: got %s; want %s", got, want)
+	}
+
+	// a type-checks, including the methods of b.T
+	f, err := goparser.ParseFile(fset, "user.go", fmt.Sprintf("package user; import %q; var x = a.A().X; func init() { a.A().M() }", pkgA.Path()), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: depsImporter{pkgA}}
+	if _, err := conf.Check("user", fset, []*ast.File{f}, nil); err != nil {
+		t.Errorf("type-checking user of a: %v", err)
+	}
+
+	// importing b itself completes it
+	if pkg, err := imp.ImportFrom("./b", dir, 0); err != nil {
+		t.Fatal(err)
+	} else if pkg != pkgB || !pkg.Complete() {
+		t.Errorf("import of b did not complete the placeholder")
+	}
+	if pkgB.Scope().Lookup("F") == nil {
+		t.Errorf("b.F missing after importing b")
+	}
+}
+
+func TestNames(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// a depends on b
+	path := func(name string) string { return filepath.Join(dir, name) }
+	fset := token.NewFileSet()
+	b := typecheck(t, fset, path("b"), "package b; type B int")
+	a := typecheck(t, fset, path("a"), fmt.Sprintf("package a; import %q; var A b.B", b.Path()), b)
+	writeObject(t, dir, "a", BExportData(fset, a))
+	writeObject(t, dir, "b", BExportData(fset, b))
+
+	// textual export data
+	const src = "go object linux amd64 go1.6 X:none\n\n$$\npackage t\nimport q \"q\"\nvar @\"\".V @\"q\".T\n$$\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "t.o"), []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	imp := &Importer{Names: map[string]string{
+		b.Path(): "renamed",
+		"q":      "quux",
+	}}
+	pkgA, err := imp.ImportFrom("./a", dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// no export data for q: import t only
+	pkgT, err := (&Importer{Names: imp.Names, SurfaceOnly: true}).ImportFrom("./t", dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		pkg  *types.Package
+		want string
+	}{
+		{pkgA, "a"}, // not overridden
+		{pkgA.Scope().Lookup("A").Type().(*types.Named).Obj().Pkg(), "renamed"},
+		{pkgT.Imports()[0], "quux"},
+	} {
+		if got := test.pkg.Name(); got != test.want {
+			t.Errorf("name of %s = %q; want %q", test.pkg.Path(), got, test.want)
+		}
+	}
+
+	// importing b itself keeps the overridden name
+	pkgB, err := imp.ImportFrom("./b", dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkgB.Name() != "renamed" {
+		t.Errorf("name of imported b = %q; want renamed", pkgB.Name())
+	}
+}
+
+func TestCgoPseudoPackage(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// go/types declares a fake package C for cgo packages
+	const src = `package a
+import "C"
+type T C.int
+func F(x C.long) int { return 0 }
+var V int
+`
+	fset := token.NewFileSet()
+	f, err := goparser.ParseFile(fset, "a.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{FakeImportC: true}
+	a, err := conf.Check(filepath.Join(dir, "a"), fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeObject(t, dir, "a", BExportData(fset, a))
+
+	// lowered cgo types refer to package C
+	b := types.NewPackage(filepath.Join(dir, "b"), "b")
+	cint := types.NewNamed(types.NewTypeName(token.NoPos, types.NewPackage("C", "C"), "int", nil), types.Typ[types.Int32], nil)
+	b.Scope().Insert(types.NewVar(token.NoPos, b, "V", cint))
+	writeObject(t, dir, "b", BExportData(nil, b))
+
+	for _, name := range []string{"a", "b"} {
+		if _, imports, err := ImportHeader("./"+name, dir); err != nil {
+			t.Fatal(err)
+		} else if len(imports) != 0 {
+			t.Errorf("ImportHeader(%s) lists imports %v", name, imports)
+		}
+		imp := &Importer{RequireComplete: true}
+		pkg, err := imp.ImportFrom("./"+name, dir, 0)
+		if err != nil {
+			t.Errorf("import of %s: %v", name, err)
+			continue
+		}
+		order, err := LoadOrder(pkg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, dep := range order {
+			if dep.Path() == "C" {
+				t.Errorf("package C in the closure of %s", name)
+			}
+		}
+	}
+}
+
+func TestDuplicateImports(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	path := func(name string) string { return filepath.Join(dir, name) }
+	fset := token.NewFileSet()
+	b := typecheck(t, fset, path("b"), "package b; type B int")
+	writeObject(t, dir, "b", BExportData(fset, b))
+
+	// list b twice, the second time as a distinct package
+	// so that its path is written again
+	a := typecheck(t, fset, path("a"), fmt.Sprintf("package a; import %q; var V b.B", b.Path()), b)
+	a.SetImports([]*types.Package{b, types.NewPackage(b.Path(), b.Name())})
+	data := BExportData(fset, a)
+	writeObject(t, dir, "a", data)
+
+	if _, imports, err := ImportHeader("./a", dir); err != nil {
+		t.Fatal(err)
+	} else if want := []string{b.Path()}; fmt.Sprint(imports) != fmt.Sprint(want) {
+		t.Errorf("ImportHeader lists imports %v; want %v", imports, want)
+	}
+
+	_, pkg, err := BImportData(fset, make(map[string]*types.Package), data, a.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	imp := new(Importer)
+	pkg2, err := imp.ImportFrom("./a", dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkg := range []*types.Package{pkg, pkg2} {
+		imports := pkg.Imports()
+		if len(imports) != 1 {
+			t.Errorf("%s imports %v; want a single package", pkg.Path(), imports)
+			continue
+		}
+		named := pkg.Scope().Lookup("V").Type().(*types.Named)
+		if got := named.Obj().Pkg(); got != imports[0] {
+			t.Errorf("type %s of %s.V belongs to %p; want imported package %p", named, pkg.Path(), got, imports[0])
+		}
+	}
+}
+
+func TestOnResolveFail(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// p is compiled into an unconventional location
+	alt := filepath.Join(dir, "obj", "p")
+	if err := os.MkdirAll(alt, 0777); err != nil {
+		t.Fatal(err)
+	}
+	writeObject(t, alt, "_go_", exportSource(t, "p", "package p; const C = 42"))
+
+	var tried []string
+	imp := &Importer{
+		OnResolveFail: func(path, srcDir string, list []string) (string, bool) {
+			if path != "./p" || srcDir != dir {
+				t.Errorf("OnResolveFail called for %q in %q", path, srcDir)
+			}
+			tried = list
+			return filepath.Join(alt, "_go_.o"), true
+		},
+	}
+	pkg, err := imp.ImportFrom("./p", dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj := pkg.Scope().Lookup("C"); obj == nil {
+		t.Errorf("%s.C not found", pkg.Path())
+	}
+	if want := []string{filepath.Join(dir, "p.a"), filepath.Join(dir, "p.o")}; fmt.Sprint(tried) != fmt.Sprint(want) {
+		t.Errorf("OnResolveFail got tried paths %q; want %q", tried, want)
+	}
+
+	imp = &Importer{
+		OnResolveFail: func(path, srcDir string, tried []string) (string, bool) { return "", false },
+	}
+	if _, err := imp.ImportFrom("./q", dir, 0); err == nil {
+		t.Errorf("import of ./q succeeded despite failed resolution")
+	}
+}
+
+func TestOnAllocProfile(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// a depends on b
+	path := func(name string) string { return filepath.Join(dir, name) }
+	fset := token.NewFileSet()
+	b := typecheck(t, fset, path("b"), "package b; type B struct{ X, Y int }; func (B) M() {}")
+	a := typecheck(t, fset, path("a"), fmt.Sprintf("package a; import %q; var A b.B", b.Path()), b)
+	writeObject(t, dir, "a", BExportData(fset, a))
+	writeObject(t, dir, "b", BExportData(fset, b))
+
+	allocs := make(map[string]int64)
+	imp := &Importer{OnAllocProfile: func(path string, bytes int64) {
+		if _, dup := allocs[path]; dup {
+			t.Errorf("OnAllocProfile called twice for %s", path)
+		}
+		allocs[path] = bytes
+	}}
+	if _, err := imp.ImportFrom("./a", dir, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(allocs) != 2 {
+		t.Errorf("OnAllocProfile called for %v; want %s and %s", allocs, a.Path(), b.Path())
+	}
+	for _, path := range []string{a.Path(), b.Path()} {
+		if bytes, ok := allocs[path]; !ok {
+			t.Errorf("OnAllocProfile not called for %s", path)
+		} else if bytes < 0 {
+			t.Errorf("OnAllocProfile(%s, %d): negative allocation", path, bytes)
+		}
+	}
+}
+
+func TestOnType(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	const src = `package p
+type Celsius float64
+type Point struct{ X, Y Celsius }
+func (p Point) Dist() Celsius { return 0 }
+type unexported int
+var V unexported
+`
+	writeObject(t, dir, "p", exportSource(t, "p", src))
+
+	// count the types without altering them
+	var names []string
+	imp := &Importer{OnType: func(typ types.Type) types.Type {
+		names = append(names, typ.(*types.Named).Obj().Name())
+		return typ
+	}}
+	pkg, err := imp.ImportFrom("./p", dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(names), "[Celsius Point unexported]"; got != want {
+		t.Errorf("OnType called for %s; want %s", got, want)
+	}
+	check := func(pkg *types.Package, src string) error {
+		fset := token.NewFileSet()
+		f, err := goparser.ParseFile(fset, "user.go", src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conf := types.Config{Importer: depsImporter{pkg}}
+		_, err = conf.Check("user", fset, []*ast.File{f}, nil)
+		return err
+	}
+	user := fmt.Sprintf("package user; import p %q; var d p.Celsius = p.Point{}.Dist()", pkg.Path())
+	if err := check(pkg, user); err != nil {
+		t.Errorf("type-checking user of p: %v", err)
+	}
+
+	// replace Celsius by its underlying type
+	imp = &Importer{OnType: func(typ types.Type) types.Type {
+		if named := typ.(*types.Named); named.Obj().Name() == "Celsius" {
+			return named.Underlying()
+		}
+		return typ
+	}}
+	pkg, err = imp.ImportFrom("./p", dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ := pkg.Scope().Lookup("Celsius").Type(); typ != types.Typ[types.Float64] {
+		t.Errorf("Celsius denotes %s; want float64", typ)
+	}
+	user = fmt.Sprintf("package user; import p %q; var d p.Celsius = 1.5; var f float64 = d", pkg.Path())
+	if err := check(pkg, user); err != nil {
+		t.Errorf("type-checking user of p: %v", err)
+	}
+}
+
+func TestImportRedacted(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	const src = `package p
+type Secret struct{ Key string }
+func (s Secret) Reveal() string { return s.Key }
+const SecretKey = "k"
+type Box struct{ S Secret }
+func Open() Box { return Box{} }
+`
+	writeObject(t, dir, "p", exportSource(t, "p", src))
+	redact := func(name string) bool { return strings.HasPrefix(name, "Secret") }
+	pkg, err := ImportRedacted(make(map[string]*types.Package), "./p", dir, redact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(pkg.Scope().Names()), "[Box Open]"; got != want {
+		t.Errorf("scope names = %s; want %s", got, want)
+	}
+
+	// code using the remaining objects still type-checks
+	user := fmt.Sprintf("package user; import p %q; var key string = p.Open().S.Reveal()", pkg.Path())
+	fset := token.NewFileSet()
+	f, err := goparser.ParseFile(fset, "user.go", user, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := types.Config{Importer: depsImporter{pkg}}
+	if _, err := conf.Check("user", fset, []*ast.File{f}, nil); err != nil {
+		t.Errorf("type-checking user of redacted package: %v", err)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// a and b both depend on c
+	path := func(name string) string { return filepath.Join(dir, name) }
+	fset := token.NewFileSet()
+	c := typecheck(t, fset, path("c"), "package c; type C int")
+	a := typecheck(t, fset, path("a"), fmt.Sprintf("package a; import %q; var V c.C", c.Path()), c)
+	b := typecheck(t, fset, path("b"), fmt.Sprintf("package b; import %q; var V c.C", c.Path()), c)
+	for _, pkg := range []*types.Package{a, b, c} {
+		writeObject(t, dir, pkg.Name(), BExportData(fset, pkg))
+	}
+
+	if Default() != Default() {
+		t.Fatal("Default returns different importers")
+	}
+
+	const n = 10
+	results := make(chan types.Type, 2*n)
+	for i := 0; i < n; i++ {
+		for _, name := range []string{"./a", "./b"} {
+			go func(name string) {
+				pkg, err := ImportFrom(name, dir, 0)
+				if err != nil {
+					t.Error(err)
+					results <- nil
+					return
+				}
+				results <- pkg.Scope().Lookup("V").Type()
+			}(name)
+		}
+	}
+	first := <-results
+	for i := 1; i < 2*n; i++ {
+		if typ := <-results; typ != first {
+			t.Errorf("got distinct types %v and %v for c.C", typ, first)
+		}
+	}
+}
+
+func TestNewImporterConcurrent(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// a and b both depend on c
+	path := func(name string) string { return filepath.Join(dir, name) }
+	fset := token.NewFileSet()
+	c := typecheck(t, fset, path("c"), "package c; type C int")
+	a := typecheck(t, fset, path("a"), fmt.Sprintf("package a; import %q; var V c.C", c.Path()), c)
+	b := typecheck(t, fset, path("b"), fmt.Sprintf("package b; import %q; var V c.C", c.Path()), c)
+	for _, pkg := range []*types.Package{a, b, c} {
+		writeObject(t, dir, pkg.Name(), BExportData(fset, pkg))
+	}
+
+	packages := make(map[string]*types.Package)
+	imp := NewImporter(packages)
+	const n = 10
+	results := make(chan *types.Package, 2*n)
+	for i := 0; i < n; i++ {
+		for _, name := range []string{"./a", "./b"} {
+			go func(name string) {
+				pkg, err := imp.ImportFrom(name, dir, 0)
+				if err != nil {
+					t.Error(err)
+					results <- nil
+					return
+				}
+				results <- pkg.Imports()[0]
+			}(name)
+		}
+	}
+	// packages must not be read before all imports are done
+	var deps []*types.Package
+	for i := 0; i < 2*n; i++ {
+		deps = append(deps, <-results)
+	}
+	for _, dep := range deps {
+		if dep != nil && dep != packages[c.Path()] {
+			t.Errorf("got dependency %p; want %p recorded in packages", dep, packages[c.Path()])
+		}
+	}
+	if len(packages) != 3 {
+		t.Errorf("packages has %d entries; want 3", len(packages))
+	}
+}
+
+func TestLRUImporter(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// p1, p2, p3, p4 -> base
+	path := func(name string) string { return filepath.Join(dir, name) }
+	fset := token.NewFileSet()
+	base := typecheck(t, fset, path("base"), "package base; type T int")
+	writeObject(t, dir, "base", BExportData(fset, base))
+	names := []string{"p1", "p2", "p3", "p4"}
+	for _, name := range names {
+		src := fmt.Sprintf("package %s; import %q; type T struct{ X base.T }", name, base.Path())
+		writeObject(t, dir, name, BExportData(fset, typecheck(t, fset, path(name), src, base)))
+	}
+
+	imp := NewLRUImporter(2)
+	resident := func() (n int) {
+		for _, pkg := range imp.packages {
+			if pkg.Complete() {
+				n++
+			}
+		}
+		return
+	}
+	field := func(pkg *types.Package) types.Type {
+		return pkg.Scope().Lookup("T").Type().Underlying().(*types.Struct).Field(0).Type()
+	}
+
+	var baseT types.Type
+	for _, name := range append(names, "p1", "p3") {
+		pkg, err := imp.ImportFrom("./"+name, dir, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := resident(); got > 2 {
+			t.Errorf("after importing %s: %d packages resident; want at most 2", name, got)
+		}
+		if imp.packages[pkg.Path()] != pkg {
+			t.Errorf("after importing %s: package not resident", name)
+		}
+		// base is kept since it is imported by the packages kept,
+		// so its types remain identical across imports
+		typ := field(pkg)
+		if baseT == nil {
+			baseT = typ
+		} else if !types.Identical(typ, baseT) {
+			t.Errorf("%s: field type %s not identical to the base.T seen before", name, typ)
+		}
+	}
+	// the object files of evicted packages are not retained
+	for path := range imp.lru.blobs {
+		if imp.packages[path] == nil {
+			t.Errorf("object file of evicted package %s retained", path)
+		}
+	}
+	if len(imp.lru.blobs) != resident() {
+		t.Errorf("got %d retained object files; want %d", len(imp.lru.blobs), resident())
+	}
+}
+
+func TestPackages(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// a depends on b and on m, which is not available
+	path := func(name string) string { return filepath.Join(dir, name) }
+	fset := token.NewFileSet()
+	m := typecheck(t, fset, path("m"), "package m; type M int")
+	b := typecheck(t, fset, path("b"), "package b; type B int")
+	a := typecheck(t, fset, path("a"), fmt.Sprintf("package a; import (%q; %q); var A b.B; var M m.M", b.Path(), m.Path()), b, m)
+	writeObject(t, dir, "a", BExportData(fset, a))
+	writeObject(t, dir, "b", BExportData(fset, b))
+
+	imp := NewImporter(nil)
+	if len(imp.Packages()) != 0 {
+		t.Errorf("new Importer has packages %v", imp.Packages())
+	}
+	if _, err := imp.ImportFrom("./a", dir, 0); err == nil {
+		t.Errorf("import succeeded despite missing dependency")
+	}
+	got := fmt.Sprint(imp.Completeness())
+	want := fmt.Sprint(map[string]bool{a.Path(): true, b.Path(): true, m.Path(): false})
+	if got != want {
+		t.Errorf("Completeness() = %s; want %s", got, want)
+	}
+
+	// the map returned is a copy
+	packages := imp.Packages()
+	if len(packages) != 3 || packages[b.Path()].Path() != b.Path() {
+		t.Errorf("Packages() = %v", packages)
+	}
+	delete(packages, b.Path())
+	if imp.Packages()[b.Path()] == nil {
+		t.Errorf("deleting from Packages() result affected the Importer")
+	}
+}
+
+func TestCachingImporter(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// b depends on a, and c on neither
+	path := func(name string) string { return filepath.Join(dir, name) }
+	fset := token.NewFileSet()
+	a := typecheck(t, fset, path("a"), "package a; type A int")
+	b := typecheck(t, fset, path("b"), fmt.Sprintf("package b; import %q; type B a.A", a.Path()), a)
+	c := typecheck(t, fset, path("c"), "package c; type C int")
+	writeObject(t, dir, "a", BExportData(fset, a))
+	writeObject(t, dir, "b", BExportData(fset, b))
+	writeObject(t, dir, "c", BExportData(fset, c))
+
+	imp := NewCachingImporter(nil)
+	importAll := func() (pkgs []*types.Package) {
+		for _, name := range []string{"b", "c"} {
+			pkg, err := imp.ImportFrom("./"+name, dir, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pkgs = append(pkgs, pkg)
+		}
+		return
+	}
+
+	first := importAll()
+	if second := importAll(); second[0] != first[0] || second[1] != first[1] {
+		t.Errorf("unchanged packages imported again")
+	}
+
+	// recompile a with an additional function, changing its size
+	a = typecheck(t, fset, path("a"), "package a; type A int; func F() {}")
+	writeObject(t, dir, "a", BExportData(fset, a))
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path("a")+".o", later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	third := importAll()
+	if third[0] == first[0] {
+		t.Errorf("package b depending on changed package a not imported again")
+	}
+	if third[1] != first[1] {
+		t.Errorf("unchanged package c imported again")
+	}
+	if pkg := imp.Packages()[a.Path()]; pkg == nil || pkg.Scope().Lookup("F") == nil {
+		t.Errorf("changed package a not imported again")
+	}
+
+	imp.Reset()
+	if n := len(imp.Packages()); n != 0 {
+		t.Errorf("after Reset: %d packages; want 0", n)
+	}
+	if fourth := importAll(); fourth[1] == third[1] {
+		t.Errorf("after Reset: package c not imported again")
+	}
+}
@@ -0,0 +1,22 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.18,!go1.25
+
+package gccgoimporter
+
+import "go/types"
+
+// Before Go 1.25, go/types records no variable kinds. See varkind25.go.
+
+type varKind int
+
+const (
+	paramVar varKind = iota
+	recvVar
+	resultVar
+)
+
+// setKind does nothing.
+func setKind(v *types.Var, kind varKind) {}
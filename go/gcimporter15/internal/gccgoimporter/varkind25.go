@@ -0,0 +1,22 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.25
+
+package gccgoimporter
+
+import "go/types"
+
+type varKind = types.VarKind
+
+const (
+	paramVar  = types.ParamVar
+	recvVar   = types.RecvVar
+	resultVar = types.ResultVar
+)
+
+// setKind records the kind of the variable v.
+func setKind(v *types.Var, kind varKind) {
+	v.SetKind(kind)
+}
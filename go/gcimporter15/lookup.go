@@ -0,0 +1,165 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.6
+
+package gcimporter
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"go/build"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// A Lookup function returns a reader to access the object file or
+// archive holding the export data of the package with the given
+// (canonical) import path. If there is no such package, the error
+// should be or wrap ErrNotFound.
+type Lookup func(path string) (io.ReadCloser, error)
+
+// isNotFound reports whether err is or wraps ErrNotFound.
+func isNotFound(err error) bool {
+	for err != nil {
+		if err == ErrNotFound {
+			return true
+		}
+		if x, ok := err.(interface {
+			Is(error) bool
+		}); ok && x.Is(ErrNotFound) {
+			return true
+		}
+		u, ok := err.(interface {
+			Unwrap() error
+		})
+		if !ok {
+			return false
+		}
+		err = u.Unwrap()
+	}
+	return false
+}
+
+// importLookup imports path using imp.Lookup.
+func (imp *Importer) importLookup(packages map[string]*types.Package, path, srcDir string) (*types.Package, error) {
+	if pkg := packages[path]; pkg != nil && pkg.Complete() {
+		return pkg, nil
+	}
+
+	rc, err := imp.Lookup(path)
+	if err != nil {
+		if imp.SourceFallback != nil && isNotFound(err) {
+			return imp.importSource(packages, path, srcDir)
+		}
+		return nil, err
+	}
+	defer rc.Close()
+	return imp.importFile(packages, path, path, rc)
+}
+
+// HTTPLookup returns a Lookup function that fetches the export data
+// for an import path from baseURL/path.a. A response with status
+// 404 Not Found is reported as an error wrapping ErrNotFound.
+// If client is nil, http.DefaultClient is used.
+func HTTPLookup(baseURL string, client *http.Client) Lookup {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	return func(path string) (io.ReadCloser, error) {
+		url := baseURL + "/" + path + ".a"
+		resp, err := client.Get(url)
+		if err != nil {
+			return nil, err
+		}
+		switch resp.StatusCode {
+		case http.StatusOK:
+			return resp.Body, nil
+		case http.StatusNotFound:
+			resp.Body.Close()
+			return nil, &notFoundError{path, url + ": " + resp.Status}
+		default:
+			resp.Body.Close()
+			return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+		}
+	}
+}
+
+// ImportZip returns an Importer for the export data in the ZIP file
+// zipPath, such as a snapshot of the pkg/$GOOS_$GOARCH directory tree
+// of a Go installation. Each archive (path.a) or object file (path.o)
+// in the ZIP file holds the export data of the package with the import
+// path path, relative to the pkg/$GOOS_$GOARCH directory if the member
+// is within one for the host GOOS and GOARCH, and relative to the root
+// of the ZIP file otherwise. Members are read when first imported and
+// retained afterwards. The ZIP file remains open until the Importer's
+// Close method is called.
+//
+func ImportZip(zipPath string) (*Importer, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	z := &zipLookup{
+		name:    zipPath,
+		members: make(map[string]*zip.File),
+		data:    make(map[string][]byte),
+	}
+	pkgDir := "pkg/" + build.Default.GOOS + "_" + build.Default.GOARCH + "/"
+	for _, f := range r.File {
+		path := f.Name
+		if i := strings.Index(path, pkgDir); i >= 0 && (i == 0 || path[i-1] == '/') {
+			path = path[i+len(pkgDir):]
+		}
+		switch {
+		case strings.HasSuffix(path, ".a"):
+			path = strings.TrimSuffix(path, ".a")
+		case strings.HasSuffix(path, ".o"):
+			path = strings.TrimSuffix(path, ".o")
+		default:
+			continue // directory or other file
+		}
+		if z.members[path] == nil || strings.HasSuffix(f.Name, ".a") {
+			z.members[path] = f // prefer archives to object files
+		}
+	}
+	return &Importer{Lookup: z.lookup, closer: r}, nil
+}
+
+// A zipLookup looks up the export data of packages in a ZIP file.
+type zipLookup struct {
+	name    string               // ZIP file name, for errors
+	mu      sync.Mutex           // guards data
+	members map[string]*zip.File // import path -> member
+	data    map[string][]byte    // import path -> member contents, once read
+}
+
+func (z *zipLookup) lookup(path string) (io.ReadCloser, error) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	data, ok := z.data[path]
+	if !ok {
+		f := z.members[path]
+		if f == nil {
+			return nil, &notFoundError{path, "not in " + z.name}
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err = ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading %s in %s: %v", f.Name, z.name, err)
+		}
+		z.data[path] = data
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
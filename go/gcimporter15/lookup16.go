@@ -0,0 +1,37 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.16
+
+package gcimporter
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+)
+
+// FSLookup returns a Lookup function that opens the export data for an
+// import path in the file system fsys, for instance export data
+// embedded in a program: the archive dir/path.a or, failing that, the
+// object file dir/path.o, where dir is a slash-separated directory
+// within fsys, such as "pkg/linux_amd64", or "." for its root.
+// Packages with neither file are reported as errors wrapping
+// ErrNotFound.
+func FSLookup(fsys fs.FS, dir string) Lookup {
+	return func(importPath string) (io.ReadCloser, error) {
+		for _, ext := range pkgExts {
+			name := path.Join(dir, importPath+ext)
+			f, err := fsys.Open(name)
+			if err == nil {
+				return f, nil
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+		}
+		return nil, &notFoundError{importPath, "not in " + dir}
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.16
+
+package gcimporter
+
+import (
+	"errors"
+	"go/token"
+	"runtime"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFSLookup(t *testing.T) {
+	// a depends on b; a is an archive, b an object file
+	fset := token.NewFileSet()
+	b := typecheck(t, fset, "example.com/b", "package b; type B int")
+	a := typecheck(t, fset, "example.com/a", `package a; import "example.com/b"; var A b.B`, b)
+	fsys := fstest.MapFS{
+		"pkg/example.com/a.a": {Data: objectFile(runtime.GOARCH, BExportData(fset, a))},
+		"pkg/example.com/b.o": {Data: objectFile(runtime.GOARCH, BExportData(fset, b))},
+	}
+
+	imp := &Importer{Lookup: FSLookup(fsys, "pkg")}
+	pkg, err := imp.Import("example.com/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := pkg.Scope().Lookup("A").Type().String(), "example.com/b.B"; got != want {
+		t.Errorf("type of A = %s; want %s", got, want)
+	}
+	if dep := imp.Packages()["example.com/b"]; dep == nil || !dep.Complete() {
+		t.Errorf("dependency example.com/b not imported from fsys")
+	}
+
+	if _, err := imp.Import("example.com/c"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("import of missing package: got error %v; want ErrNotFound", err)
+	}
+	if _, err := new(Importer).Import("example.com/a"); err == nil {
+		t.Errorf("import without Lookup succeeded")
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.13
+
+package gcimporter
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"go/build"
+	"go/token"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestHTTPLookup(t *testing.T) {
+	data := exportSource(t, "example.com/p", "package p; type T int")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pkg/example.com/p.a", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(objectFile(runtime.GOARCH, data))
+	})
+	mux.HandleFunc("/pkg/broken.a", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "oops", http.StatusInternalServerError)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	imp := &Importer{Lookup: HTTPLookup(srv.URL+"/pkg/", srv.Client())}
+	pkg, err := imp.Import("example.com/p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Path() != "example.com/p" || pkg.Scope().Lookup("T") == nil {
+		t.Errorf("got package %v with scope %v", pkg, pkg.Scope())
+	}
+
+	_, err = imp.Import("example.com/missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing package: got error %v; want ErrNotFound", err)
+	}
+	_, err = imp.Import("broken")
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("server error: got error %v", err)
+	}
+}
+
+func TestImportZip(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	// b -> example.com/a
+	fset := token.NewFileSet()
+	a := typecheck(t, fset, "example.com/a", "package a; type A int")
+	b := typecheck(t, fset, "b", fmt.Sprintf("package b; import %q; var B a.A", a.Path()), a)
+
+	zipPath := filepath.Join(dir, "pkg.zip")
+	f, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgDir := "go/pkg/" + build.Default.GOOS + "_" + build.Default.GOARCH + "/"
+	w := zip.NewWriter(f)
+	for _, m := range []struct {
+		name string
+		data []byte
+	}{
+		{pkgDir, nil}, // directory entries are ignored
+		{pkgDir + "example.com/", nil},
+		{pkgDir + "example.com/a.a", objectFile(runtime.GOARCH, BExportData(fset, a))},
+		{"b.o", objectFile(runtime.GOARCH, BExportData(fset, b))},
+		{"README", []byte("not export data")},
+	} {
+		fw, err := w.Create(m.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write(m.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	imp, err := ImportZip(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgB, err := imp.Import("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkgA, err := imp.Import("example.com/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := pkgB.Scope().Lookup("B").Type(), pkgA.Scope().Lookup("A").Type(); got != want {
+		t.Errorf("type of b.B is %s; want %s of the imported package", got, want)
+	}
+
+	if _, err := imp.Import("README"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("non-export data member: got error %v; want ErrNotFound", err)
+	}
+	if _, err := ImportZip(filepath.Join(dir, "missing.zip")); err == nil {
+		t.Errorf("ImportZip of missing file succeeded")
+	}
+
+	// Close releases the ZIP file
+	fds := func() int {
+		names, err := ioutil.ReadDir("/proc/self/fd")
+		if err != nil {
+			t.Skipf("cannot count open files: %v", err)
+		}
+		return len(names)
+	}
+	before := fds()
+	imp, err = ImportZip(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fds() != before+1 {
+		t.Errorf("ImportZip did not open the ZIP file")
+	}
+	if err := imp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := fds(); n != before {
+		t.Errorf("after Close: %d open files; want %d", n, before)
+	}
+	if _, err := imp.Import("b"); err == nil {
+		t.Errorf("import after Close succeeded")
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.6
+
+package gcimporter
+
+import (
+	"go/types"
+	"io/ioutil"
+)
+
+// NewLRUImporter returns a new Importer that keeps at most maxPackages
+// decoded packages, evicting the least recently used ones after each
+// import. The export data of evicted packages is dropped as well and
+// read again when they are imported again.
+//
+// To preserve type identity, a package is only evicted if none of the
+// packages kept imports it, and never if it was used by the most recent
+// import; an import of a package whose dependencies exceed maxPackages
+// keeps them all. A package imported again after its eviction is a new
+// package, distinct from the one returned before, whose types are not
+// identical to those of the old one: clients must not keep packages
+// returned by an LRU importer across imports of other packages if they
+// compare types between them.
+//
+func NewLRUImporter(maxPackages int) *Importer {
+	return &Importer{lru: &lruState{
+		max:   maxPackages,
+		used:  make(map[string]int),
+		blobs: make(map[string][]byte),
+	}}
+}
+
+// lruState is the state of an Importer created by NewLRUImporter.
+type lruState struct {
+	max   int
+	clock int               // number of imports so far
+	used  map[string]int    // package path -> clock of last use
+	blobs map[string][]byte // package path -> object file contents
+}
+
+// readFile returns the contents of the object file filename for the
+// package id, reading it only once while the package is resident.
+func (l *lruState) readFile(filename, id string) ([]byte, error) {
+	if data, ok := l.blobs[id]; ok {
+		return data, nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	l.blobs[id] = data
+	return data, nil
+}
+
+// touch records the use of pkg and the packages it depends on.
+func (l *lruState) touch(pkg *types.Package) {
+	if l.used[pkg.Path()] == l.clock {
+		return
+	}
+	l.used[pkg.Path()] = l.clock
+	for _, dep := range pkg.Imports() {
+		l.touch(dep)
+	}
+}
+
+// evict removes the least recently used packages from imp.packages
+// until at most imp.lru.max complete packages remain, or none can be
+// removed without breaking references from the packages kept.
+func (imp *Importer) evict() {
+	l := imp.lru
+	for {
+		resident := 0
+		imported := make(map[*types.Package]bool)
+		for _, pkg := range imp.packages {
+			if pkg.Complete() {
+				resident++
+				for _, dep := range pkg.Imports() {
+					imported[dep] = true
+				}
+			}
+		}
+		if resident <= l.max {
+			return
+		}
+
+		victim := ""
+		for path, pkg := range imp.packages {
+			if !pkg.Complete() || pkg == types.Unsafe || imported[pkg] || l.used[path] == l.clock {
+				continue
+			}
+			if victim == "" || l.used[path] < l.used[victim] || l.used[path] == l.used[victim] && path < victim {
+				victim = path
+			}
+		}
+		if victim == "" {
+			return
+		}
+		delete(imp.packages, victim)
+		delete(l.used, victim)
+		delete(l.blobs, victim)
+	}
+}
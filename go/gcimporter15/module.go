@@ -0,0 +1,85 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.6
+
+package gcimporter
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// NewModuleImporter returns an Importer for the packages of the module
+// rooted at rootDir and their dependencies.
+//
+// In module mode, the export data of dependencies lives in the build
+// cache rather than in a directory tree keyed by import path, so it
+// cannot be found by FindPkg. NewModuleImporter runs
+// "go list -export -deps" once in rootDir, building the packages as
+// necessary, and records the export data file of each package. Import
+// paths not listed are resolved by FindPkg as usual.
+func NewModuleImporter(rootDir string) (*Importer, error) {
+	files, err := listExports(rootDir, "./...")
+	if err != nil {
+		return nil, err
+	}
+	return &Importer{exportFiles: files}, nil
+}
+
+// listExports runs "go list -export -deps" for the given patterns in dir
+// and returns the export data file of each listed package, by import path.
+func listExports(dir string, patterns ...string) (map[string]string, error) {
+	args := append([]string{"list", "-export", "-deps", "-f", "{{if .Export}}{{.ImportPath}}\t{{.Export}}{{end}}"}, patterns...)
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GO111MODULE=on")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list -export in %s: %v: %s", dir, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	files := make(map[string]string)
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		if fields := strings.SplitN(s.Text(), "\t", 2); len(fields) == 2 {
+			files[fields[0]] = fields[1]
+		}
+	}
+	return files, s.Err()
+}
+
+// goList runs "go list -export -deps" for path in srcDir, records the
+// export data files listed for path and its dependencies in
+// imp.exportFiles, and returns the file for path, if any. Failures
+// are not reported; the package is then not found.
+func (imp *Importer) goList(path, srcDir string) string {
+	if imp.listed[path] {
+		return ""
+	}
+	if imp.listed == nil {
+		imp.listed = make(map[string]bool)
+	}
+	imp.listed[path] = true
+
+	files, err := listExports(srcDir, path)
+	if err != nil {
+		return ""
+	}
+	if imp.exportFiles == nil {
+		imp.exportFiles = make(map[string]string)
+	}
+	for p, filename := range files {
+		if _, ok := imp.exportFiles[p]; !ok {
+			imp.exportFiles[p] = filename
+		}
+	}
+	return imp.exportFiles[path]
+}
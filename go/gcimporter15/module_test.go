@@ -0,0 +1,131 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.6
+
+package gcimporter
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles writes the given files, keyed by slash-separated
+// name relative to dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestModuleImporter(t *testing.T) {
+	MustHaveGoBuild(t)
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"go.mod":     "module example.com/root\n\nrequire example.com/dep v0.0.0\n\nreplace example.com/dep => ./dep\n",
+		"root.go":    "package root\n\nimport \"example.com/dep\"\n\nconst C = dep.C\n",
+		"dep/go.mod": "module example.com/dep\n",
+		"dep/dep.go": "package dep\n\nconst C = 0\n",
+	})
+
+	const path = "example.com/dep"
+	if filename, _ := FindPkg(path, dir); filename != "" {
+		t.Fatalf("FindPkg(%q) = %s; want no file", path, filename)
+	}
+
+	imp, err := NewModuleImporter(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename, id := imp.findPkg(path, dir)
+	if filename == "" || id != path {
+		t.Fatalf("module importer: findPkg(%q) = %q, %q", path, filename, id)
+	}
+	if _, err := os.Stat(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	// The unified export format, written by cmd/compile since Go 1.20,
+	// cannot be decoded.
+	if format := exportFormat(t, filename); format == 'u' {
+		t.Skipf("unsupported export format %q in %s", format, filename)
+	}
+	pkg, err := imp.Import(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Path() != path || pkg.Scope().Lookup("C") == nil {
+		t.Errorf("module importer: imported %s without constant C", pkg.Path())
+	}
+}
+
+// exportFormat returns the first byte of the binary export data in
+// filename, which identifies its format, or 0 for textual export data.
+func exportFormat(t *testing.T, filename string) byte {
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	buf := bufio.NewReader(f)
+	hdr, err := FindExportData(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr != "$$B\n" {
+		return 0
+	}
+	format, err := buf.ReadByte()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return format
+}
+
+func TestGoList(t *testing.T) {
+	MustHaveGoBuild(t)
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"go.mod":         "module example.com/root\n\nrequire example.com/dep v0.0.0\n\nreplace example.com/dep => ./dep\n",
+		"root.go":        "package root\n\nimport \"example.com/dep\"\n\nconst C = dep.C\n",
+		"dep/go.mod":     "module example.com/dep\n",
+		"dep/dep.go":     "package dep\n\nimport \"example.com/dep/sub\"\n\nconst C = sub.C\n",
+		"dep/sub/sub.go": "package sub\n\nconst C = 0\n",
+	})
+
+	const path = "example.com/dep"
+	imp := &Importer{GoList: true}
+	filename, id := imp.resolve(path, dir)
+	if filename == "" || id != path {
+		t.Fatalf("resolve(%q) = %q, %q", path, filename, id)
+	}
+	if _, err := os.Stat(filename); err != nil {
+		t.Fatal(err)
+	}
+	// dependencies are recorded as well
+	if sub, _ := imp.findPkg(path+"/sub", dir); sub == "" {
+		t.Errorf("findPkg(%q) found no export data after listing %s", path+"/sub", path)
+	}
+
+	if filename, _ := imp.resolve("example.com/nonexistent", dir); filename != "" {
+		t.Errorf("resolve found %s for nonexistent package", filename)
+	}
+	if !imp.listed["example.com/nonexistent"] {
+		t.Errorf("nonexistent package was not listed")
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.6
+
+package gcimporter
+
+import (
+	"fmt"
+	"go/build"
+	"go/types"
+	"os"
+	"sort"
+)
+
+// A ChangeKind describes how an object changed between two versions
+// of a package.
+type ChangeKind int
+
+const (
+	ObjectAdded ChangeKind = iota
+	ObjectRemoved
+	ObjectChanged
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ObjectAdded:
+		return "added"
+	case ObjectRemoved:
+		return "removed"
+	case ObjectChanged:
+		return "changed"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// A Change describes a change of an exported package-level object, or
+// of an exported method of an exported named type, between two versions
+// of a package; see PlatformDiff.
+type Change struct {
+	Name     string // object name, or T.M for method M of type T
+	Kind     ChangeKind
+	Old, New string // declarations; empty if the object is absent
+}
+
+// PlatformDiff imports the package with the given import path and
+// srcDir (see FindPkg) from the export data compiled for goosA and
+// goarchA, as found in the pkg/$GOOS_$GOARCH directories, and from the
+// export data compiled for goosB and goarchB, and reports the changes of
+// its exported API from the first to the second platform, such as the
+// functions of package syscall available on one platform only. The
+// changes are sorted by name. Dependencies of the package are not
+// imported; local import paths do not depend on the platform.
+//
+func PlatformDiff(path, srcDir, goosA, goarchA, goosB, goarchB string) ([]Change, error) {
+	a, err := importPlatform(path, srcDir, goosA, goarchA)
+	if err != nil {
+		return nil, err
+	}
+	b, err := importPlatform(path, srcDir, goosB, goarchB)
+	if err != nil {
+		return nil, err
+	}
+	return apiDiff(a, b), nil
+}
+
+// importPlatform imports the package path compiled for goos and goarch.
+func importPlatform(path, srcDir, goos, goarch string) (*types.Package, error) {
+	ctxt := build.Default // copy
+	ctxt.GOOS = goos
+	ctxt.GOARCH = goarch
+	filename, id := findPkgIn(&ctxt, path, srcDir)
+	if filename == "" {
+		return nil, &notFoundError{id, "for " + goos + "/" + goarch}
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	imp := &Importer{GOARCH: goarch}
+	return imp.importFile(make(map[string]*types.Package), filename, id, f)
+}
+
+// apiDiff reports the changes of the exported API from package old to
+// package new.
+func apiDiff(old, new *types.Package) []Change {
+	oldAPI, newAPI := exportedDecls(old), exportedDecls(new)
+	var changes []Change
+	for name, o := range oldAPI {
+		switch n, ok := newAPI[name]; {
+		case !ok:
+			changes = append(changes, Change{name, ObjectRemoved, o, ""})
+		case n != o:
+			changes = append(changes, Change{name, ObjectChanged, o, n})
+		}
+	}
+	for name, n := range newAPI {
+		if _, ok := oldAPI[name]; !ok {
+			changes = append(changes, Change{name, ObjectAdded, "", n})
+		}
+	}
+	sort.Sort(byName(changes))
+	return changes
+}
+
+// exportedDecls returns the declarations of the exported objects and
+// methods of pkg, by name (see Change).
+func exportedDecls(pkg *types.Package) map[string]string {
+	qual := types.RelativeTo(pkg)
+	decls := make(map[string]string)
+	for name, obj := range exportedObjects(pkg) {
+		decls[name] = types.ObjectString(obj, qual)
+	}
+	return decls
+}
+
+// exportedObjects returns the exported objects and methods of pkg, by
+// name (see Change).
+func exportedObjects(pkg *types.Package) map[string]types.Object {
+	objs := make(map[string]types.Object)
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+		objs[name] = obj
+		if tn, ok := obj.(*types.TypeName); !ok || isAlias(tn) {
+			continue
+		}
+		if named, ok := obj.Type().(*types.Named); ok {
+			for i := 0; i < named.NumMethods(); i++ {
+				if m := named.Method(i); m.Exported() {
+					objs[name+"."+m.Name()] = m
+				}
+			}
+		}
+	}
+	return objs
+}
+
+type byName []Change
+
+func (a byName) Len() int           { return len(a) }
+func (a byName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byName) Less(i, j int) bool { return a[i].Name < a[j].Name }
@@ -0,0 +1,76 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.6
+
+package gcimporter
+
+import (
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlatformDiff(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	defer func(gopath string) { build.Default.GOPATH = gopath }(build.Default.GOPATH)
+	build.Default.GOPATH = dir
+
+	const common = `package p
+type File struct{ fd int }
+func (f *File) Close() error { return nil }
+func Open(name string) (*File, error) { return nil, nil }
+`
+	for _, test := range []struct {
+		goos, goarch, src string
+	}{
+		{"linux", "amd64", common + `
+const O_DIRECT = 0x4000
+func Splice(r, w *File) int { return 0 }
+func (f *File) Fd() int { return f.fd }
+`},
+		{"darwin", "arm64", common + `
+const O_DIRECT = "unsupported"
+func Kqueue() *File { return nil }
+func (f *File) Fd() uintptr { return 0 }
+func (f *File) Sync() error { return nil }
+`},
+	} {
+		pkgDir := filepath.Join(dir, "pkg", test.goos+"_"+test.goarch, "example.com")
+		if err := os.MkdirAll(pkgDir, 0777); err != nil {
+			t.Fatal(err)
+		}
+		data := objectFile(test.goarch, exportSource(t, "example.com/p", test.src))
+		if err := ioutil.WriteFile(filepath.Join(pkgDir, "p.a"), data, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	changes, err := PlatformDiff("example.com/p", "", "linux", "amd64", "darwin", "arm64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, fmt.Sprintf("%s %s: %q -> %q", c.Kind, c.Name, c.Old, c.New))
+	}
+	want := []string{
+		`changed File.Fd: "func (*File).Fd() int" -> "func (*File).Fd() uintptr"`,
+		`added File.Sync: "" -> "func (*File).Sync() error"`,
+		`added Kqueue: "" -> "func Kqueue() *File"`,
+		`changed O_DIRECT: "const O_DIRECT untyped int" -> "const O_DIRECT untyped string"`,
+		`removed Splice: "func Splice(r *File, w *File) int" -> ""`,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("PlatformDiff:\ngot  %q\nwant %q", got, want)
+	}
+
+	if _, err := PlatformDiff("example.com/p", "", "linux", "amd64", "windows", "386"); err == nil {
+		t.Errorf("PlatformDiff with missing platform succeeded")
+	}
+}
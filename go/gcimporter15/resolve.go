@@ -0,0 +1,92 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.6
+
+package gcimporter
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+)
+
+// Resolve returns the object denoted by selector, a package path
+// followed by a dotted sequence of names, such as "fmt.Println",
+// "net/http.Client", or "net/http.Client.Do". The first name denotes a
+// package-level object; each further name selects a field or method
+// of the type of the object before it. The package is imported as by
+// imp.Import if necessary.
+//
+// Since the last element of a package path may contain dots, as in
+// "gopkg.in/yaml.v2.Marshal", each possible split of selector into a
+// package path and names is tried; selectors that can be resolved in
+// more than one way are reported as ambiguous.
+func (imp *Importer) Resolve(selector string) (types.Object, error) {
+	start := strings.LastIndex(selector, "/") + 1
+	if !strings.Contains(selector[start:], ".") {
+		return nil, fmt.Errorf("cannot resolve %s: not a selector", selector)
+	}
+	var (
+		found     types.Object
+		foundIn   string
+		firstErr  error // first error selecting names in a package
+		importErr error // last error importing a candidate package path
+	)
+	for i := start; i < len(selector); i++ {
+		if selector[i] != '.' {
+			continue
+		}
+		path, names := selector[:i], strings.Split(selector[i+1:], ".")
+		pkg, err := imp.Import(path)
+		if err != nil {
+			importErr = err // possibly not a package path
+			continue
+		}
+		obj, err := selectNames(pkg, names)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("ambiguous selector %s: denotes %s in package %s and %s in package %s", selector, found, foundIn, obj, path)
+		}
+		found, foundIn = obj, path
+	}
+	switch {
+	case found != nil:
+		return found, nil
+	case firstErr != nil:
+		return nil, fmt.Errorf("cannot resolve %s: %v", selector, firstErr)
+	}
+	return nil, fmt.Errorf("cannot resolve %s: %v", selector, importErr)
+}
+
+// selectNames returns the object denoted by the names selecting a
+// package-level object of pkg and its fields or methods; see Resolve.
+func selectNames(pkg *types.Package, names []string) (types.Object, error) {
+	obj := pkg.Scope().Lookup(names[0])
+	if obj == nil {
+		return nil, fmt.Errorf("%s.%s not declared", pkg.Path(), names[0])
+	}
+	for _, name := range names[1:] {
+		switch obj.(type) {
+		case *types.TypeName, *types.Var:
+			// types and variables have members
+		default:
+			return nil, fmt.Errorf("%s has no members", obj.Name())
+		}
+		sel, index, _ := types.LookupFieldOrMethod(obj.Type(), true, obj.Pkg(), name)
+		if sel == nil {
+			if index != nil {
+				return nil, fmt.Errorf("ambiguous selector %s.%s", obj.Name(), name)
+			}
+			return nil, fmt.Errorf("%s.%s undefined (type %s has no field or method %s)", obj.Name(), name, obj.Type(), name)
+		}
+		obj = sel
+	}
+	return obj, nil
+}
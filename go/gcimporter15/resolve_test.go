@@ -0,0 +1,93 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.6
+
+package gcimporter
+
+import (
+	"go/build"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	defer func(gopath string) { build.Default.GOPATH = gopath }(build.Default.GOPATH)
+	build.Default.GOPATH = dir
+
+	fset := token.NewFileSet()
+	http := typecheck(t, fset, "example.com/net/http", `package http
+const MethodGet = "GET"
+type Header map[string][]string
+type Request struct{ Method string; Header Header }
+type Response struct{ StatusCode int }
+type Client struct{ Timeout int64; jar int }
+func (c *Client) Do(req *Request) (*Response, error) { return nil, nil }
+func Get(url string) (*Response, error) { return nil, nil }
+var DefaultClient = &Client{}
+`)
+	// the last element of the path contains a dot
+	yaml := typecheck(t, fset, "example.com/yaml.v2", "package yaml; func Marshal(interface{}) ([]byte, error) { return nil, nil }")
+	// example.com/x.Y.Z denotes a field of x.Y and a constant of x.Y
+	x := typecheck(t, fset, "example.com/x", "package x; type Y struct{ Z int }")
+	xy := typecheck(t, fset, "example.com/x.Y", "package y; const Z = 0")
+	pkgDir := filepath.Join(dir, "pkg", runtime.GOOS+"_"+runtime.GOARCH)
+	for _, pkg := range []*types.Package{http, yaml, x, xy} {
+		filename := filepath.Join(pkgDir, filepath.FromSlash(pkg.Path())+".a")
+		if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, objectFile(runtime.GOARCH, BExportData(fset, pkg)), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	imp := new(Importer)
+	for _, test := range []struct {
+		selector, want string
+	}{
+		{"example.com/net/http.Get", "func example.com/net/http.Get(url string) (*example.com/net/http.Response, error)"},
+		{"example.com/net/http.Client.Do", "func (*example.com/net/http.Client).Do(req *example.com/net/http.Request) (*example.com/net/http.Response, error)"},
+		{"example.com/net/http.Client.Timeout", "field Timeout int64"},
+		{"example.com/net/http.Request.Header", "field Header example.com/net/http.Header"},
+		{"example.com/net/http.DefaultClient.Do", "func (*example.com/net/http.Client).Do(req *example.com/net/http.Request) (*example.com/net/http.Response, error)"},
+		{"example.com/net/http.MethodGet", `const example.com/net/http.MethodGet untyped string`},
+		{"example.com/yaml.v2.Marshal", "func example.com/yaml.v2.Marshal(interface{}) ([]byte, error)"},
+	} {
+		obj, err := imp.Resolve(test.selector)
+		if err != nil {
+			t.Errorf("Resolve(%s): %v", test.selector, err)
+			continue
+		}
+		if got := types.ObjectString(obj, nil); got != test.want {
+			t.Errorf("Resolve(%s) = %s; want %s", test.selector, got, test.want)
+		}
+	}
+
+	for _, test := range []struct {
+		selector, err string
+	}{
+		{"example.com/net/http.Post", "example.com/net/http.Post not declared"},
+		{"example.com/net/http.Client.Close", "type example.com/net/http.Client has no field or method Close"},
+		{"example.com/net/http.Get.URL", "Get has no members"},
+		{"example.com/net/http", "not a selector"},
+		{"example.com/nonet/http.Get", "can't find import"},
+		{"example.com/x.Y.Z", "ambiguous selector"},
+	} {
+		_, err := imp.Resolve(test.selector)
+		if err == nil {
+			t.Errorf("Resolve(%s) succeeded", test.selector)
+		} else if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Resolve(%s): got error %q; want %q", test.selector, err, test.err)
+		}
+	}
+}
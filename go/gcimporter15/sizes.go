@@ -0,0 +1,46 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.9
+
+package gcimporter
+
+import (
+	"fmt"
+	"go/types"
+	"runtime"
+)
+
+// ImportWithStdSizes is like Import but also returns the sizes used by
+// the gc compiler for the host architecture, runtime.GOARCH, so that
+// the sizes, alignments, and field offsets of the imported types can
+// be computed without further setup. The sizes are only correct if the
+// package was compiled for the host architecture; use types.SizesFor
+// for other architectures.
+func ImportWithStdSizes(packages map[string]*types.Package, path, srcDir string) (*types.Package, types.Sizes, error) {
+	pkg, err := Import(packages, path, srcDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pkg, types.SizesFor("gc", runtime.GOARCH), nil
+}
+
+// ImportWithTargetSizes is like ImportWithStdSizes but for packages
+// compiled for the architecture goarch, which need not be the host
+// architecture: it returns the sizes used by the gc compiler for goarch,
+// and fails with an error wrapping ErrArchMismatch if the object file
+// records a different architecture (see Importer.GOARCH). It is an
+// error if the gc sizes of goarch are unknown.
+func ImportWithTargetSizes(packages map[string]*types.Package, path, srcDir, goarch string) (*types.Package, types.Sizes, error) {
+	sizes := types.SizesFor("gc", goarch)
+	if sizes == nil {
+		return nil, nil, fmt.Errorf("unknown architecture %q: no sizes for gc", goarch)
+	}
+	imp := &Importer{GOARCH: goarch}
+	pkg, err := imp.importPkg(packages, path, srcDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pkg, sizes, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.13
+
+package gcimporter
+
+import (
+	"errors"
+	"fmt"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+)
+
+func TestImportWithStdSizes(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	writeObject(t, dir, "p", exportSource(t, "p", "package p; type S struct { A byte; B int64; C int32; D *int }"))
+	pkg, sizes, err := ImportWithStdSizes(make(map[string]*types.Package), "./p", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var s struct {
+		A byte
+		B int64
+		C int32
+		D *int
+	}
+	S := pkg.Scope().Lookup("S").Type().Underlying().(*types.Struct)
+	if got, want := sizes.Sizeof(S), int64(unsafe.Sizeof(s)); got != want {
+		t.Errorf("Sizeof(S) = %d; want %d", got, want)
+	}
+	fields := []*types.Var{S.Field(0), S.Field(1), S.Field(2), S.Field(3)}
+	offsets := sizes.Offsetsof(fields)
+	for i, want := range []uintptr{unsafe.Offsetof(s.A), unsafe.Offsetof(s.B), unsafe.Offsetof(s.C), unsafe.Offsetof(s.D)} {
+		if offsets[i] != int64(want) {
+			t.Errorf("offset of %s = %d; want %d", fields[i].Name(), offsets[i], want)
+		}
+	}
+}
+
+func TestImportWithTargetSizes(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	data := exportSource(t, "p", "package p; type S struct { A byte; B int64; C int32; D *int }")
+	for _, goarch := range []string{"386", "amd64"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, goarch+".o"), objectFile(goarch, data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// int64 is 4-byte aligned and pointers are 4 bytes wide on 386
+	for _, test := range []struct {
+		goarch  string
+		offsets string
+		size    int64
+	}{
+		{"386", "[0 4 12 16]", 20},
+		{"amd64", "[0 8 16 24]", 32},
+	} {
+		pkg, sizes, err := ImportWithTargetSizes(make(map[string]*types.Package), "./"+test.goarch, dir, test.goarch)
+		if err != nil {
+			t.Errorf("%s: %v", test.goarch, err)
+			continue
+		}
+		S := pkg.Scope().Lookup("S").Type().Underlying().(*types.Struct)
+		offsets := sizes.Offsetsof([]*types.Var{S.Field(0), S.Field(1), S.Field(2), S.Field(3)})
+		if got := fmt.Sprint(offsets); got != test.offsets {
+			t.Errorf("%s: got offsets %s; want %s", test.goarch, got, test.offsets)
+		}
+		if got := sizes.Sizeof(S); got != test.size {
+			t.Errorf("%s: Sizeof(S) = %d; want %d", test.goarch, got, test.size)
+		}
+	}
+
+	if _, _, err := ImportWithTargetSizes(make(map[string]*types.Package), "./386", dir, "amd64"); !errors.Is(err, ErrArchMismatch) {
+		t.Errorf("got error %v; want ErrArchMismatch", err)
+	}
+	if _, _, err := ImportWithTargetSizes(make(map[string]*types.Package), "./386", dir, "vax"); err == nil {
+		t.Errorf("import for unknown architecture succeeded")
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.6
+
+package gcimporter
+
+import (
+	"go/types"
+	"os"
+	"time"
+)
+
+// NewCachingImporter returns a new Importer that records imported
+// packages in the packages map, as NewImporter does, together with the
+// modification time and size of the export data file each package was
+// imported from. Before each import, it checks these files again: the
+// packages whose files changed or were removed since, and the packages
+// depending on them, are removed from the packages map, so that they
+// are imported anew from the changed files. Packages whose files did
+// not change are returned again as before, preserving type identity.
+//
+// Packages imported using Lookup, Overlay, or SourceFallback are not
+// checked.
+//
+func NewCachingImporter(packages map[string]*types.Package) *Importer {
+	return &Importer{packages: packages, stamps: make(map[string]fileStamp)}
+}
+
+// A fileStamp records the state of the export data file a package was
+// imported from; see NewCachingImporter.
+type fileStamp struct {
+	id       string
+	packages map[string]*types.Package // map the package is recorded in
+	modTime  time.Time
+	size     int64
+}
+
+// newStamp returns the fileStamp of filename for the package id in
+// packages, or nil if filename cannot be examined.
+func newStamp(packages map[string]*types.Package, filename, id string) *fileStamp {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return nil
+	}
+	return &fileStamp{id, packages, fi.ModTime(), fi.Size()}
+}
+
+// invalidate removes the packages whose export data files changed since
+// they were imported, and the packages depending on them, from the
+// packages maps of imp.
+func (imp *Importer) invalidate() {
+	stale := make(map[*types.Package]bool)
+	for filename, s := range imp.stamps {
+		if fi, err := os.Stat(filename); err == nil && fi.ModTime().Equal(s.modTime) && fi.Size() == s.size {
+			continue
+		}
+		if pkg := s.packages[s.id]; pkg != nil {
+			stale[pkg] = true
+		}
+		delete(imp.stamps, filename)
+	}
+	if len(stale) == 0 {
+		return
+	}
+
+	maps := []map[string]*types.Package{imp.packages}
+	for _, packages := range imp.rootPackages {
+		maps = append(maps, packages)
+	}
+
+	// the types of packages depending on stale packages refer to
+	// the stale ones; they are stale, too
+	for changed := true; changed; {
+		changed = false
+		for _, packages := range maps {
+			for _, pkg := range packages {
+				if stale[pkg] {
+					continue
+				}
+				for _, dep := range pkg.Imports() {
+					if stale[dep] {
+						stale[pkg] = true
+						changed = true
+						break
+					}
+				}
+			}
+		}
+	}
+
+	for filename, s := range imp.stamps {
+		if stale[s.packages[s.id]] {
+			delete(imp.stamps, filename)
+		}
+	}
+	for _, packages := range maps {
+		for path, pkg := range packages {
+			if !stale[pkg] {
+				continue
+			}
+			delete(packages, path)
+			if imp.lru != nil {
+				delete(imp.lru.used, path)
+				delete(imp.lru.blobs, path)
+			}
+		}
+	}
+}
@@ -0,0 +1,22 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Input for TestAliasTestdata
+
+package alias
+
+type T struct{ X int }
+
+type (
+	A = T
+	P = *T
+	S = []A
+	I = int
+)
+
+var (
+	VA A
+	VP P
+	VS S
+)
@@ -0,0 +1,22 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Input for TestBinaryConstants
+
+package consts
+
+const (
+	Big     = 1 << 100
+	Bigger  = 1<<500 - 1
+	Odd     = 123456789012345678901234567890123456789
+	NegBig  = -Odd * Odd
+	Tiny    = 1.0 / (1 << 200)
+	Dyadic  = 1 + 1.0/(1<<300)
+	Third   = 1.0 / 3
+	Pi      = 3.14159265358979323846264338327950288419716939937510582097494459
+	Complex = Odd + Third*1i
+
+	F64 float64 = 0.1
+	I64 int64   = -1 << 63
+)
@@ -0,0 +1,25 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Input for TestBinaryStructTags
+
+package tags
+
+type T struct {
+	A int    `json:"a"`
+	B string `json:"b,omitempty" xml:"b"`
+	C bool   `tag:"with spaces and \"quotes\""`
+	D int    "interpreted \"string\"\ttag"
+	E []byte `dollar:"$$" pipe:"|"`
+	F int
+	G int `unicode:"ünïcödé"`
+	H struct {
+		X int `inner:"x"`
+	} `outer:""`
+	U `embedded:"u"`
+}
+
+type U struct {
+	V int `json:"a"` // same tag as T.A
+}
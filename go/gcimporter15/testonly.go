@@ -0,0 +1,70 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.6
+
+package gcimporter
+
+import (
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// TestOnlySymbols imports the package with the given import path and
+// srcDir (see FindPkg) and its test variant, and returns the exported
+// package-level objects and methods of exported named types that are
+// present in the test variant only, such as the helpers declared in an
+// export_test.go file of the package. The objects belong to the test
+// variant and are sorted by name, with methods named T.M as in Change.
+//
+// The export data of the test variant is expected next to the export
+// data of the package, with "_test" appended to the base name of the
+// file: for instance, x_test.a for x.a.
+//
+func TestOnlySymbols(path, srcDir string) ([]types.Object, error) {
+	filename, id := FindPkg(path, srcDir)
+	if filename == "" {
+		return nil, &notFoundError{path: id}
+	}
+	pkg, err := importVariant(filename, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ext := filepath.Ext(filename)
+	testname := strings.TrimSuffix(filename, ext) + "_test" + ext
+	testpkg, err := importVariant(testname, id)
+	if err != nil {
+		return nil, err
+	}
+
+	objs := exportedObjects(pkg)
+	var names []string
+	testobjs := exportedObjects(testpkg)
+	for name := range testobjs {
+		if _, ok := objs[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var only []types.Object
+	for _, name := range names {
+		only = append(only, testobjs[name])
+	}
+	return only, nil
+}
+
+// importVariant imports the package id from the export data in filename
+// into a fresh packages map.
+func importVariant(filename, id string) (*types.Package, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return new(Importer).importFile(make(map[string]*types.Package), filename, id, f)
+}
@@ -0,0 +1,58 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.6
+
+package gcimporter
+
+import (
+	"fmt"
+	"go/types"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTestOnlySymbols(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	const src = `package p
+type T struct{ n int }
+func (t *T) Len() int { return t.n }
+func New() *T { return new(T) }
+`
+	// the test variant includes the declarations of export_test.go
+	const testSrc = src + `
+var NewWithLen = func(n int) *T { return &T{n} }
+func (t *T) SetLen(n int) { t.n = n }
+const MaxLen = 1 << 10
+type Helper = T
+`
+	writeObject(t, dir, "p", exportSource(t, "p", src))
+	writeObject(t, dir, "p_test", exportSource(t, "p", testSrc))
+
+	objs, err := TestOnlySymbols("./p", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, obj := range objs {
+		got = append(got, types.ObjectString(obj, types.RelativeTo(obj.Pkg())))
+	}
+	want := []string{
+		"type Helper = T",
+		"const MaxLen untyped int",
+		"var NewWithLen func(n int) *T",
+		"func (*T).SetLen(n int)",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("TestOnlySymbols:\ngot  %q\nwant %q", got, want)
+	}
+
+	os.Remove(filepath.Join(dir, "p_test.o"))
+	if _, err := TestOnlySymbols("./p", dir); err == nil {
+		t.Errorf("TestOnlySymbols without test variant succeeded")
+	}
+}
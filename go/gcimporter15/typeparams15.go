@@ -0,0 +1,77 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.5,!go1.18
+
+package gcimporter
+
+import "go/types"
+
+// Before Go 1.18, go/types has no type parameters: packages written by
+// this version never contain them, and export data that does cannot be
+// imported. See typeparams18.go.
+
+// Placeholders for the predeclared types comparable and any
+// so that type indices agree with Go 1.18 and later.
+var predeclared18 = []types.Type{
+	placeholderType{"comparable"},
+	placeholderType{"any"},
+}
+
+type placeholderType struct{ name string }
+
+func (t placeholderType) Underlying() types.Type { return t }
+func (t placeholderType) String() string         { return t.name }
+
+func typeParams(t types.Type) []types.Type                   { return nil }
+func recvTypeParams(sig *types.Signature) []types.Type       { return nil }
+func isTypeParam(t types.Type) bool                          { return false }
+func typeParamObj(tp types.Type) *types.TypeName             { panic(noTypeParams) }
+func typeParamConstraint(tp types.Type) types.Type           { panic(noTypeParams) }
+func instanceOf(t *types.Named) (*types.Named, []types.Type) { return nil, nil }
+func isImplicit(t *types.Interface) bool                     { return false }
+
+func unionTerms(t types.Type) (terms []types.Type, tilde []bool, ok bool) {
+	return nil, nil, false
+}
+
+func embeddedTypes(t *types.Interface) []types.Type {
+	var list []types.Type
+	for i := 0; i < t.NumEmbeddeds(); i++ {
+		list = append(list, t.Embedded(i))
+	}
+	return list
+}
+
+// noTypeParams is reported, as invalid export data, for export data
+// with type parameters.
+const noTypeParams formatError = "type parameters require go1.18"
+
+func newTypeParam(obj *types.TypeName) types.Type                  { panic(noTypeParams) }
+func setConstraint(tp, constraint types.Type)                      { panic(noTypeParams) }
+func setTypeParams(t *types.Named, tparams []types.Type)           { panic(noTypeParams) }
+func instantiate(origin types.Type, targs []types.Type) types.Type { panic(noTypeParams) }
+func newUnion(terms []types.Type, tilde []bool) types.Type         { panic(noTypeParams) }
+
+func newSignature(recv *types.Var, rparams, tparams []types.Type, params, results *types.Tuple, variadic bool) *types.Signature {
+	if len(rparams) > 0 || len(tparams) > 0 {
+		panic(noTypeParams)
+	}
+	return types.NewSignature(recv, params, results, variadic)
+}
+
+func newInterface(methods []*types.Func, embeddeds []types.Type, implicit bool) *types.Interface {
+	if implicit {
+		panic(noTypeParams)
+	}
+	var named []*types.Named
+	for _, typ := range embeddeds {
+		t, ok := typ.(*types.Named)
+		if !ok {
+			panic(formatError("unexpected embedded type " + typ.String()))
+		}
+		named = append(named, t)
+	}
+	return types.NewInterface(methods, named)
+}
@@ -0,0 +1,156 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.18
+
+package gcimporter
+
+import (
+	"fmt"
+	"go/types"
+)
+
+// This file adapts the type parameter API of go/types (Go 1.18 and later)
+// to the version-independent encoding in bexport.go and bimport.go, which
+// refer to type parameters as types.Type.
+
+// predeclared types added in version 2 of the format; see predeclaredTypes
+var predeclared18 = []types.Type{
+	types.Universe.Lookup("comparable").Type(),
+	types.Universe.Lookup("any").Type(),
+}
+
+// typeParams returns the type parameters of a generic named type
+// or function signature t.
+func typeParams(t types.Type) []types.Type {
+	var list *types.TypeParamList
+	switch t := t.(type) {
+	case *types.Named:
+		list = t.TypeParams()
+	case *types.Signature:
+		list = t.TypeParams()
+	}
+	return typeParamSlice(list)
+}
+
+// recvTypeParams returns the receiver type parameters of the method
+// signature sig.
+func recvTypeParams(sig *types.Signature) []types.Type {
+	return typeParamSlice(sig.RecvTypeParams())
+}
+
+func typeParamSlice(list *types.TypeParamList) []types.Type {
+	if list.Len() == 0 {
+		return nil
+	}
+	tparams := make([]types.Type, list.Len())
+	for i := range tparams {
+		tparams[i] = list.At(i)
+	}
+	return tparams
+}
+
+func isTypeParam(t types.Type) bool {
+	_, ok := t.(*types.TypeParam)
+	return ok
+}
+
+func typeParamObj(tp types.Type) *types.TypeName {
+	return tp.(*types.TypeParam).Obj()
+}
+
+func typeParamConstraint(tp types.Type) types.Type {
+	return tp.(*types.TypeParam).Constraint()
+}
+
+// instanceOf returns the generic type and type arguments of t
+// if t is an instantiated type.
+func instanceOf(t *types.Named) (origin *types.Named, targs []types.Type) {
+	list := t.TypeArgs()
+	if list.Len() == 0 {
+		return nil, nil
+	}
+	targs = make([]types.Type, list.Len())
+	for i := range targs {
+		targs[i] = list.At(i)
+	}
+	return t.Origin(), targs
+}
+
+// unionTerms returns the terms of t if t is a union.
+func unionTerms(t types.Type) (terms []types.Type, tilde []bool, ok bool) {
+	u, ok := t.(*types.Union)
+	if !ok {
+		return nil, nil, false
+	}
+	for i := 0; i < u.Len(); i++ {
+		term := u.Term(i)
+		terms = append(terms, term.Type())
+		tilde = append(tilde, term.Tilde())
+	}
+	return terms, tilde, true
+}
+
+func embeddedTypes(t *types.Interface) []types.Type {
+	var list []types.Type
+	for i := 0; i < t.NumEmbeddeds(); i++ {
+		list = append(list, t.EmbeddedType(i))
+	}
+	return list
+}
+
+func isImplicit(t *types.Interface) bool {
+	return t.IsImplicit()
+}
+
+func newTypeParam(obj *types.TypeName) types.Type {
+	return types.NewTypeParam(obj, nil)
+}
+
+func setConstraint(tp, constraint types.Type) {
+	tp.(*types.TypeParam).SetConstraint(constraint)
+}
+
+func setTypeParams(t *types.Named, tparams []types.Type) {
+	t.SetTypeParams(asTypeParams(tparams))
+}
+
+func newSignature(recv *types.Var, rparams, tparams []types.Type, params, results *types.Tuple, variadic bool) *types.Signature {
+	return types.NewSignatureType(recv, asTypeParams(rparams), asTypeParams(tparams), params, results, variadic)
+}
+
+func asTypeParams(list []types.Type) []*types.TypeParam {
+	if len(list) == 0 {
+		return nil
+	}
+	tparams := make([]*types.TypeParam, len(list))
+	for i, tp := range list {
+		tparams[i] = tp.(*types.TypeParam)
+	}
+	return tparams
+}
+
+func instantiate(origin types.Type, targs []types.Type) types.Type {
+	t, err := types.Instantiate(nil, origin, targs, false)
+	if err != nil {
+		panic(fmt.Sprintf("cannot instantiate %s: %v", origin, err))
+	}
+	return t
+}
+
+func newUnion(terms []types.Type, tilde []bool) types.Type {
+	list := make([]*types.Term, len(terms))
+	for i, t := range terms {
+		list[i] = types.NewTerm(tilde[i], t)
+	}
+	return types.NewUnion(list)
+}
+
+func newInterface(methods []*types.Func, embeddeds []types.Type, implicit bool) *types.Interface {
+	t := types.NewInterfaceType(methods, embeddeds)
+	if implicit {
+		t.MarkImplicit()
+	}
+	return t
+}
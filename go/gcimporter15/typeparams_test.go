@@ -0,0 +1,329 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.18
+
+package gcimporter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"testing"
+)
+
+func TestGenericMethods(t *testing.T) {
+	const src = `package p
+type List[T interface{ comparable }] struct {
+	head *node[T]
+}
+type node[T comparable] struct {
+	next *node[T]
+	val  T
+}
+func (l *List[T]) Push(v T) {}
+func (l List[E]) Find(v E) (E, bool) { var zero E; return zero, false }
+`
+	pkg := bimport(t, exportSource(t, "p", src), "p")
+	list := pkg.Scope().Lookup("List").Type().(*types.Named)
+	if got := fmt.Sprint(list.TypeParams().At(0), " ", list.TypeParams().At(0).Constraint()); got != "T interface{comparable}" {
+		t.Errorf("type parameter of List: got %s", got)
+	}
+
+	for _, test := range []struct {
+		name string
+		want string
+	}{
+		{"Push", "func (*p.List[T]).Push(v T)"},
+		{"Find", "func (p.List[E]).Find(v E) (E, bool)"},
+	} {
+		obj, _, _ := types.LookupFieldOrMethod(list, true, pkg, test.name)
+		m, ok := obj.(*types.Func)
+		if !ok {
+			t.Errorf("method %s not found", test.name)
+			continue
+		}
+		if got := m.String(); got != test.want {
+			t.Errorf("got %s; want %s", got, test.want)
+		}
+
+		// the receiver type is instantiated with the receiver type parameters
+		sig := m.Type().(*types.Signature)
+		rparams := sig.RecvTypeParams()
+		if rparams.Len() != 1 {
+			t.Errorf("%s: got %d receiver type parameters; want 1", test.name, rparams.Len())
+			continue
+		}
+		recv := sig.Recv().Type()
+		if ptr, ok := recv.(*types.Pointer); ok {
+			recv = ptr.Elem()
+		}
+		targs := recv.(*types.Named).TypeArgs()
+		if targs.Len() != 1 || targs.At(0) != rparams.At(0) {
+			t.Errorf("%s: receiver %s not instantiated with %s", test.name, recv, rparams.At(0))
+		}
+		if sig.Params().At(0).Type() != rparams.At(0) {
+			t.Errorf("%s: parameter type %s is not the receiver type parameter", test.name, sig.Params().At(0).Type())
+		}
+	}
+
+	// instances are usable
+	inst, err := types.Instantiate(nil, list, []types.Type{types.Typ[types.String]}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, _, _ := types.LookupFieldOrMethod(inst, true, pkg, "Push")
+	if got, want := obj.Type().String(), "func(v string)"; got != want {
+		t.Errorf("Push of %s: got %s; want %s", inst, got, want)
+	}
+}
+
+func TestIterSeq(t *testing.T) {
+	// a stand-in for the standard library package iter
+	const srcIter = `package iter
+type Seq[V any] func(yield func(V) bool)
+`
+	const srcP = `package p
+import "iter"
+func All() iter.Seq[int] { return func(yield func(int) bool) {} }
+`
+	fset := token.NewFileSet()
+	iter := typecheck(t, fset, "iter", srcIter)
+	p := typecheck(t, fset, "p", srcP, iter)
+
+	files := map[string][]byte{
+		"iter": objectFile(runtime.GOARCH, BExportData(fset, iter)),
+		"p":    objectFile(runtime.GOARCH, BExportData(fset, p)),
+	}
+	imp := &Importer{Lookup: func(path string) (io.ReadCloser, error) {
+		data, ok := files[path]
+		if !ok {
+			return nil, &notFoundError{path: path}
+		}
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}}
+	pkg, err := imp.Import("p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deps := pkg.Imports(); len(deps) != 1 || deps[0].Path() != "iter" || !deps[0].Complete() {
+		t.Fatalf("imports of p: got %v; want complete package iter", deps)
+	}
+
+	res := pkg.Scope().Lookup("All").Type().(*types.Signature).Results().At(0).Type()
+	seq, ok := res.(*types.Named)
+	if !ok || seq.Obj() != pkg.Imports()[0].Scope().Lookup("Seq") {
+		t.Fatalf("result of All is %s; want instance of iter.Seq", res)
+	}
+	if targs := seq.TypeArgs(); targs.Len() != 1 || targs.At(0) != types.Typ[types.Int] {
+		t.Errorf("type arguments of %s: got %v; want [int]", seq, targs)
+	}
+	if got, want := seq.Underlying().String(), "func(yield func(int) bool)"; got != want {
+		t.Errorf("underlying type of %s: got %s; want %s", seq, got, want)
+	}
+}
+
+func TestTypeParamConstraints(t *testing.T) {
+	const src = `package p
+func F[T any](T) {}
+func G[K comparable, V any](map[K]V) {}
+`
+	pkg := bimport(t, exportSource(t, "p", src), "p")
+	any := types.Universe.Lookup("any").Type()
+	comparable := types.Universe.Lookup("comparable").Type()
+	for _, test := range []struct {
+		fun  string
+		want []types.Type
+	}{
+		{"F", []types.Type{any}},
+		{"G", []types.Type{comparable, any}},
+	} {
+		tparams := pkg.Scope().Lookup(test.fun).Type().(*types.Signature).TypeParams()
+		for i, want := range test.want {
+			tp := tparams.At(i)
+			if c := tp.Constraint(); c == nil || !types.Identical(c, want) {
+				t.Errorf("%s: constraint of %s is %v; want %s", test.fun, tp, c, want)
+			}
+		}
+	}
+}
+
+func TestIsGeneric(t *testing.T) {
+	const src = `package p
+type List[T any] struct {
+	next *List[T]
+	val  T
+}
+type Pair[K comparable, V any] struct{ k K; v V }
+type Plain struct{ val int }
+type Defined List[int]
+var V List[string]
+`
+	pkg := bimport(t, exportSource(t, "p", src), "p")
+	for _, test := range []struct {
+		name string
+		want bool
+	}{
+		{"List", true},
+		{"Pair", true},
+		{"Plain", false},
+		{"Defined", false},
+		{"V", true}, // instantiated type
+	} {
+		named := pkg.Scope().Lookup(test.name).Type().(*types.Named)
+		if got := IsGeneric(named); got != test.want {
+			t.Errorf("IsGeneric(%s) = %t; want %t", named, got, test.want)
+		}
+	}
+}
+
+func TestTypeParamResults(t *testing.T) {
+	const src = `package p
+func Zero[T any]() T { var z T; return z }
+func Lookup[K comparable, V any](m map[K]V, k K) (v V, keys []K) { return }
+`
+	pkg := bimport(t, exportSource(t, "p", src), "p")
+
+	// the parameter and result types refer to the type parameters
+	// of the signature
+	zero := pkg.Scope().Lookup("Zero").Type().(*types.Signature)
+	if tp, res := zero.TypeParams().At(0), zero.Results().At(0).Type(); res != tp {
+		t.Errorf("Zero: result type %s (%p) is not type parameter %s (%p)", res, res, tp, tp)
+	}
+	lookup := pkg.Scope().Lookup("Lookup").Type().(*types.Signature)
+	K, V := lookup.TypeParams().At(0), lookup.TypeParams().At(1)
+	m := lookup.Params().At(0).Type().(*types.Map)
+	for _, test := range []struct {
+		what string
+		got  types.Type
+		want *types.TypeParam
+	}{
+		{"map key", m.Key(), K},
+		{"map element", m.Elem(), V},
+		{"parameter k", lookup.Params().At(1).Type(), K},
+		{"result v", lookup.Results().At(0).Type(), V},
+		{"result keys", lookup.Results().At(1).Type().(*types.Slice).Elem(), K},
+	} {
+		if test.got != test.want {
+			t.Errorf("Lookup: type %s of %s is not type parameter %s", test.got, test.what, test.want)
+		}
+	}
+
+	// instantiation substitutes the type parameters
+	inst, err := types.Instantiate(nil, zero, []types.Type{types.Typ[types.String]}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := inst.(*types.Signature).Results().At(0).Type(); res != types.Typ[types.String] {
+		t.Errorf("Zero[string] returns %s; want string", res)
+	}
+	inst, err = types.Instantiate(nil, lookup, []types.Type{types.Typ[types.Int], types.Typ[types.Bool]}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := inst.String(), "func(m map[int]bool, k int) (v bool, keys []int)"; got != want {
+		t.Errorf("Lookup[int, bool] is %s; want %s", got, want)
+	}
+}
+
+func TestInstantiatedFields(t *testing.T) {
+	const srcList = `package list
+type List[T any] struct {
+	head *List[T]
+	val  T
+	ints *List[int] // instantiated before List is complete
+}
+`
+	const src = `package p
+import "list"
+type S struct {
+	L  list.List[int]
+	M  map[string]list.List[int]
+	Sl []*list.List[string]
+}
+func F(list.List[int]) []list.List[bool] { return nil }
+`
+	fset := token.NewFileSet()
+	list := typecheck(t, fset, "list", srcList)
+	pkg := bimport(t, BExportData(fset, typecheck(t, fset, "p", src, list)), "p")
+
+	// check checks that typ is the instance List[targ] of the
+	// imported generic type List.
+	check := func(what string, typ types.Type, targ types.Type) {
+		inst, ok := typ.(*types.Named)
+		if !ok || inst.TypeArgs().Len() != 1 {
+			t.Errorf("%s: got %s; want instantiated type", what, typ)
+			return
+		}
+		if obj := inst.Origin().Obj(); obj.Pkg().Path() != "list" || obj.Name() != "List" {
+			t.Errorf("%s: got instance of %s; want instance of list.List", what, obj)
+		}
+		if got := inst.TypeArgs().At(0); !types.Identical(got, targ) {
+			t.Errorf("%s: got type argument %s; want %s", what, got, targ)
+		}
+		// the instance is expanded using the type argument
+		if f := inst.Underlying().(*types.Struct).Field(1); !types.Identical(f.Type(), targ) {
+			t.Errorf("%s: field %s has type %s; want %s", what, f.Name(), f.Type(), targ)
+		}
+	}
+
+	s := pkg.Scope().Lookup("S").Type().Underlying().(*types.Struct)
+	check("field L", s.Field(0).Type(), types.Typ[types.Int])
+	check("map element", s.Field(1).Type().(*types.Map).Elem(), types.Typ[types.Int])
+	check("slice element", s.Field(2).Type().(*types.Slice).Elem().(*types.Pointer).Elem(), types.Typ[types.String])
+
+	sig := pkg.Scope().Lookup("F").Type().(*types.Signature)
+	check("parameter", sig.Params().At(0).Type(), types.Typ[types.Int])
+	check("result element", sig.Results().At(0).Type().(*types.Slice).Elem(), types.Typ[types.Bool])
+
+	// instances with identical type arguments are identical
+	generic := s.Field(0).Type().(*types.Named).Origin()
+	ints := generic.Underlying().(*types.Struct).Field(2).Type().(*types.Pointer).Elem()
+	check("field ints", ints, types.Typ[types.Int])
+	if !types.Identical(ints, s.Field(0).Type()) {
+		t.Errorf("%s and %s are not identical", ints, s.Field(0).Type())
+	}
+}
+
+func TestInvalidTypeIndex(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		refs []int64
+	}{
+		{"type", []int64{1 << 20}},
+		{"type parameter", []int64{typeParamTag, 3}},
+		{"negative type parameter", []int64{typeParamTag, -1}},
+	} {
+		var data []byte
+		for _, x := range test.refs {
+			var buf [binary.MaxVarintLen64]byte
+			data = append(data, buf[:binary.PutVarint(buf[:], x)]...)
+		}
+		p := importer{conf: new(Importer), data: data, path: "p"}
+		func() {
+			defer func() {
+				if _, ok := recover().(formatError); !ok {
+					t.Errorf("%s: no format error", test.name)
+				}
+			}()
+			p.typ(nil)
+		}()
+	}
+}
+
+func TestInvalidTypeParamCount(t *testing.T) {
+	var buf [binary.MaxVarintLen64]byte
+	p := importer{conf: new(Importer), data: buf[:binary.PutVarint(buf[:], 1<<40)], path: "p", version: 2}
+	defer func() {
+		if _, ok := recover().(formatError); !ok {
+			t.Errorf("no format error")
+		}
+	}()
+	p.typeParamList(nil, nil)
+}
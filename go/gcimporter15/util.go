@@ -0,0 +1,536 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.5
+
+// This file implements convenience functions for inspecting
+// imported packages.
+
+package gcimporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// Owner returns the package defining the type t, and whether t is a
+// predeclared type such as int, error, or comparable, which has no
+// package. For types other than basic and named types, Owner returns
+// nil, false.
+func Owner(t types.Type) (pkg *types.Package, predeclared bool) {
+	switch t := t.(type) {
+	case *types.Basic:
+		return nil, true
+	case interface {
+		Obj() *types.TypeName
+	}: // *types.Named and, where available, alias types
+		pkg = t.Obj().Pkg()
+		return pkg, pkg == nil
+	}
+	return nil, false
+}
+
+// ObjectID returns a string identifying obj among the objects of
+// all packages: it is the path of the declaring package followed by
+// the name of the declaring type for methods and struct fields and by
+// the name of obj, separated by dots, as in "fmt.Println" and
+// "net/http.Client.Do". Promoted methods and fields are attributed
+// to the type declaring them. Unlike types.ObjectString, which is
+// meant for display, ObjectID does not describe the type of obj.
+//
+// Fields of struct types and methods of interface types that are not
+// the underlying type of a package-level named type have no declaring
+// type name; their identifiers use "?" instead and are not unique.
+// Objects of the Universe scope are identified by their name.
+func ObjectID(obj types.Object) string {
+	if obj.Pkg() == nil {
+		return obj.Name()
+	}
+
+	member := false
+	pkg := obj.Pkg()
+	owner := ""
+	switch obj := obj.(type) {
+	case *types.Func:
+		if recv := obj.Type().(*types.Signature).Recv(); recv != nil {
+			member = true
+			if named, ok := deref(recv.Type()).(*types.Named); ok && !types.IsInterface(named) {
+				pkg = named.Obj().Pkg()
+				owner = named.Obj().Name()
+			}
+		}
+	case *types.Var:
+		member = obj.IsField()
+	}
+	if member && owner == "" {
+		owner = declaringType(obj)
+	}
+
+	id := pkg.Path() + "."
+	if member {
+		id += owner + "."
+	}
+	return id + obj.Name()
+}
+
+// declaringType returns the name of the package-level named type whose
+// underlying struct or interface type declares the field or method obj,
+// or "?".
+func declaringType(obj types.Object) string {
+	scope := obj.Pkg().Scope()
+	for _, name := range scope.Names() {
+		tname, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		switch u := tname.Type().Underlying().(type) {
+		case *types.Struct:
+			for i := 0; i < u.NumFields(); i++ {
+				if u.Field(i) == obj {
+					return name
+				}
+			}
+		case *types.Interface:
+			for i := 0; i < u.NumExplicitMethods(); i++ {
+				if u.ExplicitMethod(i) == obj {
+					return name
+				}
+			}
+		}
+	}
+	return "?"
+}
+
+// ShadowsPredeclared reports whether the package-level object obj has
+// the name of a predeclared identifier such as error or len, which it
+// shadows in its package. Only exported objects and the unexported
+// types they refer to are recorded in export data, so for imported
+// packages only the latter can shadow predeclared identifiers.
+func ShadowsPredeclared(obj types.Object) bool {
+	return obj.Pkg() != nil && obj.Parent() == obj.Pkg().Scope() && types.Universe.Lookup(obj.Name()) != nil
+}
+
+// IsAlias reports whether tn is an alias type name, declared as in
+// "type A = B", rather than the name of a defined type. Importers of
+// this package record alias type names for aliases in binary export
+// data of version 2 and later; earlier versions and textual export data
+// do not record aliases. IsAlias is equivalent to tn.IsAlias, which is
+// not available before Go 1.9.
+func IsAlias(tn *types.TypeName) bool {
+	return isAlias(tn)
+}
+
+// IsGeneric reports whether named has type parameters, as the generic
+// type List declared by "type List[T any] struct{ ... }" does. Note
+// that instantiated types, such as List[int], have the type parameters
+// of their generic type, too. Importers of this package record type
+// parameters for generic types in binary export data of version 2 and
+// later; before Go 1.18, there are no generic types.
+func IsGeneric(named *types.Named) bool {
+	return len(typeParams(named)) > 0
+}
+
+// IsUntyped reports whether c is an untyped constant, such as math.Pi,
+// rather than a constant of a named or basic type, such as time.Second
+// or a constant declared with an explicit type like int.
+func IsUntyped(c *types.Const) bool {
+	basic, ok := c.Type().(*types.Basic)
+	return ok && basic.Info()&types.IsUntyped != 0
+}
+
+// Implementers returns the non-interface named types declared at package
+// level in pkgs that implement iface, either directly or through their
+// pointer type, in package and scope order. Generic types are ignored.
+func Implementers(iface *types.Interface, pkgs ...*types.Package) []*types.Named {
+	var list []*types.Named
+	for _, pkg := range pkgs {
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			tname, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok {
+				continue
+			}
+			named, ok := tname.Type().(*types.Named)
+			if !ok || named.Obj() != tname || types.IsInterface(named) || len(typeParams(named)) > 0 {
+				continue // alias, interface, or generic type
+			}
+			if types.Implements(named, iface) || types.Implements(types.NewPointer(named), iface) {
+				list = append(list, named)
+			}
+		}
+	}
+	return list
+}
+
+// UnderlyingChain returns the types leading from t to its underlying
+// type: t, the types denoted by the aliases among them, and finally
+// the underlying type, which ends the list. Note that go/types does not
+// record the type a defined type was declared with: given "type B C",
+// the underlying type of B directly follows B.
+func UnderlyingChain(t types.Type) []types.Type {
+	chain := []types.Type{t}
+	for {
+		if rhs, ok := aliasRhs(t); ok {
+			t = rhs
+			chain = append(chain, t)
+			continue
+		}
+		if u := t.Underlying(); u != t {
+			chain = append(chain, u)
+		}
+		return chain
+	}
+}
+
+// FullyQualified is a types.Qualifier qualifying objects with the
+// full import path of their package, as in "net/url.URL".
+func FullyQualified(pkg *types.Package) string {
+	return pkg.Path()
+}
+
+// PackageNameQualifier returns a types.Qualifier for use within pkg:
+// objects of pkg are not qualified, and others are qualified with the
+// name of their package, as in "url.URL". Packages whose names are
+// ambiguous among the imports of pkg or unknown, as for placeholder
+// packages created for textual export data, are identified by their
+// import path instead.
+func PackageNameQualifier(pkg *types.Package) types.Qualifier {
+	count := make(map[string]int)
+	for _, imp := range pkg.Imports() {
+		count[imp.Name()]++
+	}
+	return func(other *types.Package) string {
+		if other == pkg {
+			return ""
+		}
+		name := other.Name()
+		if name == "" || count[name] > 1 || name == pkg.Name() {
+			return other.Path()
+		}
+		return name
+	}
+}
+
+// LoadOrder returns pkg and the packages it imports, directly or
+// indirectly, in dependency order: each package follows the packages
+// it imports, and pkg is last. Packages imported by the same package
+// are visited in the order of Imports. Note that the imports recorded
+// by importers may include packages that are referenced only through
+// the types of exported objects. LoadOrder reports an error if the
+// import graph contains a cycle, which can only happen for invalid
+// export data.
+func LoadOrder(pkg *types.Package) ([]*types.Package, error) {
+	var order []*types.Package
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[*types.Package]int)
+	var stack []string // import paths of packages being visited
+	var visit func(pkg *types.Package) error
+	visit = func(pkg *types.Package) error {
+		switch state[pkg] {
+		case visiting:
+			for i, path := range stack {
+				if path == pkg.Path() {
+					return fmt.Errorf("import cycle: %s", strings.Join(append(stack[i:], path), " -> "))
+				}
+			}
+		case done:
+			return nil
+		}
+		state[pkg] = visiting
+		stack = append(stack, pkg.Path())
+		for _, dep := range pkg.Imports() {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[pkg] = done
+		order = append(order, pkg)
+		return nil
+	}
+	if err := visit(pkg); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// CommonIfaces reports which of some widely used interfaces of the
+// standard library a type implements.
+type CommonIfaces struct {
+	Stringer        bool // fmt.Stringer
+	Error           bool // error
+	JSONMarshaler   bool // encoding/json.Marshaler
+	JSONUnmarshaler bool // encoding/json.Unmarshaler
+	TextMarshaler   bool // encoding.TextMarshaler
+	TextUnmarshaler bool // encoding.TextUnmarshaler
+}
+
+// ImplementsCommon reports which of the interfaces listed in CommonIfaces
+// t implements. Methods with pointer receivers are only considered if t
+// is a pointer type; for a named type T, pass *T to include them.
+// The interfaces are constructed rather than imported, which makes no
+// difference since all their methods are exported.
+func ImplementsCommon(t types.Type) CommonIfaces {
+	return CommonIfaces{
+		Stringer:        types.Implements(t, stringerIface),
+		Error:           types.Implements(t, errorType.Underlying().(*types.Interface)),
+		JSONMarshaler:   types.Implements(t, jsonMarshalerIface),
+		JSONUnmarshaler: types.Implements(t, jsonUnmarshalerIface),
+		TextMarshaler:   types.Implements(t, textMarshalerIface),
+		TextUnmarshaler: types.Implements(t, textUnmarshalerIface),
+	}
+}
+
+var (
+	errorType = types.Universe.Lookup("error").Type()
+	byteSlice = types.NewSlice(types.Typ[types.Byte])
+
+	stringerIface        = methodIface("String", nil, []types.Type{types.Typ[types.String]})
+	jsonMarshalerIface   = methodIface("MarshalJSON", nil, []types.Type{byteSlice, errorType})
+	jsonUnmarshalerIface = methodIface("UnmarshalJSON", []types.Type{byteSlice}, []types.Type{errorType})
+	textMarshalerIface   = methodIface("MarshalText", nil, []types.Type{byteSlice, errorType})
+	textUnmarshalerIface = methodIface("UnmarshalText", []types.Type{byteSlice}, []types.Type{errorType})
+)
+
+// methodIface returns the interface with the single method name
+// of the given parameter and result types.
+func methodIface(name string, params, results []types.Type) *types.Interface {
+	tuple := func(list []types.Type) *types.Tuple {
+		vars := make([]*types.Var, len(list))
+		for i, typ := range list {
+			vars[i] = types.NewVar(token.NoPos, nil, "", typ)
+		}
+		return types.NewTuple(vars...)
+	}
+	sig := types.NewSignature(nil, tuple(params), tuple(results), false)
+	return types.NewInterface([]*types.Func{types.NewFunc(token.NoPos, nil, name, sig)}, nil).Complete()
+}
+
+// A jsonVar describes a receiver, parameter, or result in the JSON
+// encoding of a signature.
+type jsonVar struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type jsonSignature struct {
+	Recv     *jsonVar  `json:"recv,omitempty"`
+	Params   []jsonVar `json:"params"`
+	Results  []jsonVar `json:"results"`
+	Variadic bool      `json:"variadic"`
+}
+
+// SignatureJSON returns a JSON encoding of sig of the form
+//
+//	{"recv":{"name":"r","type":"*p.T"},"params":[...],"results":[...],"variadic":false}
+//
+// Receiver, parameters, and results are described by their name, which
+// is empty for unnamed ones, and their type, written with fully qualified
+// package paths. The type of the last parameter of a variadic function
+// is a slice type. The receiver is omitted for functions.
+func SignatureJSON(sig *types.Signature) ([]byte, error) {
+	v := func(obj *types.Var) jsonVar {
+		return jsonVar{obj.Name(), types.TypeString(obj.Type(), nil)}
+	}
+	list := func(t *types.Tuple) []jsonVar {
+		vars := make([]jsonVar, t.Len())
+		for i := range vars {
+			vars[i] = v(t.At(i))
+		}
+		return vars
+	}
+	s := jsonSignature{
+		Params:   list(sig.Params()),
+		Results:  list(sig.Results()),
+		Variadic: sig.Variadic(),
+	}
+	if recv := sig.Recv(); recv != nil {
+		r := v(recv)
+		s.Recv = &r
+	}
+	return json.Marshal(s)
+}
+
+// A MethodChangeKind describes how a method changed between two
+// versions of a type.
+type MethodChangeKind int
+
+const (
+	MethodAdded MethodChangeKind = iota
+	MethodRemoved
+	MethodChanged // the signature changed
+)
+
+func (k MethodChangeKind) String() string {
+	switch k {
+	case MethodAdded:
+		return "added"
+	case MethodRemoved:
+		return "removed"
+	case MethodChanged:
+		return "changed"
+	}
+	return fmt.Sprintf("MethodChangeKind(%d)", int(k))
+}
+
+// A MethodChange describes a change of a method between two versions
+// of a type; see MethodSetDiff.
+type MethodChange struct {
+	Name     string
+	Kind     MethodChangeKind
+	Pointer  bool   // the change applies to the method set of *T only
+	Old, New string // signatures; empty if the method is not in the method set
+}
+
+// MethodSetDiff reports the changes of the method sets of a named type T
+// and of *T between an old and a new version, including promoted
+// methods. Changes of the method set of T, which also apply to *T, are
+// reported with Pointer false. Changes particular to the method set of
+// *T, as for a method with a value receiver that gained a pointer
+// receiver, are reported with Pointer true. Signatures are compared as
+// strings with fully qualified package paths, so that old and new may
+// come from separate imports. The changes are sorted by method name.
+func MethodSetDiff(old, new *types.Named) []MethodChange {
+	sigs := func(T types.Type) map[string]string {
+		mset := types.NewMethodSet(T)
+		m := make(map[string]string, mset.Len())
+		for i := 0; i < mset.Len(); i++ {
+			obj := mset.At(i).Obj()
+			m[obj.Name()] = types.TypeString(obj.Type(), FullyQualified)
+		}
+		return m
+	}
+	oldVal, newVal := sigs(old), sigs(new)
+	oldPtr, newPtr := sigs(types.NewPointer(old)), sigs(types.NewPointer(new))
+
+	var names []string
+	for name := range oldPtr {
+		names = append(names, name)
+	}
+	for name := range newPtr {
+		if _, ok := oldPtr[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []MethodChange
+	for _, name := range names {
+		val, valOk := methodChange(name, oldVal, newVal)
+		if valOk {
+			changes = append(changes, val)
+		}
+		if ptr, ok := methodChange(name, oldPtr, newPtr); ok && (!valOk || ptr != val) {
+			ptr.Pointer = true
+			changes = append(changes, ptr)
+		}
+	}
+	return changes
+}
+
+// methodChange reports the change of the method name between the method
+// signatures old and new, if any.
+func methodChange(name string, old, new map[string]string) (MethodChange, bool) {
+	c := MethodChange{Name: name, Old: old[name], New: new[name]}
+	switch {
+	case c.Old == c.New:
+		return c, false
+	case c.Old == "":
+		c.Kind = MethodAdded
+	case c.New == "":
+		c.Kind = MethodRemoved
+	default:
+		c.Kind = MethodChanged
+	}
+	return c, true
+}
+
+// KindCounts returns the number of exported package-level objects of
+// pkg by kind, keyed by "func", "type", "const", and "var", and the
+// number of exported methods declared by exported types, keyed by
+// "method". The methods of an interface type are its explicit methods;
+// those of other named types are the methods declared with them.
+// Type aliases count as types, but the methods of the types they
+// denote are not counted for them.
+//
+func KindCounts(pkg *types.Package) map[string]int {
+	counts := map[string]int{"func": 0, "type": 0, "const": 0, "var": 0, "method": 0}
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+		switch obj := obj.(type) {
+		case *types.Func:
+			counts["func"]++
+		case *types.Const:
+			counts["const"]++
+		case *types.Var:
+			counts["var"]++
+		case *types.TypeName:
+			counts["type"]++
+			if isAlias(obj) {
+				continue
+			}
+			named, ok := obj.Type().(*types.Named)
+			if !ok {
+				continue
+			}
+			if iface, ok := named.Underlying().(*types.Interface); ok {
+				for i := 0; i < iface.NumExplicitMethods(); i++ {
+					if iface.ExplicitMethod(i).Exported() {
+						counts["method"]++
+					}
+				}
+				continue
+			}
+			for i := 0; i < named.NumMethods(); i++ {
+				if named.Method(i).Exported() {
+					counts["method"]++
+				}
+			}
+		}
+	}
+	return counts
+}
+
+// DeclarationOrder returns the package-level objects of pkg in source
+// order: sorted by the files in which they are declared, in order of
+// their first appearance in the export data, and by line within each
+// file. Positions are only recorded if the export data includes them.
+// Objects without a position, such as all objects imported from
+// textual export data, follow those with one, sorted by name: the
+// scope of an imported package does not record the order in which its
+// objects were decoded.
+//
+func DeclarationOrder(pkg *types.Package) []types.Object {
+	scope := pkg.Scope()
+	objs := make([]types.Object, 0, scope.Len())
+	for _, name := range scope.Names() {
+		objs = append(objs, scope.Lookup(name))
+	}
+	sort.Stable(byPos(objs))
+	return objs
+}
+
+// byPos sorts objects by position; objects without a position sort last.
+type byPos []types.Object
+
+func (a byPos) Len() int      { return len(a) }
+func (a byPos) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byPos) Less(i, j int) bool {
+	x, y := a[i].Pos(), a[j].Pos()
+	if !x.IsValid() || !y.IsValid() {
+		return x.IsValid() && !y.IsValid()
+	}
+	return x < y
+}
@@ -0,0 +1,497 @@
+// Copyright 2016 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.6
+
+package gcimporter
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+)
+
+func TestOwner(t *testing.T) {
+	pkg := bimport(t, exportSource(t, "p", "package p; type T int; func F() (T, error, int, []T) { return 0, nil, 0, nil }"), "p")
+	res := pkg.Scope().Lookup("F").Type().(*types.Signature).Results()
+	for i, want := range []struct {
+		pkg         *types.Package
+		predeclared bool
+	}{
+		{pkg, false}, // T
+		{nil, true},  // error
+		{nil, true},  // int
+		{nil, false}, // []T
+	} {
+		typ := res.At(i).Type()
+		owner, predeclared := Owner(typ)
+		if owner != want.pkg || predeclared != want.predeclared {
+			t.Errorf("Owner(%s) = %v, %t; want %v, %t", typ, owner, predeclared, want.pkg, want.predeclared)
+		}
+	}
+}
+
+func TestObjectID(t *testing.T) {
+	const src = `package p
+type Base struct{ ID int }
+func (Base) Hello() {}
+type T struct {
+	Base
+	Name string
+}
+func (t *T) Do() {}
+type I interface{ M() }
+const C = 1
+func F() {}
+`
+	pkg := bimport(t, exportSource(t, "example.com/p", src), "example.com/p")
+	T := pkg.Scope().Lookup("T").Type()
+	member := func(name string) types.Object {
+		obj, _, _ := types.LookupFieldOrMethod(T, true, pkg, name)
+		if obj == nil {
+			t.Fatalf("%s not found", name)
+		}
+		return obj
+	}
+	iface := pkg.Scope().Lookup("I").Type().Underlying().(*types.Interface)
+
+	seen := make(map[string]bool)
+	for _, test := range []struct {
+		obj  types.Object
+		want string
+	}{
+		{pkg.Scope().Lookup("F"), "example.com/p.F"},
+		{pkg.Scope().Lookup("C"), "example.com/p.C"},
+		{pkg.Scope().Lookup("T"), "example.com/p.T"},
+		{member("Do"), "example.com/p.T.Do"},
+		{member("Name"), "example.com/p.T.Name"},
+		{member("Hello"), "example.com/p.Base.Hello"}, // promoted
+		{member("ID"), "example.com/p.Base.ID"},       // promoted
+		{iface.Method(0), "example.com/p.I.M"},
+		{types.Universe.Lookup("error"), "error"},
+	} {
+		got := ObjectID(test.obj)
+		if got != test.want {
+			t.Errorf("ObjectID(%s) = %s; want %s", test.obj, got, test.want)
+		}
+		if seen[got] {
+			t.Errorf("duplicate id %s", got)
+		}
+		seen[got] = true
+	}
+}
+
+func TestShadowsPredeclared(t *testing.T) {
+	const src = `package p
+type error struct{ msg string }
+type len int
+func F() (error, len) { return error{}, 0 }
+type T struct{ cap int }
+`
+	pkg := bimport(t, exportSource(t, "p", src), "p")
+	T := pkg.Scope().Lookup("T").Type().Underlying().(*types.Struct)
+	for _, test := range []struct {
+		obj  types.Object
+		want bool
+	}{
+		{pkg.Scope().Lookup("error"), true},
+		{pkg.Scope().Lookup("len"), true},
+		{pkg.Scope().Lookup("F"), false},
+		{T.Field(0), false}, // not a package-level object
+		{types.Universe.Lookup("error"), false},
+	} {
+		if test.obj == nil {
+			t.Fatalf("object not found")
+		}
+		if got := ShadowsPredeclared(test.obj); got != test.want {
+			t.Errorf("ShadowsPredeclared(%s) = %t; want %t", test.obj, got, test.want)
+		}
+	}
+}
+
+func TestIsAlias(t *testing.T) {
+	const src = `package p
+import "io"
+type B struct{}
+type A = B
+type D B
+type I = int
+type J int
+type R = io.Reader
+type S = []B
+`
+	fset := token.NewFileSet()
+	io := typecheck(t, fset, "io", "package io; type Reader interface{ Read([]byte) (int, error) }")
+	pkg := bimport(t, BExportData(fset, typecheck(t, fset, "p", src, io)), "p")
+	reexported := bimport(t, BExportData(nil, pkg), "p")
+	for _, test := range []struct {
+		name string
+		want bool
+	}{
+		{"A", true},
+		{"B", false},
+		{"D", false},
+		{"I", true},
+		{"J", false},
+		{"R", true},
+		{"S", true},
+	} {
+		for _, pkg := range []*types.Package{pkg, reexported} {
+			tn, ok := pkg.Scope().Lookup(test.name).(*types.TypeName)
+			if !ok {
+				t.Errorf("%s is not a type name", test.name)
+				continue
+			}
+			if got := IsAlias(tn); got != test.want {
+				t.Errorf("IsAlias(%s) = %t; want %t", tn, got, test.want)
+			}
+		}
+	}
+
+	// A denotes B, D does not
+	a := pkg.Scope().Lookup("A").Type()
+	b := pkg.Scope().Lookup("B").Type()
+	d := pkg.Scope().Lookup("D").Type()
+	if !types.Identical(a, b) || types.Identical(d, b) {
+		t.Errorf("A is identical to B: %t; D is identical to B: %t; want true, false", types.Identical(a, b), types.Identical(d, b))
+	}
+}
+
+func TestIsUntyped(t *testing.T) {
+	const src = `package p
+type Duration int64
+const (
+	Pi      = 3.14159
+	Answer  = 42
+	Rune    = 'x'
+	Greet   = "hello"
+	Yes     = 1 < 2
+	Cplx    = 1i
+	Second  Duration = 1e9
+	Typed   int      = 42
+	Float   float32  = 1.5
+	Derived = Second * 2
+	Str     string = "typed"
+)
+`
+	pkg := bimport(t, exportSource(t, "p", src), "p")
+	for name, want := range map[string]bool{
+		"Pi":      true,
+		"Answer":  true,
+		"Rune":    true,
+		"Greet":   true,
+		"Yes":     true,
+		"Cplx":    true,
+		"Second":  false,
+		"Typed":   false,
+		"Float":   false,
+		"Derived": false,
+		"Str":     false,
+	} {
+		c, ok := pkg.Scope().Lookup(name).(*types.Const)
+		if !ok {
+			t.Errorf("constant %s not found", name)
+			continue
+		}
+		if got := IsUntyped(c); got != want {
+			t.Errorf("IsUntyped(%s) = %t; want %t", c, got, want)
+		}
+	}
+
+	// complex constants with integer parts are exported as well
+	if got := pkg.Scope().Lookup("Cplx").(*types.Const).Val().String(); got != "(0 + 1i)" {
+		t.Errorf("Cplx = %s; want (0 + 1i)", got)
+	}
+}
+
+func TestImplementers(t *testing.T) {
+	fset := token.NewFileSet()
+	// a stand-in for the standard library package io
+	io := typecheck(t, fset, "io", "package io; type Reader interface{ Read(p []byte) (n int, err error) }")
+	const src = `package p
+import "io"
+type File struct{}
+func (*File) Read(p []byte) (int, error) { return 0, nil }
+type Bytes []byte
+func (Bytes) Read(p []byte) (int, error) { return 0, nil }
+type Writer struct{}
+func (Writer) Write(p []byte) (int, error) { return 0, nil }
+type ReadCloser interface {
+	io.Reader
+	Close() error
+}
+`
+	data := BExportData(fset, typecheck(t, fset, "p", src, io))
+	packages := make(map[string]*types.Package)
+	_, pkg, err := BImportData(token.NewFileSet(), packages, data, "p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := packages["io"].Scope().Lookup("Reader").Type().Underlying().(*types.Interface)
+
+	var got []string
+	for _, named := range Implementers(reader, pkg) {
+		got = append(got, named.Obj().Name())
+	}
+	if want := "[Bytes File]"; fmt.Sprint(got) != want {
+		t.Errorf("Implementers(io.Reader) = %v; want %s", got, want)
+	}
+}
+
+func TestSignatureJSON(t *testing.T) {
+	fset := token.NewFileSet()
+	// stand-ins for the standard library packages io and fmt
+	io := typecheck(t, fset, "io", "package io; type Writer interface{ Write(p []byte) (n int, err error) }")
+	const src = `package fmt
+import "io"
+func Fprintf(w io.Writer, format string, a ...interface{}) (n int, err error) { return }
+type pp struct{}
+func (p *pp) Write(b []byte) (int, error) { return 0, nil }
+var P = &pp{}
+`
+	data := BExportData(fset, typecheck(t, fset, "fmt", src, io))
+	pkg := bimport(t, data, "fmt")
+
+	for _, test := range []struct {
+		sig  *types.Signature
+		want string
+	}{
+		{
+			pkg.Scope().Lookup("Fprintf").Type().(*types.Signature),
+			`{"params":[{"name":"w","type":"io.Writer"},{"name":"format","type":"string"},{"name":"a","type":"[]interface{}"}],` +
+				`"results":[{"name":"n","type":"int"},{"name":"err","type":"error"}],"variadic":true}`,
+		},
+		{
+			types.NewMethodSet(pkg.Scope().Lookup("P").Type()).At(0).Obj().Type().(*types.Signature),
+			`{"recv":{"name":"p","type":"*fmt.pp"},"params":[{"name":"b","type":"[]byte"}],` +
+				`"results":[{"name":"","type":"int"},{"name":"","type":"error"}],"variadic":false}`,
+		},
+	} {
+		got, err := SignatureJSON(test.sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("SignatureJSON(%s):\ngot  %s\nwant %s", test.sig, got, test.want)
+		}
+	}
+}
+
+func TestQualifiers(t *testing.T) {
+	fset := token.NewFileSet()
+	// stand-ins for standard library packages
+	url := typecheck(t, fset, "net/url", "package url; type URL struct{ Path string }")
+	mrand := typecheck(t, fset, "math/rand", "package rand; type Rand struct{}")
+	crand := typecheck(t, fset, "crypto/rand", "package rand; type Reader interface{ Read([]byte) (int, error) }")
+	const src = `package http
+import (
+	"crypto/rand"
+	mrand "math/rand"
+	"net/url"
+	"unsafe"
+)
+type Request struct {
+	URL *url.URL
+}
+func NewRequest(u *url.URL, r rand.Reader, s *mrand.Rand, p unsafe.Pointer) *Request { return nil }
+`
+	data := BExportData(fset, typecheck(t, fset, "net/http", src, url, mrand, crand, types.Unsafe))
+	pkg := bimport(t, data, "net/http")
+	obj := pkg.Scope().Lookup("NewRequest")
+
+	for _, test := range []struct {
+		name string
+		qf   types.Qualifier
+		want string
+	}{
+		{"FullyQualified", FullyQualified, "func net/http.NewRequest(u *net/url.URL, r crypto/rand.Reader, s *math/rand.Rand, p unsafe.Pointer) *net/http.Request"},
+		{"PackageNameQualifier", PackageNameQualifier(pkg), "func NewRequest(u *url.URL, r crypto/rand.Reader, s *math/rand.Rand, p unsafe.Pointer) *Request"},
+	} {
+		if got := types.ObjectString(obj, test.qf); got != test.want {
+			t.Errorf("%s:\ngot  %s\nwant %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestImplementsCommon(t *testing.T) {
+	const src = `package p
+type Color int
+func (c Color) String() string { return "" }
+func (c *Color) UnmarshalJSON(b []byte) error { return nil }
+func (c Color) MarshalText() ([]byte, error) { return nil, nil }
+type Fault struct{}
+func (*Fault) Error() string { return "" }
+`
+	pkg := bimport(t, exportSource(t, "p", src), "p")
+	color := pkg.Scope().Lookup("Color").Type()
+	fault := pkg.Scope().Lookup("Fault").Type()
+	for _, test := range []struct {
+		typ  types.Type
+		want CommonIfaces
+	}{
+		{color, CommonIfaces{Stringer: true, TextMarshaler: true}},
+		{types.NewPointer(color), CommonIfaces{Stringer: true, JSONUnmarshaler: true, TextMarshaler: true}},
+		{fault, CommonIfaces{}},
+		{types.NewPointer(fault), CommonIfaces{Error: true}},
+		{types.Universe.Lookup("error").Type(), CommonIfaces{Error: true}},
+	} {
+		if got := ImplementsCommon(test.typ); got != test.want {
+			t.Errorf("ImplementsCommon(%s) = %+v; want %+v", test.typ, got, test.want)
+		}
+	}
+}
+
+func TestLoadOrder(t *testing.T) {
+	// a -> b, c; b -> d; c -> d
+	fset := token.NewFileSet()
+	d := typecheck(t, fset, "d", "package d; type D int")
+	b := typecheck(t, fset, "b", `package b; import "d"; type B d.D`, d)
+	c := typecheck(t, fset, "c", `package c; import "d"; type C d.D`, d)
+	a := typecheck(t, fset, "a", `package a; import ("b"; "c"); var X b.B; var Y c.C`, b, c)
+
+	packages := make(map[string]*types.Package)
+	for _, pkg := range []*types.Package{d, b, c, a} {
+		if _, _, err := BImportData(token.NewFileSet(), packages, BExportData(fset, pkg), pkg.Path()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	order, err := LoadOrder(packages["a"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, pkg := range order {
+		got = append(got, pkg.Path())
+	}
+	if want := "[d b c a]"; fmt.Sprint(got) != want {
+		t.Errorf("LoadOrder(a) = %v; want %s", got, want)
+	}
+
+	// invalid export data may record import cycles
+	p := types.NewPackage("p", "p")
+	q := types.NewPackage("q", "q")
+	p.SetImports([]*types.Package{q})
+	q.SetImports([]*types.Package{p})
+	if _, err := LoadOrder(p); err == nil || err.Error() != "import cycle: p -> q -> p" {
+		t.Errorf("LoadOrder(p) = %v; want import cycle error", err)
+	}
+}
+
+func TestMethodSetDiff(t *testing.T) {
+	const v1 = `package p
+type Base struct{}
+func (Base) Promoted() {}
+type T struct{ Base }
+func (T) Get() int  { return 0 }
+func (T) Set(x int) {}
+func (T) Old()      {}
+`
+	const v2 = `package p
+type Base struct{}
+func (Base) Promoted() {}
+func (*Base) Reset()   {}
+type T struct{ Base }
+func (T) Get() string { return "" }
+func (*T) Set(x int)  {}
+func (T) New()        {}
+`
+	T := func(src string) *types.Named {
+		pkg := bimport(t, exportSource(t, "p", src), "p")
+		return pkg.Scope().Lookup("T").Type().(*types.Named)
+	}
+	var got []string
+	for _, c := range MethodSetDiff(T(v1), T(v2)) {
+		got = append(got, fmt.Sprintf("%s %s pointer=%v (%s -> %s)", c.Name, c.Kind, c.Pointer, c.Old, c.New))
+	}
+	want := []string{
+		"Get changed pointer=false (func() int -> func() string)",
+		"New added pointer=false ( -> func())",
+		"Old removed pointer=false (func() -> )",
+		"Reset added pointer=true ( -> func())",
+		"Set removed pointer=false (func(x int) -> )",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("MethodSetDiff:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestDeclarationOrder(t *testing.T) {
+	const src = `package p
+func Zeta() {}
+type Beta int
+const Omega = 1
+var Alpha Beta
+`
+	names := func(objs []types.Object) string {
+		var s []string
+		for _, obj := range objs {
+			s = append(s, obj.Name())
+		}
+		return strings.Join(s, " ")
+	}
+
+	pkg := bimport(t, exportSource(t, "p", src), "p")
+	if got, want := names(DeclarationOrder(pkg)), "Zeta Beta Omega Alpha"; got != want {
+		t.Errorf("with positions: got %s; want %s", got, want)
+	}
+
+	// Without positions, objects are sorted by name.
+	fset := token.NewFileSet()
+	data := bexportData(fset, typecheck(t, fset, "p", src), exportVersion, false)
+	pkg = bimport(t, data, "p")
+	if got, want := names(DeclarationOrder(pkg)), "Alpha Beta Omega Zeta"; got != want {
+		t.Errorf("without positions: got %s; want %s", got, want)
+	}
+}
+
+func TestKindCounts(t *testing.T) {
+	const src = `package p
+import "io"
+const A, B, c = 1, 2, 3
+var V, w int
+type T struct{}
+func (T) M() {}
+func (T) N() {}
+func (T) m() {}
+type I interface{ io.Reader; Close() error; private() }
+type R = io.Reader
+type u int
+func (u) Exported() {}
+func F() {}
+func g() {}
+`
+	fset := token.NewFileSet()
+	io := typecheck(t, fset, "io", "package io; type Reader interface{ Read([]byte) (int, error) }")
+	pkg := bimport(t, BExportData(fset, typecheck(t, fset, "p", src, io)), "p")
+	got := KindCounts(pkg)
+	want := map[string]int{"func": 1, "type": 3, "const": 2, "var": 1, "method": 3}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("KindCounts = %v; want %v", got, want)
+	}
+
+	// a standard library package
+	conf := loader.Config{}
+	conf.Import("strings")
+	prog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stringsPkg := bimport(t, BExportData(prog.Fset, prog.Package("strings").Pkg), "strings")
+	got = KindCounts(stringsPkg)
+	for kind, r := range map[string]struct{ min, max int }{
+		"func":   {40, 200}, // Contains, Fields, Split, ...
+		"type":   {3, 20},   // Builder, Reader, Replacer
+		"const":  {0, 10},
+		"var":    {0, 10},
+		"method": {20, 100}, // (*Builder).WriteString, (*Reader).Read, ...
+	} {
+		if n := got[kind]; n < r.min || n > r.max {
+			t.Errorf("KindCounts(strings)[%q] = %d; want %d..%d", kind, n, r.min, r.max)
+		}
+	}
+}